@@ -0,0 +1,986 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+const (
+	_adminURLPattern      = "/admin/v1"
+	_credentialsURL       = "/credentials"
+	_credentialURL        = "/credentials/:provider"
+	_rotateCredentialURL  = "/credentials/:provider/rotate"
+	_destinationsURL      = "/destinations"
+	_destinationURL       = "/destinations/:name"
+	_rotateDestinationURL = "/destinations/:name/rotate"
+
+	_credentialsSource = "credentials"
+)
+
+var (
+	// ErrInvalidRequest is returned when a destination or credential managed through the admin API is invalid.
+	ErrInvalidRequest = errors.New("invalid request")
+
+	// ErrUnknownDestination is returned when sending to a destination which doesn't exist or belongs to another
+	// channel or tenant.
+	ErrUnknownDestination = errors.New("unknown destination")
+)
+
+// destinationNamePattern restricts names of destinations, so that they are safe to use in URLs and logs.
+var destinationNamePattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// _credentialKeys are the configuration keys of the credentials of every provider managed through the admin API.
+var _credentialKeys = map[string][]string{
+	_slackProvider: {"slack_web_hook_url"},
+	_twilioProvider: {
+		"twilio.sid", "twilio.token", "twilio.number", "twilio.whatsapp_number", "twilio.api_base_url",
+	},
+	_smtpProvider: {
+		"mail.email_sender", "mail.smtp_host", "mail.smtp_port", "mail.smtp_username", "mail.smtp_password",
+	},
+	_webhookProvider:  {"webhook.url", "webhook.signing_secret", "webhook.headers"},
+	_teamsProvider:    {"teams.web_hook_url"},
+	_discordProvider:  {"discord.web_hook_url"},
+	_telegramProvider: {"telegram.bot_token", "telegram.base_url"},
+	_chatProvider:     {"chat.web_hook_url", "chat.endpoints"},
+	_matrixProvider:   {"matrix.homeserver_url", "matrix.access_token"},
+	_fcmProvider:      {"fcm.service_account", "fcm.project_id"},
+	_apnsProvider:     {"apns.key", "apns.key_id", "apns.team_id", "apns.topic"},
+}
+
+// _destinationAddressTags validate the addresses of destinations of every channel.
+var _destinationAddressTags = map[string]string{
+	_slackChannel: "url",
+	_smsChannel:   "sms_address",
+	_mailChannel:  "email",
+	// Webhook URLs of destinations are managed by the admin, they don't have to be on an allowed host.
+	_webhookChannel:  "url",
+	_teamsChannel:    "url",
+	_discordChannel:  "url",
+	_telegramChannel: "telegram_chat_id",
+	_chatChannel:     "url",
+	_matrixChannel:   "matrix_room",
+	_fcmChannel:      "printascii,max=4096",
+	_apnsChannel:     "hexadecimal,max=200",
+	_voiceChannel:    "e164",
+}
+
+// Admin manages destinations and provider credentials at runtime. Stored credentials override the ones of the
+// configuration of the deployment or of a tenant, the resulting configuration is applied on every change of the
+// credentials without restart. Destinations are looked up on every notification, so that changing them applies
+// nothing.
+//
+// Admin is safe for concurrent use by multiple goroutines.
+type Admin struct {
+	store *AdminStore
+
+	mu   sync.Mutex
+	base *Config
+	// config is the applied configuration, base with the stored credentials of the deployment.
+	config  *Config
+	tenants *Tenants
+	apply   []func(*Config)
+	secrets []func([]string)
+	state   atomic.Pointer[adminState]
+}
+
+type adminState struct {
+	destinations map[destinationKey]Destination
+	// credentials are the stored credentials keyed by tenant, the ones of the deployment by the empty string.
+	credentials map[string][]Credential
+}
+
+// destinationKey identifies a destination, names are unique per tenant.
+type destinationKey struct {
+	tenant string
+	name   string
+}
+
+// NewAdmin is a constructor function for Admin, the stored state is loaded by Reload.
+func NewAdmin(store *AdminStore) *Admin {
+	a := &Admin{store: store}
+	a.state.Store(&adminState{
+		destinations: make(map[destinationKey]Destination),
+		credentials:  make(map[string][]Credential),
+	})
+
+	return a
+}
+
+// WithTenants applies the stored credentials of tenants on top of their configuration, the tenants are rebuilt
+// whenever the stored credentials change.
+func (a *Admin) WithTenants(tenants *Tenants) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tenants = tenants
+	tenants.OnBuild(a.tenantConfig)
+}
+
+// tenantConfig applies the stored credentials of the tenant on top of its configuration.
+func (a *Admin) tenantConfig(tenant TenantConfig) (*Config, error) {
+	credentials := a.state.Load().credentials[tenant.ID]
+	if len(credentials) == 0 {
+		return tenant.Config, nil
+	}
+
+	return applyCredentials(tenant.Config, credentials)
+}
+
+// OnApply registers the function applying the configuration with the stored credentials, functions are called in
+// registration order.
+func (a *Admin) OnApply(apply func(*Config)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.apply = append(a.apply, apply)
+}
+
+// OnSecrets registers the function receiving the secrets to mask whenever they change, the ones of the applied
+// configuration along with the ones of the stored state.
+func (a *Admin) OnSecrets(set func(secrets []string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.secrets = append(a.secrets, set)
+}
+
+// Reload applies the stored credentials on top of the configuration. On error the state in effect is kept.
+func (a *Admin) Reload(ctx context.Context, config *Config) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prev := a.base
+	a.base = config
+
+	if err := a.refresh(ctx); err != nil {
+		a.base = prev
+
+		return err
+	}
+
+	return nil
+}
+
+// refresh loads the stored state and applies it, a.mu must be held.
+func (a *Admin) refresh(ctx context.Context) error {
+	stored, err := a.store.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	credentials := make(map[string][]Credential)
+	for _, c := range stored {
+		credentials[c.Tenant] = append(credentials[c.Tenant], c)
+	}
+
+	config, err := applyCredentials(a.base, credentials[""])
+	if err != nil {
+		return err
+	}
+
+	destinations, err := a.loadDestinations(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.state.Store(&adminState{destinations: destinations, credentials: credentials})
+	a.config = config
+	a.publishSecrets()
+
+	for _, apply := range a.apply {
+		apply(config)
+	}
+
+	if a.tenants != nil {
+		if err := a.tenants.rebuild(ctx); err != nil {
+			return fmt.Errorf("failed to apply credentials of tenants: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// refreshDestinations loads the stored destinations, the applied configuration is kept. a.mu must be held.
+func (a *Admin) refreshDestinations(ctx context.Context) error {
+	destinations, err := a.loadDestinations(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.state.Store(&adminState{destinations: destinations, credentials: a.state.Load().credentials})
+	a.publishSecrets()
+
+	return nil
+}
+
+func (a *Admin) loadDestinations(ctx context.Context) (map[destinationKey]Destination, error) {
+	stored, err := a.store.Destinations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := make(map[destinationKey]Destination, len(stored))
+	for _, d := range stored {
+		destinations[destinationKey{tenant: d.Tenant, name: d.Name}] = d
+	}
+
+	return destinations, nil
+}
+
+// publishSecrets passes the secrets to mask to the registered functions, a.mu must be held.
+func (a *Admin) publishSecrets() {
+	var secrets []string
+	if a.config != nil {
+		secrets = a.config.SecretValues()
+	}
+
+	secrets = append(secrets, a.SecretValues()...)
+
+	for _, set := range a.secrets {
+		set(secrets)
+	}
+}
+
+// SecretValues returns the secret values of the credentials of tenants and the addresses of the Slack and webhook
+// destinations, which may hold secrets in their URL. Secrets of the credentials of the deployment are among the
+// ones of the applied configuration.
+func (a *Admin) SecretValues() []string {
+	var secrets []string
+
+	state := a.state.Load()
+	keys := secretConfigKeys()
+
+	for tenant, credentials := range state.credentials {
+		if tenant == "" {
+			continue
+		}
+
+		for _, c := range credentials {
+			for key, value := range c.Values {
+				if keys[key] && value != "" {
+					secrets = append(secrets, value)
+				}
+			}
+		}
+	}
+
+	for _, d := range state.destinations {
+		if d.secretAddress() {
+			secrets = append(secrets, d.Address)
+		}
+	}
+
+	return secrets
+}
+
+// Credentials lists the stored credentials.
+func (a *Admin) Credentials(ctx context.Context) ([]Credential, error) {
+	return a.store.Credentials(ctx)
+}
+
+// CreateCredential stores the credentials of the provider and applies them, to the tenant when it is set.
+func (a *Admin) CreateCredential(ctx context.Context, c Credential) (Credential, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkCredential(ctx, c.Tenant, c.Provider, c.Values); err != nil {
+		return Credential{}, err
+	}
+
+	c, err := a.store.CreateCredential(ctx, c)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return c, a.refresh(ctx)
+}
+
+// RotateCredential replaces the credentials of the provider of the tenant, empty for the deployment, and applies
+// them.
+func (a *Admin) RotateCredential(ctx context.Context, tenant, provider string, values map[string]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkCredential(ctx, tenant, provider, values); err != nil {
+		return err
+	}
+
+	if err := a.store.RotateCredential(ctx, tenant, provider, values); err != nil {
+		return err
+	}
+
+	return a.refresh(ctx)
+}
+
+// RevokeCredential deletes the credentials of the provider of the tenant, empty for the deployment, falling back to
+// the ones of the configuration.
+func (a *Admin) RevokeCredential(ctx context.Context, tenant, provider string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.RevokeCredential(ctx, tenant, provider); err != nil {
+		return err
+	}
+
+	return a.refresh(ctx)
+}
+
+// checkCredential verifies the configuration of the tenant, or of the deployment when it is empty, stays valid with
+// the credentials of the provider replaced.
+func (a *Admin) checkCredential(ctx context.Context, tenant, provider string, values map[string]string) error {
+	if _, ok := _credentialKeys[provider]; !ok {
+		return fmt.Errorf("%w: unknown provider %q", ErrInvalidRequest, provider)
+	}
+
+	base, err := a.tenantBase(tenant)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := a.store.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	credentials = slices.DeleteFunc(credentials, func(c Credential) bool {
+		return c.Tenant != tenant || c.Provider == provider
+	})
+	credentials = append(credentials, Credential{Provider: provider, Tenant: tenant, Values: values})
+
+	if _, err := applyCredentials(base, credentials); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	return nil
+}
+
+// tenantBase returns the configuration of the tenant, or of the deployment when it is empty, before the stored
+// credentials are applied. Unknown tenants are reported as ErrInvalidRequest.
+func (a *Admin) tenantBase(tenant string) (*Config, error) {
+	if tenant == "" {
+		return a.base, nil
+	}
+
+	if a.tenants != nil {
+		if t, ok := a.tenants.Tenant(tenant); ok {
+			return t.base, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unknown tenant %q", ErrInvalidRequest, tenant)
+}
+
+// Destinations lists the stored destinations.
+func (a *Admin) Destinations(ctx context.Context) ([]Destination, error) {
+	return a.store.Destinations(ctx)
+}
+
+// CreateDestination stores the destination and makes it available to clients.
+func (a *Admin) CreateDestination(ctx context.Context, d Destination) (Destination, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !destinationNamePattern.MatchString(d.Name) {
+		return Destination{}, fmt.Errorf("%w: invalid destination name %q", ErrInvalidRequest, d.Name)
+	}
+
+	if _, err := a.tenantBase(d.Tenant); err != nil {
+		return Destination{}, err
+	}
+
+	if err := checkDestinationAddress(d.Channel, d.Address); err != nil {
+		return Destination{}, err
+	}
+
+	d, err := a.store.CreateDestination(ctx, d)
+	if err != nil {
+		return Destination{}, err
+	}
+
+	return d, a.refreshDestinations(ctx)
+}
+
+// RotateDestination replaces the address of the destination of the tenant, empty for the deployment.
+func (a *Admin) RotateDestination(ctx context.Context, tenant, name, address string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	d, ok := a.state.Load().destinations[destinationKey{tenant: tenant, name: name}]
+	if !ok {
+		return fmt.Errorf("destination %w", ErrNotFound)
+	}
+
+	if err := checkDestinationAddress(d.Channel, address); err != nil {
+		return err
+	}
+
+	if err := a.store.RotateDestination(ctx, tenant, name, address); err != nil {
+		return err
+	}
+
+	return a.refreshDestinations(ctx)
+}
+
+// RevokeDestination deletes the destination of the tenant, empty for the deployment, notifications to it are
+// rejected from then on.
+func (a *Admin) RevokeDestination(ctx context.Context, tenant, name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.RevokeDestination(ctx, tenant, name); err != nil {
+		return err
+	}
+
+	return a.refreshDestinations(ctx)
+}
+
+// destination looks up the destination of the channel available to the tenant of the context.
+func (a *Admin) destination(ctx context.Context, channel, name string) (Destination, error) {
+	if a != nil {
+		d, ok := a.state.Load().destinations[destinationKey{tenant: TenantIDFromContext(ctx), name: name}]
+		if ok && d.Channel == channel {
+			return d, nil
+		}
+	}
+
+	return Destination{}, Permanent(fmt.Errorf("%w: %s", ErrUnknownDestination, name))
+}
+
+func checkDestinationAddress(channel, address string) error {
+	tag, ok := _destinationAddressTags[channel]
+	if !ok {
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidRequest, channel)
+	}
+
+	if err := newRequestValidator().Var(address, "required,"+tag); err != nil {
+		return fmt.Errorf("%w: address must be a valid %s", ErrInvalidRequest, tag)
+	}
+
+	return nil
+}
+
+// applyCredentials returns a copy of the configuration with the credentials applied and validated.
+func applyCredentials(base *Config, credentials []Credential) (*Config, error) {
+	config := *base
+
+	values := make(map[string]any)
+
+	for _, c := range credentials {
+		for key, value := range c.Values {
+			if !slices.Contains(_credentialKeys[c.Provider], key) {
+				return nil, fmt.Errorf("%s can't be set by credentials of %s", key, c.Provider)
+			}
+
+			values[key] = value
+		}
+	}
+
+	fields := configFields(reflect.ValueOf(&config).Elem(), "", "")
+	if errs := applyConfigValues(fields, values, _credentialsSource); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := validateConfig(&config, ""); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Notifier returns Notifier sending notifications addressed to destinations, as stored in the context by the
+// endpoints, to the address of the destination and other notifications as they are through next. Without Admin,
+// i.e. when it is nil, notifications addressed to destinations are rejected.
+func (a *Admin) Notifier(next Notifier) Notifier {
+	return &destinationNotifier{admin: a, next: next}
+}
+
+type destinationNotifier struct {
+	admin *Admin
+	next  Notifier
+}
+
+var _ Notifier = (*destinationNotifier)(nil)
+
+// NotifySlack sends Slack notification to the webhook of the destination.
+func (n *destinationNotifier) NotifySlack(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifySlack(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _slackChannel, name)
+	if err != nil {
+		return err
+	}
+
+	return n.next.NotifySlack(withDestinationURL(ctx, d.Address), msg)
+}
+
+// NotifySMS sends SMS notification to the number of the destination.
+func (n *destinationNotifier) NotifySMS(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifySMS(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _smsChannel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*SMSRequestBody)
+	body.SendToNumber = d.Address
+
+	return n.next.NotifySMS(ctx, &body)
+}
+
+// NotifyVoice places voice call to the number of the destination.
+func (n *destinationNotifier) NotifyVoice(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyVoice(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _voiceChannel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*VoiceRequestBody)
+	body.SendToNumber = d.Address
+
+	return n.next.NotifyVoice(ctx, &body)
+}
+
+// NotifyMail sends mail notification to the address of the destination.
+func (n *destinationNotifier) NotifyMail(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyMail(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _mailChannel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*MailRequestBody)
+	body.SendTo = d.Address
+
+	return n.next.NotifyMail(ctx, &body)
+}
+
+// NotifyWebhook sends webhook notification to the URL of the destination.
+func (n *destinationNotifier) NotifyWebhook(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyWebhook(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _webhookChannel, name)
+	if err != nil {
+		return err
+	}
+
+	return n.next.NotifyWebhook(withDestinationURL(ctx, d.Address), msg)
+}
+
+// NotifyTeams sends Microsoft Teams notification to the webhook of the destination.
+func (n *destinationNotifier) NotifyTeams(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyTeams(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _teamsChannel, name)
+	if err != nil {
+		return err
+	}
+
+	return n.next.NotifyTeams(withDestinationURL(ctx, d.Address), msg)
+}
+
+// NotifyDiscord sends Discord notification to the webhook of the destination.
+func (n *destinationNotifier) NotifyDiscord(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyDiscord(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _discordChannel, name)
+	if err != nil {
+		return err
+	}
+
+	return n.next.NotifyDiscord(withDestinationURL(ctx, d.Address), msg)
+}
+
+// NotifyTelegram sends Telegram notification to the chat of the destination.
+func (n *destinationNotifier) NotifyTelegram(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyTelegram(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _telegramChannel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*TelegramRequestBody)
+	body.ChatID = d.Address
+
+	return n.next.NotifyTelegram(ctx, &body)
+}
+
+// NotifyChat sends Mattermost or Rocket.Chat notification to the webhook of the destination.
+func (n *destinationNotifier) NotifyChat(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyChat(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _chatChannel, name)
+	if err != nil {
+		return err
+	}
+
+	return n.next.NotifyChat(withDestinationURL(ctx, d.Address), msg)
+}
+
+// NotifyMatrix sends Matrix notification to the room of the destination.
+func (n *destinationNotifier) NotifyMatrix(ctx context.Context, msg any) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return n.next.NotifyMatrix(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, _matrixChannel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*MatrixRequestBody)
+	body.Room = d.Address
+
+	return n.next.NotifyMatrix(ctx, &body)
+}
+
+// NotifyFCM sends push notification to the device token of the destination.
+func (n *destinationNotifier) NotifyFCM(ctx context.Context, msg any) error {
+	return n.notifyPush(ctx, _fcmChannel, msg, n.next.NotifyFCM)
+}
+
+// NotifyAPNS sends push notification to the device token of the destination.
+func (n *destinationNotifier) NotifyAPNS(ctx context.Context, msg any) error {
+	return n.notifyPush(ctx, _apnsChannel, msg, n.next.NotifyAPNS)
+}
+
+// NotifyWebPush sends web push notification, web push notifications are addressed to users rather than destinations.
+func (n *destinationNotifier) NotifyWebPush(ctx context.Context, msg any) error {
+	return n.next.NotifyWebPush(ctx, msg)
+}
+
+// notifyPush sends push notification to the device token of the destination. Destinations whose token the push
+// service reports as unregistered are revoked, so that they are not sent to again.
+func (n *destinationNotifier) notifyPush(ctx context.Context, channel string, msg any, notify Effector) error {
+	name := DestinationFromContext(ctx)
+	if name == "" {
+		return notify(ctx, msg)
+	}
+
+	d, err := n.admin.destination(ctx, channel, name)
+	if err != nil {
+		return err
+	}
+
+	body := *msg.(*PushRequestBody)
+	body.Token = d.Address
+
+	err = notify(ctx, &body)
+	if errors.Is(err, ErrUnregisteredToken) {
+		if rerr := n.admin.RevokeDestination(context.WithoutCancel(ctx), d.Tenant, name); rerr != nil {
+			LoggerFromContext(ctx).Error("failed to revoke destination with unregistered token",
+				"destination", name, "error", rerr)
+		} else {
+			LoggerFromContext(ctx).Info("revoked destination with unregistered token", "destination", name)
+		}
+	}
+
+	return err
+}
+
+type destinationContextKey struct{}
+
+// destinationURLContextKey holds the URL of the destination of channels sending to URLs, e.g. Slack webhooks.
+type destinationURLContextKey struct{}
+
+// WithDestination stores the name of the destination the notification is addressed to in the context.
+func WithDestination(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, destinationContextKey{}, name)
+}
+
+// DestinationFromContext retrieves the name of the destination stored in the context, empty if there is none.
+func DestinationFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(destinationContextKey{}).(string)
+
+	return name
+}
+
+func withDestinationURL(ctx context.Context, url string) context.Context {
+	return context.WithValue(ctx, destinationURLContextKey{}, url)
+}
+
+func destinationURLFromContext(ctx context.Context) string {
+	url, _ := ctx.Value(destinationURLContextKey{}).(string)
+
+	return url
+}
+
+// AdminAuthMiddleware rejects requests without the admin token as bearer token with 401 Unauthorized.
+func AdminAuthMiddleware(token string) httptreemux.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+
+	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error": "Unauthorized."}`, http.StatusUnauthorized)
+
+				return
+			}
+
+			next(w, r, m)
+		}
+	}
+}
+
+// secretConfigKeys reports which configuration keys hold secrets.
+func secretConfigKeys() map[string]bool {
+	secrets := make(map[string]bool)
+
+	for _, f := range configFields(reflect.ValueOf(&Config{}).Elem(), "", "") {
+		secrets[f.key] = f.secret
+	}
+
+	return secrets
+}
+
+// MakeListCredentialsEndpoint creates endpoint listing the stored credentials, with secrets masked.
+func MakeListCredentialsEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	secrets := secretConfigKeys()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		credentials, err := admin.Credentials(r.Context())
+		if err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		for _, c := range credentials {
+			for key := range c.Values {
+				if secrets[key] {
+					c.Values[key] = _redacted
+				}
+			}
+		}
+
+		writeAdminJSON(w, http.StatusOK, credentials)
+	}
+}
+
+// MakeCreateCredentialEndpoint creates endpoint storing the credentials of a provider.
+func MakeCreateCredentialEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body CredentialRequestBody
+		if !decodeAdminRequest(w, r, &body) {
+			return
+		}
+
+		c, err := admin.CreateCredential(r.Context(), Credential{
+			Provider: body.Provider, Tenant: body.Tenant, Values: body.Values,
+		})
+		if err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		writeAdminJSON(w, http.StatusCreated, Credential{
+			Provider: c.Provider, Tenant: c.Tenant, Version: c.Version, CreatedAt: c.CreatedAt, RotatedAt: c.RotatedAt,
+		})
+	}
+}
+
+// MakeRotateCredentialEndpoint creates endpoint replacing the credentials of a provider, of the tenant given by the
+// tenant query parameter if any.
+func MakeRotateCredentialEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body CredentialRequestBody
+		if !decodeAdminRequest(w, r, &body) {
+			return
+		}
+
+		provider := httptreemux.ContextParams(r.Context())["provider"]
+
+		if err := admin.RotateCredential(r.Context(), r.URL.Query().Get("tenant"), provider, body.Values); err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		writeAdminJSON(w, http.StatusOK, map[string]string{"status": "Credential rotated."})
+	}
+}
+
+// MakeRevokeCredentialEndpoint creates endpoint deleting the credentials of a provider, of the tenant given by the
+// tenant query parameter if any.
+func MakeRevokeCredentialEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider := httptreemux.ContextParams(r.Context())["provider"]
+
+		if err := admin.RevokeCredential(r.Context(), r.URL.Query().Get("tenant"), provider); err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MakeListDestinationsEndpoint creates endpoint listing the destinations, with URLs masked.
+func MakeListDestinationsEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		destinations, err := admin.Destinations(r.Context())
+		if err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		for i := range destinations {
+			if destinations[i].secretAddress() {
+				destinations[i].Address = _redacted
+			}
+		}
+
+		writeAdminJSON(w, http.StatusOK, destinations)
+	}
+}
+
+// MakeCreateDestinationEndpoint creates endpoint storing a destination.
+func MakeCreateDestinationEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body DestinationRequestBody
+		if !decodeAdminRequest(w, r, &body) {
+			return
+		}
+
+		d, err := admin.CreateDestination(r.Context(), Destination{
+			Name: body.Name, Channel: body.Channel, Tenant: body.Tenant, Address: body.Address,
+		})
+		if err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		if d.secretAddress() {
+			d.Address = _redacted
+		}
+
+		writeAdminJSON(w, http.StatusCreated, d)
+	}
+}
+
+// MakeRotateDestinationEndpoint creates endpoint replacing the address of a destination, of the tenant given by the
+// tenant query parameter if any.
+func MakeRotateDestinationEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body DestinationRequestBody
+		if !decodeAdminRequest(w, r, &body) {
+			return
+		}
+
+		name := httptreemux.ContextParams(r.Context())["name"]
+
+		if err := admin.RotateDestination(r.Context(), r.URL.Query().Get("tenant"), name, body.Address); err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		writeAdminJSON(w, http.StatusOK, map[string]string{"status": "Destination rotated."})
+	}
+}
+
+// MakeRevokeDestinationEndpoint creates endpoint deleting a destination, of the tenant given by the tenant query
+// parameter if any.
+func MakeRevokeDestinationEndpoint(admin *Admin) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httptreemux.ContextParams(r.Context())["name"]
+
+		if err := admin.RevokeDestination(r.Context(), r.URL.Query().Get("tenant"), name); err != nil {
+			writeAdminError(w, r, err)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request, body any) bool {
+	//nolint: errcheck
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+		return false
+	}
+
+	return true
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	//nolint: errcheck
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		status = http.StatusConflict
+	default:
+		LoggerFromContext(r.Context()).Error("admin request failed", "error", err)
+
+		err = errors.New("Internal error")
+	}
+
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,419 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+func newAdminConfig(t *testing.T) internal.AdminConfig {
+	t.Helper()
+
+	return internal.AdminConfig{
+		DBPath:        filepath.Join(t.TempDir(), "admin.db"),
+		Token:         "admin-token",
+		EncryptionKey: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)),
+	}
+}
+
+func TestAdminStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	config := newAdminConfig(t)
+
+	store, err := internal.NewAdminStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.CreateCredential(ctx, internal.Credential{
+		Provider: "twilio", Values: map[string]string{"twilio.token": "stored-twilio-token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.CreateCredential(ctx, internal.Credential{Provider: "twilio"})
+	if !errors.Is(err, internal.ErrAlreadyExists) {
+		t.Fatalf("Expected error %v, got: %v", internal.ErrAlreadyExists, err)
+	}
+
+	err = store.RotateCredential(ctx, "", "twilio", map[string]string{"twilio.token": "rotated-twilio-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := store.Credentials(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(credentials) != 1 || credentials[0].Version != 2 ||
+		credentials[0].Values["twilio.token"] != "rotated-twilio-token" {
+		t.Fatalf("Expected rotated credential, got: %+v", credentials)
+	}
+
+	if err := store.RotateDestination(ctx, "", "missing", "+35988357997"); !errors.Is(err, internal.ErrNotFound) {
+		t.Fatalf("Expected error %v, got: %v", internal.ErrNotFound, err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(config.DBPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, []byte("rotated-twilio-token")) || bytes.Contains(data, []byte("stored-twilio-token")) {
+		t.Fatal("Expected secrets to be encrypted at rest")
+	}
+
+	config.EncryptionKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32))
+
+	store, err = internal.NewAdminStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//nolint: errcheck
+	defer store.Close()
+
+	if _, err := store.Credentials(ctx); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Fatalf("Expected secrets to be unreadable with another key, got: %v", err)
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Admin = newAdminConfig(t)
+	config.Throttle.SlackRate = 0
+
+	var slackCalls atomic.Int32
+
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slackCalls.Add(1)
+	}))
+	defer slack.Close()
+
+	store, err := internal.NewAdminStore(config.Admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//nolint: errcheck
+	defer store.Close()
+
+	s := internal.NewService(config)
+
+	var (
+		applied atomic.Pointer[internal.Config]
+		applies atomic.Int32
+	)
+
+	admin := internal.NewAdmin(store)
+	admin.OnApply(s.Reload)
+	admin.OnApply(func(c *internal.Config) {
+		applied.Store(c)
+		applies.Add(1)
+	})
+
+	if err := admin.Reload(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := internal.NewMux(config, logger, s, internal.WithAdmin(admin))
+
+	type test struct {
+		name               string
+		method             string
+		url                string
+		token              string
+		requestBody        any
+		expectedStatusCode int
+		expectedTwilio     string
+	}
+
+	tests := []test{
+		{
+			name:               "request without admin token should be unauthorized",
+			method:             http.MethodGet,
+			url:                "/admin/v1/credentials",
+			token:              "wrong-token",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "created credential should be applied",
+			method: http.MethodPost,
+			url:    "/admin/v1/credentials",
+			requestBody: &internal.CredentialRequestBody{
+				Provider: "twilio", Values: map[string]string{"twilio.token": "admin-twilio-token"},
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedTwilio:     "admin-twilio-token",
+		},
+		{
+			name:               "existing credential should conflict",
+			method:             http.MethodPost,
+			url:                "/admin/v1/credentials",
+			requestBody:        &internal.CredentialRequestBody{Provider: "twilio"},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:   "credential of another provider should be rejected",
+			method: http.MethodPost,
+			url:    "/admin/v1/credentials",
+			requestBody: &internal.CredentialRequestBody{
+				Provider: "smtp", Values: map[string]string{"twilio.token": "admin-twilio-token"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "rotated credential should be applied",
+			method: http.MethodPost,
+			url:    "/admin/v1/credentials/twilio/rotate",
+			requestBody: &internal.CredentialRequestBody{
+				Values: map[string]string{"twilio.token": "rotated-twilio-token"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedTwilio:     "rotated-twilio-token",
+		},
+		{
+			name:               "revoked credential should fall back to configuration",
+			method:             http.MethodDelete,
+			url:                "/admin/v1/credentials/twilio",
+			expectedStatusCode: http.StatusNoContent,
+			expectedTwilio:     config.Twilio.Token,
+		},
+		{
+			name:               "revoking missing credential should not be found",
+			method:             http.MethodDelete,
+			url:                "/admin/v1/credentials/twilio",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:   "destination with invalid address should be rejected",
+			method: http.MethodPost,
+			url:    "/admin/v1/destinations",
+			requestBody: &internal.DestinationRequestBody{
+				Name: "oncall", Channel: "sms", Address: "not-a-number",
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "created destination should be available",
+			method: http.MethodPost,
+			url:    "/admin/v1/destinations",
+			requestBody: &internal.DestinationRequestBody{
+				Name: "ops", Channel: "slack", Address: slack.URL + "/services/secret",
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "notification addressed to destination should be sent to it",
+			method:             http.MethodPost,
+			url:                "/api/v1/slack",
+			requestBody:        &internal.SlackRequestBody{Message: "Hello", Destination: "ops"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "notification addressed to destination of another channel should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/sms",
+			requestBody:        &internal.SMSRequestBody{Message: "Hello", Destination: "ops"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "revoked destination should be removed",
+			method:             http.MethodDelete,
+			url:                "/admin/v1/destinations/ops",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "notification addressed to revoked destination should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/slack",
+			requestBody:        &internal.SlackRequestBody{Message: "Hello", Destination: "ops"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		var body bytes.Buffer
+		if tc.requestBody != nil {
+			if err := json.NewEncoder(&body).Encode(tc.requestBody); err != nil {
+				t.Fatalf("failed to marshal request: %v", err)
+			}
+		}
+
+		req := httptest.NewRequest(tc.method, tc.url, &body)
+
+		token := "admin-token"
+		if tc.token != "" {
+			token = tc.token
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v (%s)",
+				tc.name, tc.expectedStatusCode, res.Result().StatusCode, res.Body.String())
+		}
+
+		if tc.expectedTwilio != "" && applied.Load().Twilio.Token != tc.expectedTwilio {
+			t.Fatalf("%s: Expected applied Twilio token %q, got: %q",
+				tc.name, tc.expectedTwilio, applied.Load().Twilio.Token)
+		}
+
+		if strings.Contains(res.Body.String(), "secret") || strings.Contains(res.Body.String(), "twilio-token") {
+			t.Fatalf("%s: Expected secrets to be masked, got: %s", tc.name, res.Body.String())
+		}
+	}
+
+	if slackCalls.Load() != 1 {
+		t.Fatalf("Expected one notification sent to the destination, got: %d", slackCalls.Load())
+	}
+
+	// Loading, creating, rotating and revoking the credential, changes of destinations apply nothing.
+	if applies.Load() != 4 {
+		t.Fatalf("Expected configuration applied on changes of credentials only, got %d applies", applies.Load())
+	}
+}
+
+func TestAdminTenants(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Admin = newAdminConfig(t)
+
+	store, err := internal.NewAdminStore(config.Admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//nolint: errcheck
+	defer store.Close()
+
+	tenants := internal.NewTenants(internal.TenantStoreFunc(
+		func(ctx context.Context, base *internal.Config) ([]internal.TenantConfig, error) {
+			tenant, err := internal.NewTenantConfig(base, "acme", []string{"acme-key"}, nil)
+
+			return []internal.TenantConfig{tenant}, err
+		},
+	))
+
+	var applied atomic.Pointer[internal.Config]
+
+	admin := internal.NewAdmin(store)
+	admin.WithTenants(tenants)
+	admin.OnApply(func(c *internal.Config) { applied.Store(c) })
+
+	ctx := context.Background()
+
+	if err := admin.Reload(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tenants.Reload(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = admin.CreateCredential(ctx, internal.Credential{
+		Provider: "twilio", Tenant: "acme", Values: map[string]string{"twilio.token": "acme-twilio-token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if acme, _ := tenants.Tenant("acme"); acme.Config.Twilio.Token != "acme-twilio-token" {
+		t.Fatalf("Expected credential to be applied to the tenant, got: %q", acme.Config.Twilio.Token)
+	}
+
+	if applied.Load().Twilio.Token != config.Twilio.Token {
+		t.Fatalf("Expected credential of the tenant not to be applied to the deployment, got: %q",
+			applied.Load().Twilio.Token)
+	}
+
+	if err := tenants.Reload(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if acme, _ := tenants.Tenant("acme"); acme.Config.Twilio.Token != "acme-twilio-token" {
+		t.Fatalf("Expected credential to survive reload of the tenants, got: %q", acme.Config.Twilio.Token)
+	}
+
+	_, err = admin.CreateCredential(ctx, internal.Credential{
+		Provider: "twilio", Tenant: "unknown", Values: map[string]string{"twilio.token": "token"},
+	})
+	if !errors.Is(err, internal.ErrInvalidRequest) {
+		t.Fatalf("Expected error %v, got: %v", internal.ErrInvalidRequest, err)
+	}
+
+	for _, tenant := range []string{"", "acme"} {
+		d := internal.Destination{Name: "oncall", Channel: "sms", Tenant: tenant, Address: "+35988357997"}
+		if _, err := admin.CreateDestination(ctx, d); err != nil {
+			t.Fatalf("Expected destinations of different tenants to share the name, got: %v", err)
+		}
+	}
+
+	d := internal.Destination{Name: "oncall", Channel: "sms", Tenant: "unknown", Address: "+35988357997"}
+	if _, err := admin.CreateDestination(ctx, d); !errors.Is(err, internal.ErrInvalidRequest) {
+		t.Fatalf("Expected error %v, got: %v", internal.ErrInvalidRequest, err)
+	}
+
+	if err := admin.RevokeDestination(ctx, "acme", "oncall"); err != nil {
+		t.Fatal(err)
+	}
+
+	destinations, err := admin.Destinations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(destinations) != 1 || destinations[0].Tenant != "" {
+		t.Fatalf("Expected only the destination of the tenant to be revoked, got: %+v", destinations)
+	}
+
+	if err := admin.RevokeCredential(ctx, "acme", "twilio"); err != nil {
+		t.Fatal(err)
+	}
+
+	if acme, _ := tenants.Tenant("acme"); acme.Config.Twilio.Token != config.Twilio.Token {
+		t.Fatalf("Expected revoked credential of the tenant to fall back to configuration, got: %q",
+			acme.Config.Twilio.Token)
+	}
+}
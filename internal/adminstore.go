@@ -0,0 +1,368 @@
+package internal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	// Pure Go SQLite driver, the service is built without cgo.
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const _adminSchema = `
+CREATE TABLE IF NOT EXISTS credentials (
+	tenant     TEXT NOT NULL,
+	provider   TEXT NOT NULL,
+	secret     BLOB NOT NULL,
+	version    INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	rotated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (tenant, provider)
+);
+
+CREATE TABLE IF NOT EXISTS destinations (
+	tenant     TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	channel    TEXT NOT NULL,
+	secret     BLOB NOT NULL,
+	version    INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	rotated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (tenant, name)
+);
+`
+
+var (
+	// ErrNotFound is returned when the destination or credential doesn't exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrAlreadyExists is returned when creating a destination or credential which already exists.
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// Credential holds the credentials of a provider managed through the admin API, overriding the ones of the
+// configuration of the deployment or, when Tenant is set, of the tenant. Values are keyed like in the configuration
+// file, e.g. twilio.token.
+type Credential struct {
+	Provider  string            `json:"provider"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Values    map[string]string `json:"values"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	RotatedAt time.Time         `json:"rotated_at"`
+}
+
+// Destination is a named recipient of notifications managed through the admin API - a Slack webhook URL, phone
+// number or email address clients send to by name. Destinations of a tenant can only be used by the tenant, names
+// are unique per tenant.
+type Destination struct {
+	Name      string    `json:"name"`
+	Channel   string    `json:"channel"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Address   string    `json:"address"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// secretAddress reports whether the address of the destination is a URL, which may hold secrets, e.g. the token
+// of a Slack webhook.
+func (d Destination) secretAddress() bool {
+	switch d.Channel {
+	case _slackChannel, _webhookChannel, _teamsChannel, _discordChannel, _chatChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+// AdminStore persists credentials and destinations in SQLite. Credential values and destination addresses are
+// encrypted with AES-GCM, bound to the row they belong to.
+//
+// AdminStore is safe for concurrent use by multiple goroutines.
+type AdminStore struct {
+	db   *sql.DB
+	aead cipher.AEAD
+}
+
+// NewAdminStore is a constructor function for AdminStore, the database is created when missing.
+func NewAdminStore(config AdminConfig) (*AdminStore, error) {
+	key, err := base64.StdEncoding.DecodeString(config.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes encoded as base64")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", config.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin database: %v", err)
+	}
+
+	// SQLite allows a single writer, sharing one connection avoids "database is locked" errors.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(_adminSchema); err != nil {
+		//nolint: errcheck
+		db.Close()
+
+		return nil, fmt.Errorf("failed to create admin database schema: %v", err)
+	}
+
+	return &AdminStore{db: db, aead: aead}, nil
+}
+
+// Close closes the database.
+func (s *AdminStore) Close() error {
+	return s.db.Close()
+}
+
+// Check reports whether the database is reachable.
+func (s *AdminStore) Check(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("admin database is unreachable: %v", err)
+	}
+
+	return nil
+}
+
+// CreateCredential stores the credentials of a provider.
+func (s *AdminStore) CreateCredential(ctx context.Context, c Credential) (Credential, error) {
+	secret, err := s.encrypt(c.Values, "credentials", c.Tenant, c.Provider)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	c.Version = 1
+	c.CreatedAt = time.Now().UTC()
+	c.RotatedAt = c.CreatedAt
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO credentials (tenant, provider, secret, version, created_at, rotated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.Tenant, c.Provider, secret, c.Version, c.CreatedAt, c.RotatedAt,
+	)
+	if err != nil {
+		return Credential{}, storeError(err, "credential")
+	}
+
+	return c, nil
+}
+
+// Credentials lists the stored credentials, decrypted.
+func (s *AdminStore) Credentials(ctx context.Context) ([]Credential, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT tenant, provider, secret, version, created_at, rotated_at FROM credentials ORDER BY tenant, provider`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %v", err)
+	}
+
+	//nolint: errcheck
+	defer rows.Close()
+
+	var credentials []Credential
+
+	for rows.Next() {
+		var (
+			c      Credential
+			secret []byte
+		)
+
+		if err := rows.Scan(&c.Tenant, &c.Provider, &secret, &c.Version, &c.CreatedAt, &c.RotatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read credential: %v", err)
+		}
+
+		if err := s.decrypt(secret, "credentials", c.Tenant, c.Provider, &c.Values); err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, c)
+	}
+
+	return credentials, rows.Err()
+}
+
+// RotateCredential replaces the values of the credentials of the provider of the tenant, empty for the deployment.
+func (s *AdminStore) RotateCredential(ctx context.Context, tenant, provider string, values map[string]string) error {
+	secret, err := s.encrypt(values, "credentials", tenant, provider)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE credentials SET secret = ?, version = version + 1, rotated_at = ? WHERE tenant = ? AND provider = ?`,
+		secret, time.Now().UTC(), tenant, provider,
+	)
+
+	return affectedOne(res, err, "credential")
+}
+
+// RevokeCredential deletes the credentials of the provider of the tenant, empty for the deployment.
+func (s *AdminStore) RevokeCredential(ctx context.Context, tenant, provider string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM credentials WHERE tenant = ? AND provider = ?`, tenant, provider)
+
+	return affectedOne(res, err, "credential")
+}
+
+// CreateDestination stores the destination.
+func (s *AdminStore) CreateDestination(ctx context.Context, d Destination) (Destination, error) {
+	secret, err := s.encrypt(d.Address, "destinations", d.Tenant, d.Name)
+	if err != nil {
+		return Destination{}, err
+	}
+
+	d.Version = 1
+	d.CreatedAt = time.Now().UTC()
+	d.RotatedAt = d.CreatedAt
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO destinations (tenant, name, channel, secret, version, created_at, rotated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.Tenant, d.Name, d.Channel, secret, d.Version, d.CreatedAt, d.RotatedAt,
+	)
+	if err != nil {
+		return Destination{}, storeError(err, "destination")
+	}
+
+	return d, nil
+}
+
+// Destinations lists the stored destinations, decrypted.
+func (s *AdminStore) Destinations(ctx context.Context) ([]Destination, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT tenant, name, channel, secret, version, created_at, rotated_at FROM destinations ORDER BY tenant, name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destinations: %v", err)
+	}
+
+	//nolint: errcheck
+	defer rows.Close()
+
+	var destinations []Destination
+
+	for rows.Next() {
+		var (
+			d      Destination
+			secret []byte
+		)
+
+		if err := rows.Scan(&d.Tenant, &d.Name, &d.Channel, &secret, &d.Version, &d.CreatedAt, &d.RotatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read destination: %v", err)
+		}
+
+		if err := s.decrypt(secret, "destinations", d.Tenant, d.Name, &d.Address); err != nil {
+			return nil, err
+		}
+
+		destinations = append(destinations, d)
+	}
+
+	return destinations, rows.Err()
+}
+
+// RotateDestination replaces the address of the destination of the tenant, empty for the deployment.
+func (s *AdminStore) RotateDestination(ctx context.Context, tenant, name, address string) error {
+	secret, err := s.encrypt(address, "destinations", tenant, name)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE destinations SET secret = ?, version = version + 1, rotated_at = ? WHERE tenant = ? AND name = ?`,
+		secret, time.Now().UTC(), tenant, name,
+	)
+
+	return affectedOne(res, err, "destination")
+}
+
+// RevokeDestination deletes the destination of the tenant, empty for the deployment.
+func (s *AdminStore) RevokeDestination(ctx context.Context, tenant, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM destinations WHERE tenant = ? AND name = ?`, tenant, name)
+
+	return affectedOne(res, err, "destination")
+}
+
+// encrypt seals the JSON encoded value, binding it to the row so that it can't be moved to another one.
+func (s *AdminStore) encrypt(v any, table, tenant, id string) ([]byte, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secret: %v", err)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	return s.aead.Seal(nonce, nonce, plaintext, rowData(table, tenant, id)), nil
+}
+
+func (s *AdminStore) decrypt(secret []byte, table, tenant, id string, v any) error {
+	row := rowData(table, tenant, id)
+
+	if len(secret) < s.aead.NonceSize() {
+		return fmt.Errorf("failed to decrypt %s: ciphertext too short", row)
+	}
+
+	nonce, ciphertext := secret[:s.aead.NonceSize()], secret[s.aead.NonceSize():]
+
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, row)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %v", row, err)
+	}
+
+	if err := json.Unmarshal(plaintext, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %v", row, err)
+	}
+
+	return nil
+}
+
+// rowData is the additional data secrets are bound to, identifying their row.
+func rowData(table, tenant, id string) []byte {
+	return []byte(table + "/" + tenant + "/" + id)
+}
+
+func storeError(err error, kind string) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return fmt.Errorf("%s %w", kind, ErrAlreadyExists)
+	}
+
+	return fmt.Errorf("failed to store %s: %v", kind, err)
+}
+
+func affectedOne(res sql.Result, err error, kind string) error {
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", kind, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", kind, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s %w", kind, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// _apnsTokenLifetime is how long provider tokens are reused. APNs rejects tokens older than an hour and refreshing
+// them more often than every 20 minutes.
+const _apnsTokenLifetime = 50 * time.Minute
+
+// parseAPNSKey parses the .p8 signing key of APNs.
+func parseAPNSKey(s string) (*ecdsa.PrivateKey, error) {
+	signer, err := parsePrivateKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := signer.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("APNs key is not an ECDSA key")
+	}
+
+	return key, nil
+}
+
+// APNS holds Apple Push Notification service related configuration for sending push notifications with token based
+// authentication. The default transport negotiates HTTP/2 with APNs, as it requires.
+type APNS struct {
+	baseURL  string
+	keyID    string
+	teamID   string
+	topic    string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+	throttle *Throttle
+	token    *cachedToken
+}
+
+func newAPNS(config APNSConfig) *APNS {
+	// The configuration is validated, the key is known to parse.
+	key, _ := parseAPNSKey(config.Key)
+
+	return &APNS{
+		baseURL: config.BaseURL,
+		keyID:   config.KeyID,
+		teamID:  config.TeamID,
+		topic:   config.Topic,
+		key:     key,
+		client:  newProviderClient(),
+		token:   &cachedToken{},
+	}
+}
+
+type apnsAlert struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type apnsPayload struct {
+	Alert            *apnsAlert `json:"alert,omitempty"`
+	Sound            string     `json:"sound,omitempty"`
+	ContentAvailable int        `json:"content-available,omitempty"`
+}
+
+// newAPNSPayload maps the notification to the payload of APNs, data is sent next to the aps dictionary. Notifications
+// without title and body are sent as background notifications.
+func newAPNSPayload(body *PushRequestBody) map[string]any {
+	payload := make(map[string]any, len(body.Data)+1)
+	for k, v := range body.Data {
+		payload[k] = v
+	}
+
+	if body.Title == "" && body.Body == "" {
+		payload["aps"] = apnsPayload{ContentAvailable: 1}
+	} else {
+		payload["aps"] = apnsPayload{Alert: &apnsAlert{Title: body.Title, Body: body.Body}, Sound: "default"}
+	}
+
+	return payload
+}
+
+// NotifyAPNS sends push notification to the device token through the Apple Push Notification service.
+func (s *Service) NotifyAPNS(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_apnsChannel, _apnsProvider, err) }()
+
+	apns := s.providers.Load().apns
+	if apns == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _apnsChannel)
+	}
+
+	body := msg.(*PushRequestBody)
+
+	payload, err := json.Marshal(newAPNSPayload(body))
+	if err != nil {
+		return fmt.Errorf("failed to marshal APNs payload: %v", err)
+	}
+
+	release, err := apns.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule APNs notification: %w", err)
+	}
+	defer release()
+
+	token, err := apns.token.get(ctx, apns.issueToken)
+	if err != nil {
+		return fmt.Errorf("failed to authorize APNs notification: %w", err)
+	}
+
+	u := strings.TrimSuffix(apns.baseURL, "/") + "/3/device/" + url.PathEscape(body.Token)
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", apns.topic)
+
+	// Background notifications must be sent with normal priority.
+	pushType, priority := "alert", "10"
+	if body.Priority == _pushPriorityNormal {
+		priority = "5"
+	}
+
+	if body.Title == "" && body.Body == "" {
+		pushType, priority = "background", "5"
+	}
+
+	req.Header.Set("apns-push-type", pushType)
+	req.Header.Set("apns-priority", priority)
+
+	if body.TTL != nil {
+		expiration := int64(0)
+		if *body.TTL > 0 {
+			expiration = time.Now().Add(time.Duration(*body.TTL) * time.Second).Unix()
+		}
+
+		req.Header.Set("apns-expiration", strconv.FormatInt(expiration, 10))
+	}
+
+	err = sendProviderRequest(ctx, apns.client, req, _apnsChannel, _apnsProvider,
+		func(resp *http.Response, data []byte) error {
+			return apns.checkResponse(token, resp, data)
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send APNs notification: %w", err)
+	}
+
+	LoggerFromContext(ctx).Debug("APNs notification sent")
+
+	return nil
+}
+
+// checkResponse checks the response of APNs. Unregistered and invalid device tokens are reported as
+// ErrUnregisteredToken, expired provider tokens are dropped so that the retry issues a new one.
+func (a *APNS) checkResponse(token string, resp *http.Response, data []byte) error {
+	err := responseStatusError(resp)
+	if err == nil {
+		return nil
+	}
+
+	var res struct {
+		Reason string `json:"reason"`
+	}
+
+	//nolint: errcheck
+	json.Unmarshal(data, &res)
+
+	switch {
+	case resp.StatusCode == http.StatusGone || res.Reason == "BadDeviceToken":
+		return Permanent(fmt.Errorf("%w: %s", ErrUnregisteredToken, res.Reason))
+	case res.Reason == "ExpiredProviderToken":
+		a.token.invalidate(token)
+
+		return errors.New("provider token expired")
+	case res.Reason != "":
+		return fmt.Errorf("%w: %s", err, res.Reason)
+	default:
+		return err
+	}
+}
+
+// issueToken signs a provider token with the key.
+func (a *APNS) issueToken(context.Context) (string, time.Time, error) {
+	now := time.Now()
+
+	token, err := signJWT(
+		map[string]string{"alg": "ES256", "kid": a.keyID},
+		map[string]any{"iss": a.teamID, "iat": now.Unix()},
+		a.key,
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, now.Add(_apnsTokenLifetime), nil
+}
+
+// checkAPNS verifies APNs is reachable, provider tokens can only be verified by sending a notification.
+func (s *Service) checkAPNS(ctx context.Context) error {
+	apns := s.providers.Load().apns
+
+	return checkURL(ctx, apns.client, apns.baseURL)
+}
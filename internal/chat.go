@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownChatEndpoint is returned when a notification names an endpoint which is not configured.
+var ErrUnknownChatEndpoint = errors.New("unknown chat endpoint")
+
+// Chat holds configuration for sending notifications to Slack compatible incoming webhooks of self-hosted chats,
+// i.e. Mattermost and Rocket.Chat.
+type Chat struct {
+	webHookURL string
+	endpoints  map[string]string
+	username   string
+	iconURL    string
+	client     *http.Client
+	throttle   *Throttle
+}
+
+func newChat(config ChatConfig) *Chat {
+	// The configuration is validated, endpoints are known to parse.
+	endpoints, _ := parseChatEndpoints(config.Endpoints)
+
+	return &Chat{
+		webHookURL: config.WebHookURL,
+		endpoints:  endpoints,
+		username:   config.Username,
+		iconURL:    config.IconURL,
+		client:     newProviderClient(),
+	}
+}
+
+// chatErrorResponse is the body of failed requests, Mattermost reports the error in the message and Rocket.Chat in
+// the error, together with success set to false.
+type chatErrorResponse struct {
+	Success *bool  `json:"success"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// newChatMessage maps the notification to a Slack message, the username and icon of the notification take precedence
+// over the configured ones.
+func (c *Chat) newChatMessage(body *ChatRequestBody) SlackMessage {
+	msg := SlackMessage{
+		Text:        body.Text,
+		Channel:     body.Channel,
+		Username:    body.Username,
+		IconURL:     body.IconURL,
+		IconEmoji:   body.IconEmoji,
+		Attachments: body.Attachments,
+		Props:       body.Props,
+	}
+
+	if msg.Username == "" {
+		msg.Username = c.username
+	}
+
+	if msg.IconURL == "" && msg.IconEmoji == "" {
+		msg.IconURL = c.iconURL
+	}
+
+	return msg
+}
+
+// NotifyChat sends notification to the Mattermost or Rocket.Chat incoming webhook of the destination, the named
+// endpoint or the configured one.
+func (s *Service) NotifyChat(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_chatChannel, _chatProvider, err) }()
+
+	chat := s.providers.Load().chat
+	if chat == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _chatChannel)
+	}
+
+	body := msg.(*ChatRequestBody)
+
+	webHookURL, err := chat.target(ctx, body.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(chat.newChatMessage(body))
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat message: %v", err)
+	}
+
+	release, err := chat.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule chat notification: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodPost, webHookURL, bytes.NewReader(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), webHookURL)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	err = sendProviderRequest(ctx, chat.client, req, _chatChannel, _chatProvider, checkChatResponse)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send chat notification: %w", err), webHookURL)
+	}
+
+	LoggerFromContext(ctx).Debug("chat notification sent", "endpoint", body.Endpoint)
+
+	return nil
+}
+
+// target resolves the webhook the notification is sent to: the one of its destination, of the named endpoint or the
+// configured one.
+func (c *Chat) target(ctx context.Context, endpoint string) (string, error) {
+	if u := destinationURLFromContext(ctx); u != "" {
+		return u, nil
+	}
+
+	if endpoint == "" {
+		if c.webHookURL == "" {
+			return "", Permanent(fmt.Errorf("%w: no endpoint named and no default webhook", ErrUnknownChatEndpoint))
+		}
+
+		return c.webHookURL, nil
+	}
+
+	u, ok := c.endpoints[endpoint]
+	if !ok {
+		return "", Permanent(fmt.Errorf("%w: %s", ErrUnknownChatEndpoint, endpoint))
+	}
+
+	return u, nil
+}
+
+// checkChatResponse checks the response of the webhook. Mattermost answers 200 with ok, Rocket.Chat with success set
+// to true, failures carry the error in the body. Rate limited messages are retried after the Retry-After header.
+func checkChatResponse(resp *http.Response, body []byte) error {
+	var res chatErrorResponse
+
+	//nolint: errcheck
+	json.Unmarshal(body, &res)
+
+	reason := res.Message
+	if res.Error != "" {
+		reason = res.Error
+	}
+
+	if err := responseStatusError(resp); err != nil {
+		if reason != "" {
+			err = fmt.Errorf("%w: %s", err, reason)
+		}
+
+		if after, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && after > 0 {
+			return RetryAfter(err, time.Duration(after)*time.Second)
+		}
+
+		return err
+	}
+
+	if res.Success != nil && !*res.Success {
+		return Permanent(fmt.Errorf("message rejected: %s", reason))
+	}
+
+	return nil
+}
+
+// parseChatEndpoints parses comma separated name=URL pairs of named webhooks.
+func parseChatEndpoints(s string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.New("endpoint is not a name=URL pair")
+		}
+
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !destinationNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid endpoint name %q", name)
+		}
+
+		// The URL holds the secret of the webhook, it is left out of errors.
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("endpoint %q has invalid URL", name)
+		}
+
+		endpoints[name] = value
+	}
+
+	return endpoints, nil
+}
+
+// checkChat verifies the configured webhooks are reachable.
+func (s *Service) checkChat(ctx context.Context) error {
+	chat := s.providers.Load().chat
+
+	if chat.webHookURL != "" {
+		if err := checkURL(ctx, chat.client, chat.webHookURL); err != nil {
+			return err
+		}
+	}
+
+	for name, u := range chat.endpoints {
+		if err := checkURL(ctx, chat.client, u); err != nil {
+			return fmt.Errorf("endpoint %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// chatServer fakes the incoming webhooks of Mattermost and Rocket.Chat, recording the messages posted to them.
+type chatServer struct {
+	mu       sync.Mutex
+	messages map[string][]internal.SlackMessage
+}
+
+func (s *chatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var msg internal.SlackMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	s.messages[r.URL.Path] = append(s.messages[r.URL.Path], msg)
+	s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/hooks/mattermost" && msg.Channel == "missing":
+		w.WriteHeader(http.StatusBadRequest)
+		//nolint: errcheck
+		w.Write([]byte(`{"id": "web.incoming_webhook.channel.app_error", "message": "Couldn't find the channel.",
+			"status_code": 400}`))
+	case r.URL.Path == "/hooks/mattermost":
+		w.Write([]byte("ok")) //nolint: errcheck
+	case msg.Channel == "missing":
+		//nolint: errcheck
+		w.Write([]byte(`{"success": false, "error": "invalid-channel"}`))
+	default:
+		w.Write([]byte(`{"success": true}`)) //nolint: errcheck
+	}
+}
+
+func (s *chatServer) sent(path string) []internal.SlackMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.messages[path]
+}
+
+func TestChatNotification(t *testing.T) {
+	t.Parallel()
+
+	chat := &chatServer{messages: make(map[string][]internal.SlackMessage)}
+
+	server := httptest.NewServer(chat)
+	defer server.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Chat = internal.ChatConfig{
+			Enabled:    true,
+			WebHookURL: server.URL + "/hooks/mattermost",
+			Endpoints:  "rocket=" + server.URL + "/hooks/rocket/token",
+			Username:   "Notifier",
+		}
+		config.Retry.MaxRetries = 2
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	attachment := internal.SlackAttachment{
+		Color: "#ff0000", Title: "CPU usage", Fields: []internal.SlackAttachmentField{{Title: "Host", Value: "web-1"}},
+	}
+
+	type test struct {
+		name               string
+		requestBody        any
+		expectedStatusCode int
+		expectedResponse   string
+	}
+
+	tests := []test{
+		{
+			name: "message with props should be posted to the default webhook",
+			requestBody: &internal.ChatRequestBody{
+				Text: "Deploy finished", Channel: "town-square", Props: map[string]any{"card": "Version 1.2.3"},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "message with attachments should be posted to the named endpoint",
+			requestBody: &internal.ChatRequestBody{
+				Attachments: []internal.SlackAttachment{attachment}, Endpoint: "rocket", Username: "Alerts",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "message rejected by Mattermost should report its error",
+			requestBody:        &internal.ChatRequestBody{Text: "Hello", Channel: "missing"},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedResponse:   "Couldn't find the channel.",
+		},
+		{
+			name:               "message rejected by Rocket.Chat should report its error",
+			requestBody:        &internal.ChatRequestBody{Text: "Hello", Channel: "missing", Endpoint: "rocket"},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedResponse:   "invalid-channel",
+		},
+		{
+			name:               "message to an unknown endpoint should be rejected",
+			requestBody:        &internal.ChatRequestBody{Text: "Hello", Endpoint: "unknown"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   internal.ErrUnknownChatEndpoint.Error(),
+		},
+		{
+			name:               "message without text and attachments should be rejected",
+			requestBody:        &internal.ChatRequestBody{Channel: "town-square"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		if !strings.Contains(res.Body.String(), tc.expectedResponse) {
+			t.Fatalf("%s: Expected response containing %s, got: %s", tc.name, tc.expectedResponse, res.Body.String())
+		}
+	}
+
+	// Rejected messages are not retried.
+	mattermost, rocket := chat.sent("/hooks/mattermost"), chat.sent("/hooks/rocket/token")
+	if len(mattermost) != 2 || len(rocket) != 2 {
+		t.Fatalf("Expected 2 messages posted to every webhook, got: %d and %d", len(mattermost), len(rocket))
+	}
+
+	if mattermost[0].Text != "Deploy finished" || mattermost[0].Channel != "town-square" ||
+		mattermost[0].Username != "Notifier" || mattermost[0].Props["card"] != "Version 1.2.3" {
+		t.Fatalf("Expected message with channel override and props, got: %+v", mattermost[0])
+	}
+
+	if rocket[0].Username != "Alerts" || len(rocket[0].Attachments) != 1 ||
+		rocket[0].Attachments[0].Title != "CPU usage" || rocket[0].Attachments[0].Fields[0].Value != "web-1" {
+		t.Fatalf("Expected message with own username and attachment, got: %+v", rocket[0])
+	}
+}
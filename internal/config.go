@@ -1,31 +1,68 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/joeshaw/envdecode"
 )
 
 // Config holds the configuration for the program.
+//
+// Fields are read from the variable of their env tag and from the configuration file, by their snake cased name.
+// Fields tagged as secret may hold references to secrets, e.g. file:///run/secrets/twilio_token, and are
+// redacted when the configuration is printed or logged, changes of fields tagged with
+// reload:"restart" are only applied on restart. Configuration of a channel is only required when it is enabled.
+// Fields tagged with tenant:"true" can be set per tenant.
 type Config struct {
-	Server          ServerConfig       `env:""`
-	SlackWebHookURL string             `env:"SLACK_WEB_HOOK_URL" validate:"required"`
+	Server       ServerConfig `env:"" reload:"restart"`
+	SlackEnabled bool         `env:"SLACK_ENABLED,default=true" reload:"restart" tenant:"true"`
+	//nolint: lll
+	SlackWebHookURL string             `env:"SLACK_WEB_HOOK_URL" validate:"required_if=SlackEnabled true" secret:"true" tenant:"true"`
 	Retry           RequestRetryConfig `env:""`
-	Twilio          TwilioConfig       `env:""`
-	Mail            MailConfig         `env:""`
+	Twilio          TwilioConfig       `env:"" tenant:"true"`
+	Mail            MailConfig         `env:"" tenant:"true"`
+	Webhook         WebhookConfig      `env:"" tenant:"true"`
+	Teams           TeamsConfig        `env:"" tenant:"true"`
+	Discord         DiscordConfig      `env:"" tenant:"true"`
+	Telegram        TelegramConfig     `env:"" tenant:"true"`
+	Chat            ChatConfig         `env:"" tenant:"true"`
+	Matrix          MatrixConfig       `env:"" tenant:"true"`
+	FCM             FCMConfig          `env:"" tenant:"true"`
+	APNS            APNSConfig         `env:"" tenant:"true"`
+	WebPush         WebPushConfig      `env:"" tenant:"true"`
+	RateLimit       RateLimitConfig    `env:"" tenant:"true"`
+	Throttle        ThrottleConfig     `env:"" tenant:"true"`
+	Tracing         TracingConfig      `env:"" reload:"restart"`
+	Log             LogConfig          `env:"" reload:"restart"`
+	Health          HealthConfig       `env:"" reload:"restart"`
+	Reload          ReloadConfig       `env:"" reload:"restart"`
+	Secrets         SecretsConfig      `env:"" reload:"restart"`
+	Admin           AdminConfig        `env:"" reload:"restart"`
+	// Tenants are read from the tenants table of the configuration file, not from the environment.
+	Tenants []TenantConfig `config:"-"`
 }
 
 // NewConfig is a constructor function for Config.
-func NewConfig() (*Config, error) {
-	var config Config
+//
+// Without options the configuration is read from the environment only. Sources override each other in order:
+// defaults, configuration file, environment variables and overrides.
+func NewConfig(opts ...ConfigOption) (*Config, error) {
+	var (
+		config  Config
+		options configOptions
+	)
 
-	err := envdecode.Decode(&config)
-	if err != nil {
-		if err != envdecode.ErrNoTargetFieldsAreSet {
-			return nil, err
-		}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := loadConfig(&config, options); err != nil {
+		return nil, err
 	}
 
 	if err := config.validate(); err != nil {
@@ -35,8 +72,227 @@ func NewConfig() (*Config, error) {
 	return &config, nil
 }
 
+// validate checks the configuration against the validate tags, reporting every invalid field by its key and
+// environment variable. Configurations of the tenants are checked as well.
 func (c *Config) validate() error {
-	return validator.New().Struct(c)
+	errs := []error{validateConfig(c, "")}
+
+	keys := make(map[string]string)
+	for _, t := range c.Tenants {
+		errs = append(errs, t.validate(keys))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateConfig checks the configuration, keyed under key, against the validate tags.
+func validateConfig(c *Config, key string) error {
+	err := newConfigValidator().Struct(c)
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	fields := make(map[string]configField)
+	for _, f := range configFields(reflect.ValueOf(c).Elem(), key, "") {
+		fields[f.namespace] = f
+	}
+
+	errs := make([]error, 0, len(validationErrors))
+
+	for _, fe := range validationErrors {
+		namespace := strings.TrimPrefix(fe.StructNamespace(), "Config.")
+
+		f, ok := fields[namespace]
+		if !ok {
+			errs = append(errs, fe)
+
+			continue
+		}
+
+		// Tenants are only read from the configuration file, there is no variable to point to.
+		name := f.key
+		if f.env != "" && key == "" {
+			name = fmt.Sprintf("%s (%s)", f.key, f.env)
+		}
+
+		errs = append(errs, fmt.Errorf("%s %s", name, validationMessage(fields, namespace, fe)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// channelEnabled reports whether the channel is enabled.
+func (c *Config) channelEnabled(channel string) bool {
+	switch channel {
+	case _slackChannel:
+		return c.SlackEnabled
+	case _smsChannel:
+		return c.Twilio.Enabled
+	case _mailChannel:
+		return c.Mail.Enabled
+	case _webhookChannel:
+		return c.Webhook.Enabled
+	case _teamsChannel:
+		return c.Teams.Enabled
+	case _discordChannel:
+		return c.Discord.Enabled
+	case _telegramChannel:
+		return c.Telegram.Enabled
+	case _chatChannel:
+		return c.Chat.Enabled
+	case _matrixChannel:
+		return c.Matrix.Enabled
+	case _fcmChannel:
+		return c.FCM.Enabled
+	case _apnsChannel:
+		return c.APNS.Enabled
+	case _webPushChannel:
+		return c.WebPush.Enabled
+	case _voiceChannel:
+		return c.Twilio.Voice.Enabled
+	default:
+		return false
+	}
+}
+
+// newConfigValidator creates validator knowing the validations specific to the configuration.
+func newConfigValidator() *validator.Validate {
+	v := validator.New()
+
+	//nolint: errcheck
+	v.RegisterValidation("http_headers", func(fl validator.FieldLevel) bool {
+		_, err := parseHeaders(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("template", func(fl validator.FieldLevel) bool {
+		_, err := parseBodyTemplate(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("chat_endpoints", func(fl validator.FieldLevel) bool {
+		_, err := parseChatEndpoints(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("service_account", func(fl validator.FieldLevel) bool {
+		_, _, err := parseServiceAccount(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("apns_key", func(fl validator.FieldLevel) bool {
+		_, err := parseAPNSKey(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("vapid_key", func(fl validator.FieldLevel) bool {
+		_, _, err := parseVAPIDKey(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("burst", func(fl validator.FieldLevel) bool {
+		// The parameter is the name of the sibling rate, a burst below 1 would reject every request.
+		rate := fl.Parent().FieldByName(fl.Param())
+
+		return !rate.IsValid() || rate.Float() <= 0 || fl.Field().Int() >= 1
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("vapid_subject", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "mailto:") || strings.HasPrefix(fl.Field().String(), "https://")
+	})
+
+	return v
+}
+
+func validationMessage(fields map[string]configField, namespace string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		// The parameter is the name of a sibling field followed by its value.
+		name, value, _ := strings.Cut(fe.Param(), " ")
+		if f, ok := fields[siblingNamespace(namespace, name)]; ok {
+			name = f.key
+		}
+
+		return fmt.Sprintf("is required when %s is %s", name, value)
+	case "required_with":
+		// The parameter is the name of a sibling field.
+		name := fe.Param()
+		if f, ok := fields[siblingNamespace(namespace, name)]; ok {
+			name = f.key
+		}
+
+		return fmt.Sprintf("is required when %s is set", name)
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "url":
+		return "must be a valid URL"
+	case "http_headers":
+		return "must be comma separated Name=value pairs of valid headers"
+	case "template":
+		return "must be a valid text/template"
+	case "chat_endpoints":
+		return "must be comma separated name=URL pairs of webhooks"
+	case "service_account":
+		return "must be the JSON key of a service account"
+	case "apns_key":
+		return "must be a PEM encoded .p8 key"
+	case "vapid_key":
+		return "must be a P-256 private key encoded as URL safe base64"
+	case "vapid_subject":
+		return "must be a mailto: or https:// URL"
+	case "http_url":
+		return "must be a valid HTTP URL"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "burst":
+		// The parameter is the name of the sibling rate.
+		name := fe.Param()
+		if f, ok := fields[siblingNamespace(namespace, name)]; ok {
+			name = f.key
+		}
+
+		return fmt.Sprintf("must be at least 1 when %s is greater than 0", name)
+	case "ltefield":
+		// The parameter is the name of a sibling field.
+		if f, ok := fields[siblingNamespace(namespace, fe.Param())]; ok {
+			return "must be less than or equal to " + f.key
+		}
+
+		return "must be less than or equal to " + fe.Param()
+	default:
+		return fmt.Sprintf("failed on the %q validation", fe.Tag())
+	}
+}
+
+func siblingNamespace(namespace, name string) string {
+	if i := strings.LastIndex(namespace, "."); i >= 0 {
+		return namespace[:i+1] + name
+	}
+
+	return name
 }
 
 // ServerConfig holds the configuration for the HTTP server.
@@ -47,6 +303,13 @@ type ServerConfig struct {
 	WriteTimeout    time.Duration `env:"SERVER_WRITE_TIMEOUT,default=10s"`
 	IdleTimeout     time.Duration `env:"SERVER_IDLE_TIMEOUT,default=120s"`
 	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT,default=20s"`
+	// PreShutdownDelay is how long the server keeps serving after readiness starts failing, so that load balancers
+	// stop routing traffic to it before it stops accepting connections.
+	PreShutdownDelay time.Duration `env:"SERVER_PRE_SHUTDOWN_DELAY,default=0s" validate:"gte=0"`
+	// DrainTimeout is how long in-flight notifications are given to complete after shutdown starts, before
+	// they are interrupted and persisted to PendingDir. It must leave enough of ShutdownTimeout for persisting.
+	DrainTimeout time.Duration `env:"SERVER_DRAIN_TIMEOUT,default=15s" validate:"ltefield=ShutdownTimeout"`
+	PendingDir   string        `env:"SERVER_PENDING_DIR"`
 }
 
 // Addr retrieves the address of the server.
@@ -62,16 +325,291 @@ type RequestRetryConfig struct {
 
 // TwilioConfig holds configuration for Twilio service.
 type TwilioConfig struct {
-	SID    string `env:"TWILIO_SID" validate:"required"`
-	Token  string `env:"TWILIO_TOKEN" validate:"required"`
-	Number string `env:"TWILIO_NUMBER" validate:"required"`
+	// Enabled enables the SMS channel, credentials are only required when it or the voice channel is.
+	Enabled bool   `env:"TWILIO_ENABLED,default=true" reload:"restart"`
+	SID     string `env:"TWILIO_SID" validate:"required_if=Enabled true,required_if=Voice.Enabled true"`
+	Token   string `env:"TWILIO_TOKEN" validate:"required_if=Enabled true,required_if=Voice.Enabled true" secret:"true"`
+	Number  string `env:"TWILIO_NUMBER" validate:"required_if=Enabled true,required_if=Voice.Enabled true"`
+	// WhatsappNumber is the WhatsApp sender of the account, WhatsApp messages are sent from Number when it isn't set.
+	WhatsappNumber string `env:"TWILIO_WHATSAPP_NUMBER" validate:"omitempty,e164"`
+	// APIBaseURL redirects requests to Twilio API, e.g. to a local fake in tests.
+	APIBaseURL string            `env:"TWILIO_API_BASE_URL" validate:"omitempty,url"`
+	Voice      TwilioVoiceConfig `env:""`
+}
+
+// TwilioVoiceConfig holds configuration for the voice channel, calling through the Twilio account.
+type TwilioVoiceConfig struct {
+	Enabled bool `env:"TWILIO_VOICE_ENABLED,default=false" reload:"restart"`
+	// CallbackURL is the public URL of notifier, Twilio reports the status of calls and the pressed keys to it.
+	CallbackURL string `env:"TWILIO_VOICE_CALLBACK_URL" validate:"required_if=Enabled true,omitempty,http_url"`
+	// SpeechVoice and Language are the voice and language messages are read with, see the Say verb of TwiML.
+	SpeechVoice string `env:"TWILIO_VOICE_SPEECH_VOICE,default=alice"`
+	Language    string `env:"TWILIO_VOICE_LANGUAGE,default=en-US"`
+	// RingTimeout is how long the phone rings before the call is given up as not answered.
+	RingTimeout time.Duration `env:"TWILIO_VOICE_RING_TIMEOUT,default=30s" validate:"gte=5s,lte=600s"`
+}
+
+func (t TwilioConfig) whatsappNumber() string {
+	if t.WhatsappNumber == "" {
+		return t.Number
+	}
+
+	return t.WhatsappNumber
+}
+
+func (t TwilioConfig) baseURL() *url.URL {
+	if t.APIBaseURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(t.APIBaseURL)
+	if err != nil {
+		return nil
+	}
+
+	return u
 }
 
 // MailConfig holds configuration for Mail config.
 type MailConfig struct {
-	EmailSender  string `env:"EMAIL_SENDER" validate:"required"`
-	SMTPHost     string `env:"EMAIL_SMTP_HOST" validate:"required"`
-	SMTPPort     int    `env:"EMAIL_SMTP_PORT,default=456" validate:"required"`
-	SMTPUsername string `env:"EMAIL_SMTP_USERNAME" validate:"required"`
-	SMTPPassword string `env:"EMAIL_SMTP_PASSWORD" validate:"required"`
+	// Enabled enables the mail channel, SMTP configuration is only required when it is.
+	Enabled      bool   `env:"EMAIL_ENABLED,default=true" reload:"restart"`
+	EmailSender  string `env:"EMAIL_SENDER" validate:"required_if=Enabled true"`
+	SMTPHost     string `env:"EMAIL_SMTP_HOST" validate:"required_if=Enabled true"`
+	SMTPPort     int    `env:"EMAIL_SMTP_PORT,default=456" validate:"required_if=Enabled true"`
+	SMTPUsername string `env:"EMAIL_SMTP_USERNAME" validate:"required_if=Enabled true"`
+	SMTPPassword string `env:"EMAIL_SMTP_PASSWORD" validate:"required_if=Enabled true" secret:"true"`
+}
+
+// WebhookConfig holds configuration for the generic webhook channel, notifying arbitrary HTTP services.
+type WebhookConfig struct {
+	// Enabled enables the webhook channel, it is disabled by default.
+	Enabled bool `env:"WEBHOOK_ENABLED,default=false" reload:"restart"`
+	// URL receives the notifications which are not sent to a URL of their own or to a destination.
+	URL string `env:"WEBHOOK_URL" validate:"omitempty,url" secret:"true"`
+	// SigningSecret is the key of the HMAC-SHA256 signature of every payload.
+	SigningSecret string `env:"WEBHOOK_SIGNING_SECRET" validate:"required_if=Enabled true" secret:"true"`
+	// Headers are added to every request, as comma separated Name=value pairs.
+	Headers string `env:"WEBHOOK_HEADERS" validate:"omitempty,http_headers"`
+	// BodyTemplate renders the body from the envelope with text/template, the envelope is sent as JSON without it.
+	BodyTemplate string `env:"WEBHOOK_BODY_TEMPLATE" validate:"omitempty,template"`
+	ContentType  string `env:"WEBHOOK_CONTENT_TYPE,default=application/json" validate:"required"`
+	// AllowedHosts are the comma separated hosts notifications may name their own URL on, e.g. hooks.example.com.
+	// Without them notifications can't name their own URL.
+	AllowedHosts string `env:"WEBHOOK_ALLOWED_HOSTS"`
+}
+
+// TeamsConfig holds configuration for the Microsoft Teams channel.
+type TeamsConfig struct {
+	// Enabled enables the Teams channel, it is disabled by default.
+	Enabled bool `env:"TEAMS_ENABLED,default=false" reload:"restart"`
+	// WebHookURL is the URL of an incoming webhook or of a Workflows webhook trigger posting to the channel.
+	WebHookURL string `env:"TEAMS_WEB_HOOK_URL" validate:"required_if=Enabled true" secret:"true"`
+}
+
+// DiscordConfig holds configuration for the Discord channel.
+type DiscordConfig struct {
+	// Enabled enables the Discord channel, it is disabled by default.
+	Enabled bool `env:"DISCORD_ENABLED,default=false" reload:"restart"`
+	// WebHookURL is the URL of the webhook of the Discord channel.
+	WebHookURL string `env:"DISCORD_WEB_HOOK_URL" validate:"required_if=Enabled true" secret:"true"`
+	// Username and AvatarURL override the name and avatar of the webhook, unless the notification overrides them.
+	Username  string `env:"DISCORD_USERNAME" validate:"max=80"`
+	AvatarURL string `env:"DISCORD_AVATAR_URL" validate:"omitempty,http_url"`
+}
+
+// TelegramConfig holds configuration for the Telegram channel.
+type TelegramConfig struct {
+	// Enabled enables the Telegram channel, it is disabled by default.
+	Enabled bool `env:"TELEGRAM_ENABLED,default=false" reload:"restart"`
+	// BotToken is the token of the bot sending the notifications, as issued by @BotFather.
+	BotToken string `env:"TELEGRAM_BOT_TOKEN" validate:"required_if=Enabled true" secret:"true"`
+	// BaseURL redirects requests to the Bot API, e.g. to a local fake in tests.
+	BaseURL string `env:"TELEGRAM_BASE_URL,default=https://api.telegram.org" validate:"required,url"`
+	// ChatID is the chat notifications naming no chat are sent to.
+	ChatID string `env:"TELEGRAM_CHAT_ID"`
+}
+
+// ChatConfig holds configuration for the channel of Mattermost and Rocket.Chat incoming webhooks.
+type ChatConfig struct {
+	// Enabled enables the chat channel, it is disabled by default.
+	Enabled bool `env:"CHAT_ENABLED,default=false" reload:"restart"`
+	// WebHookURL is the webhook notifications naming no endpoint are sent to.
+	WebHookURL string `env:"CHAT_WEB_HOOK_URL" validate:"omitempty,http_url" secret:"true"`
+	// Endpoints are comma separated name=URL pairs of webhooks notifications can name, e.g.
+	// ops=https://mattermost.example.com/hooks/xxx.
+	Endpoints string `env:"CHAT_ENDPOINTS" validate:"omitempty,chat_endpoints" secret:"true"`
+	// Username and IconURL override the name and icon of the webhook, unless the notification overrides them.
+	Username string `env:"CHAT_USERNAME"`
+	IconURL  string `env:"CHAT_ICON_URL" validate:"omitempty,http_url"`
+}
+
+// MatrixConfig holds configuration for the Matrix channel.
+type MatrixConfig struct {
+	// Enabled enables the Matrix channel, it is disabled by default.
+	Enabled bool `env:"MATRIX_ENABLED,default=false" reload:"restart"`
+	// HomeserverURL is the base URL of the client-server API of the homeserver, e.g. https://matrix.example.com.
+	HomeserverURL string `env:"MATRIX_HOMESERVER_URL" validate:"required_if=Enabled true,omitempty,http_url"`
+	// AccessToken is the access token of the user notifications are sent as, the user must have joined the rooms.
+	AccessToken string `env:"MATRIX_ACCESS_TOKEN" validate:"required_if=Enabled true" secret:"true"`
+	// Room is the ID or alias of the room notifications naming no room are sent to, e.g. #alerts:example.com.
+	Room string `env:"MATRIX_ROOM"`
+}
+
+// FCMConfig holds configuration for the Firebase Cloud Messaging channel.
+type FCMConfig struct {
+	// Enabled enables the FCM channel, it is disabled by default.
+	Enabled bool `env:"FCM_ENABLED,default=false" reload:"restart"`
+	// ServiceAccount is the JSON key of the service account notifications are sent as.
+	//nolint: lll
+	ServiceAccount string `env:"FCM_SERVICE_ACCOUNT" validate:"required_if=Enabled true,omitempty,service_account" secret:"true"`
+	// ProjectID overrides the Firebase project of the service account.
+	ProjectID string `env:"FCM_PROJECT_ID"`
+	// BaseURL and TokenURL redirect requests to FCM and to the token endpoint of the service account, e.g. to local
+	// fakes in tests.
+	BaseURL  string `env:"FCM_BASE_URL,default=https://fcm.googleapis.com" validate:"required,http_url"`
+	TokenURL string `env:"FCM_TOKEN_URL" validate:"omitempty,http_url"`
+}
+
+// APNSConfig holds configuration for the Apple Push Notification service channel.
+type APNSConfig struct {
+	// Enabled enables the APNs channel, it is disabled by default.
+	Enabled bool `env:"APNS_ENABLED,default=false" reload:"restart"`
+	// Key is the PEM encoded .p8 signing key, KeyID its ID and TeamID the ID of the team of the developer account.
+	Key    string `env:"APNS_KEY" validate:"required_if=Enabled true,omitempty,apns_key" secret:"true"`
+	KeyID  string `env:"APNS_KEY_ID" validate:"required_if=Enabled true"`
+	TeamID string `env:"APNS_TEAM_ID" validate:"required_if=Enabled true"`
+	// Topic is the bundle ID of the app.
+	Topic string `env:"APNS_TOPIC" validate:"required_if=Enabled true"`
+	// BaseURL is the APNs server, https://api.sandbox.push.apple.com for development builds of the app.
+	BaseURL string `env:"APNS_BASE_URL,default=https://api.push.apple.com" validate:"required,http_url"`
+}
+
+// WebPushConfig holds configuration for the Web Push channel.
+type WebPushConfig struct {
+	// Enabled enables the Web Push channel, it is disabled by default.
+	Enabled bool `env:"WEB_PUSH_ENABLED,default=false" reload:"restart"`
+	// VAPIDPrivateKey identifies the service to push services, browsers subscribe with its public key. Replacing it
+	// invalidates every subscription.
+	//nolint: lll
+	VAPIDPrivateKey string `env:"WEB_PUSH_VAPID_PRIVATE_KEY" validate:"required_if=Enabled true,omitempty,vapid_key" secret:"true"`
+	// Subject is the contact of the operator push services reach out to, e.g. mailto:ops@example.com.
+	Subject string `env:"WEB_PUSH_SUBJECT" validate:"required_if=Enabled true,omitempty,vapid_subject"`
+	// SubscriptionsDir is the directory subscriptions are kept in, they are kept in memory when it is not set.
+	SubscriptionsDir string `env:"WEB_PUSH_SUBSCRIPTIONS_DIR" reload:"restart"`
+	// AllowedHosts are the comma separated hosts of push services, subscriptions may be on them or on their
+	// subdomains. They default to the push services of the major browsers.
+	//nolint: lll
+	AllowedHosts string `env:"WEB_PUSH_ALLOWED_HOSTS,default=fcm.googleapis.com,push.services.mozilla.com,notify.windows.com,push.apple.com"`
+}
+
+// RateLimitConfig holds configuration for rate limiting incoming requests.
+//
+// Rates are in requests per second, a rate of 0 disables the corresponding limit. Bursts of enabled limits must be at
+// least 1.
+type RateLimitConfig struct {
+	ClientRate       float64 `env:"RATE_LIMIT_CLIENT_RATE,default=10" validate:"gte=0"`
+	ClientBurst      int     `env:"RATE_LIMIT_CLIENT_BURST,default=20" validate:"gte=0,burst=ClientRate"`
+	DestinationRate  float64 `env:"RATE_LIMIT_DESTINATION_RATE,default=1" validate:"gte=0"`
+	DestinationBurst int     `env:"RATE_LIMIT_DESTINATION_BURST,default=5" validate:"gte=0,burst=DestinationRate"`
+	ChannelRate      float64 `env:"RATE_LIMIT_CHANNEL_RATE,default=50" validate:"gte=0"`
+	ChannelBurst     int     `env:"RATE_LIMIT_CHANNEL_BURST,default=100" validate:"gte=0,burst=ChannelRate"`
+}
+
+// Rules builds the rate limit rules for a channel, destination is used to key the per-destination limit.
+func (c RateLimitConfig) Rules(channel string, destination RateLimitKeyFunc) []RateLimitRule {
+	candidates := []RateLimitRule{
+		{Name: "client", Limit: RateLimit{Rate: c.ClientRate, Burst: c.ClientBurst}, Key: ClientKey},
+		{
+			Name:  channel + "_destination",
+			Limit: RateLimit{Rate: c.DestinationRate, Burst: c.DestinationBurst},
+			Key:   destination,
+		},
+		{Name: "channel", Limit: RateLimit{Rate: c.ChannelRate, Burst: c.ChannelBurst}, Key: StaticKey(channel)},
+	}
+
+	rules := make([]RateLimitRule, 0, len(candidates))
+
+	for _, rule := range candidates {
+		if rule.Limit.Rate > 0 {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// ThrottleConfig holds configuration for shaping outbound throughput to the providers.
+//
+// Rates are in messages per second and concurrency is the maximum of simultaneous sends, 0 means no limit.
+type ThrottleConfig struct {
+	SlackRate          float64 `env:"THROTTLE_SLACK_RATE,default=1" validate:"gte=0"`
+	SlackConcurrency   int     `env:"THROTTLE_SLACK_CONCURRENCY,default=0" validate:"gte=0"`
+	TwilioRate         float64 `env:"THROTTLE_TWILIO_RATE,default=1" validate:"gte=0"`
+	TwilioConcurrency  int     `env:"THROTTLE_TWILIO_CONCURRENCY,default=0" validate:"gte=0"`
+	MailRate           float64 `env:"THROTTLE_MAIL_RATE,default=5" validate:"gte=0"`
+	MailConcurrency    int     `env:"THROTTLE_MAIL_CONCURRENCY,default=2" validate:"gte=0"`
+	WebhookRate        float64 `env:"THROTTLE_WEBHOOK_RATE,default=10" validate:"gte=0"`
+	WebhookConcurrency int     `env:"THROTTLE_WEBHOOK_CONCURRENCY,default=0" validate:"gte=0"`
+	TeamsRate          float64 `env:"THROTTLE_TEAMS_RATE,default=1" validate:"gte=0"`
+	TeamsConcurrency   int     `env:"THROTTLE_TEAMS_CONCURRENCY,default=0" validate:"gte=0"`
+	DiscordRate        float64 `env:"THROTTLE_DISCORD_RATE,default=1" validate:"gte=0"`
+	DiscordConcurrency int     `env:"THROTTLE_DISCORD_CONCURRENCY,default=0" validate:"gte=0"`
+	// TelegramRate is shared by all chats, the Bot API allows about 30 messages per second to different chats.
+	TelegramRate        float64 `env:"THROTTLE_TELEGRAM_RATE,default=1" validate:"gte=0"`
+	TelegramConcurrency int     `env:"THROTTLE_TELEGRAM_CONCURRENCY,default=0" validate:"gte=0"`
+	ChatRate            float64 `env:"THROTTLE_CHAT_RATE,default=1" validate:"gte=0"`
+	ChatConcurrency     int     `env:"THROTTLE_CHAT_CONCURRENCY,default=0" validate:"gte=0"`
+	MatrixRate          float64 `env:"THROTTLE_MATRIX_RATE,default=1" validate:"gte=0"`
+	MatrixConcurrency   int     `env:"THROTTLE_MATRIX_CONCURRENCY,default=0" validate:"gte=0"`
+	FCMRate             float64 `env:"THROTTLE_FCM_RATE,default=100" validate:"gte=0"`
+	FCMConcurrency      int     `env:"THROTTLE_FCM_CONCURRENCY,default=0" validate:"gte=0"`
+	APNSRate            float64 `env:"THROTTLE_APNS_RATE,default=100" validate:"gte=0"`
+	APNSConcurrency     int     `env:"THROTTLE_APNS_CONCURRENCY,default=0" validate:"gte=0"`
+	WebPushRate         float64 `env:"THROTTLE_WEB_PUSH_RATE,default=100" validate:"gte=0"`
+	WebPushConcurrency  int     `env:"THROTTLE_WEB_PUSH_CONCURRENCY,default=0" validate:"gte=0"`
+	// VoiceRate is the rate calls are placed at, Twilio places one call per second unless the account is raised.
+	VoiceRate        float64 `env:"THROTTLE_VOICE_RATE,default=1" validate:"gte=0"`
+	VoiceConcurrency int     `env:"THROTTLE_VOICE_CONCURRENCY,default=0" validate:"gte=0"`
+}
+
+// TracingConfig holds configuration for OpenTelemetry tracing.
+type TracingConfig struct {
+	Exporter    string  `env:"TRACING_EXPORTER,default=none" validate:"oneof=none stdout otlp"`
+	ServiceName string  `env:"TRACING_SERVICE_NAME,default=notifier" validate:"required"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO,default=1" validate:"gte=0,lte=1"`
+}
+
+// LogConfig holds configuration for the logger.
+type LogConfig struct {
+	Level  string `env:"LOG_LEVEL,default=info" validate:"oneof=debug info warn error"`
+	Format string `env:"LOG_FORMAT,default=json" validate:"oneof=json text"`
+}
+
+// HealthConfig holds configuration for the provider health checks.
+type HealthConfig struct {
+	CheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT,default=5s" validate:"gt=0"`
+	CacheTTL     time.Duration `env:"HEALTH_CACHE_TTL,default=30s"`
+}
+
+// ReloadConfig holds configuration for reloading the configuration without restart.
+type ReloadConfig struct {
+	// WatchInterval is how often the configuration file is checked for changes, 0 disables watching.
+	WatchInterval time.Duration `env:"CONFIG_WATCH_INTERVAL,default=5s" validate:"gte=0"`
+}
+
+// SecretsConfig holds configuration for secrets referenced by the configuration.
+type SecretsConfig struct {
+	// RefreshInterval is how often the configuration is reloaded to pick up rotated secrets, 0 disables refreshing.
+	RefreshInterval time.Duration `env:"SECRETS_REFRESH_INTERVAL,default=0" validate:"gte=0"`
+}
+
+// AdminConfig holds configuration for the admin API managing destinations and provider credentials at runtime.
+type AdminConfig struct {
+	// DBPath is the path of the SQLite database the admin API persists to, the admin API is disabled without it.
+	DBPath string `env:"ADMIN_DB_PATH"`
+	// Token authenticates requests to the admin API, sent as bearer token.
+	Token string `env:"ADMIN_TOKEN" validate:"required_with=DBPath" secret:"true"`
+	// EncryptionKey encrypts the stored secrets, 32 bytes encoded as base64.
+	EncryptionKey string `env:"ADMIN_ENCRYPTION_KEY" validate:"required_with=DBPath" secret:"true"`
 }
@@ -3,7 +3,10 @@ package internal_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kkereziev/notifier/internal"
@@ -28,3 +31,260 @@ func TestConfigValidation(t *testing.T) {
 		t.Fatal("error creating new config: ", err)
 	}
 }
+
+const _testConfigFile = `
+server:
+  host: 127.0.0.1
+  port: 9000
+slack_web_hook_url: http://example.com/secret-path
+twilio:
+  sid: sid
+  token: twilio-token
+  number: "+35988357997"
+mail:
+  email_sender: example@gmail.com
+  smtp_host: smtp.example.com
+  smtp_username: user
+  smtp_password: smtp-password
+log:
+  level: debug
+tracing:
+  service_name: from-file
+  sample_ratio: 0.5
+`
+
+func TestConfigSources(t *testing.T) {
+	// Empty variables are considered unset, neutralizing values loaded by other tests.
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("TRACING_SAMPLE_RATIO", "")
+	t.Setenv("MAX_DELAY", "")
+	t.Setenv("TRACING_SERVICE_NAME", "from-env")
+
+	file := writeConfigFile(t, "config.yaml", _testConfigFile)
+
+	config, err := internal.NewConfig(
+		internal.WithConfigFile(file),
+		internal.WithConfigOverrides(map[string]string{"tracing.sample_ratio": "0.25"}),
+	)
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if config.Retry.Delay != time.Second*2 {
+		t.Fatalf("Expected default to be used, got: %v", config.Retry.Delay)
+	}
+
+	if config.Log.Level != "debug" {
+		t.Fatalf("Expected file to override default, got: %v", config.Log.Level)
+	}
+
+	if config.Tracing.ServiceName != "from-env" {
+		t.Fatalf("Expected environment to override file, got: %v", config.Tracing.ServiceName)
+	}
+
+	if config.Tracing.SampleRatio != 0.25 {
+		t.Fatalf("Expected override to override environment, got: %v", config.Tracing.SampleRatio)
+	}
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("SERVER_DRAIN_TIMEOUT", "")
+
+	toml := `
+slack_web_hook_url = "http://example.com"
+
+[server]
+host = "127.0.0.1"
+port = 9000
+drain_timeout = "1s"
+
+[twilio]
+sid = "sid"
+token = "token"
+number = "+35988357997"
+
+[mail]
+email_sender = "example@gmail.com"
+smtp_host = "smtp.example.com"
+smtp_username = "user"
+smtp_password = "password"
+
+[log]
+level = "warn"
+`
+
+	config, err := internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.toml", toml)))
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if config.Log.Level != "warn" || config.Server.DrainTimeout != time.Second {
+		t.Fatalf("Expected values from TOML file, got: %+v %+v", config.Log, config.Server)
+	}
+
+	_, err = internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.json", "{}")))
+	if err == nil || !strings.Contains(err.Error(), "unsupported config file format") {
+		t.Fatalf("Expected unsupported format error, got: %v", err)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("SERVER_PORT", "")
+	t.Setenv("TRACING_SAMPLE_RATIO", "")
+
+	type test struct {
+		name          string
+		config        string
+		overrides     map[string]string
+		expectedError string
+	}
+
+	tests := []test{
+		{
+			name:          "unknown field should be reported with its path",
+			config:        strings.Replace(_testConfigFile, "sid: sid", "sidd: sid", 1),
+			expectedError: `unknown field "twilio.sidd"`,
+		},
+		{
+			name:          "invalid value should be reported with its path",
+			config:        strings.Replace(_testConfigFile, "port: 9000", "port: abc", 1),
+			expectedError: `server.port: invalid integer "abc"`,
+		},
+		{
+			name:          "table given a value should be reported",
+			config:        _testConfigFile + "retry: 3\n",
+			expectedError: "retry: expected a table",
+		},
+		{
+			name:          "failed validation should be reported with field and variable",
+			config:        strings.Replace(_testConfigFile, "level: debug", "level: verbose", 1),
+			expectedError: "log.level (LOG_LEVEL) must be one of: debug info warn error",
+		},
+		{
+			name:          "invalid custom validation should be reported",
+			config:        _testConfigFile + "webhook:\n  enabled: true\n  signing_secret: s\n  headers: X-Env\n",
+			expectedError: "webhook.headers (WEBHOOK_HEADERS) must be comma separated Name=value pairs of valid headers",
+		},
+		{
+			name:          "invalid named chat endpoints should be reported",
+			config:        _testConfigFile + "chat:\n  enabled: true\n  endpoints: ops=ftp://chat.example.com\n",
+			expectedError: "chat.endpoints (CHAT_ENDPOINTS) must be comma separated name=URL pairs of webhooks",
+		},
+		{
+			name:   "burst of an enabled rate limit below 1 should be reported",
+			config: _testConfigFile + "rate_limit:\n  client_rate: 5\n  client_burst: 0\n",
+			expectedError: "rate_limit.client_burst (RATE_LIMIT_CLIENT_BURST) must be at least 1 when " +
+				"rate_limit.client_rate is greater than 0",
+		},
+		{
+			name:          "invalid flag should be reported",
+			config:        _testConfigFile,
+			overrides:     map[string]string{"tracing.sample_ratio": "abc"},
+			expectedError: `flag -tracing.sample_ratio: invalid number "abc"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			file := writeConfigFile(t, "config.yaml", tc.config)
+
+			_, err := internal.NewConfig(internal.WithConfigFile(file), internal.WithConfigOverrides(tc.overrides))
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("\nExpected error containing: %s\nActual: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestConfigWriteRedacted(t *testing.T) {
+	// Empty variables are considered unset, neutralizing values loaded by other tests.
+	for _, key := range []string{"TRACING_SERVICE_NAME", "TWILIO_TOKEN", "EMAIL_SMTP_PASSWORD", "SLACK_WEB_HOOK_URL"} {
+		t.Setenv(key, "")
+	}
+
+	config, err := internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.yaml", _testConfigFile)))
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	var out strings.Builder
+	if err := config.WriteRedacted(&out); err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	for _, secret := range []string{"secret-path", "twilio-token", "smtp-password"} {
+		if strings.Contains(out.String(), secret) {
+			t.Fatalf("Expected secret %q to be redacted, got:\n%s", secret, out.String())
+		}
+	}
+
+	for _, redacted := range []string{"token: '********'", "smtp_password: '********'"} {
+		if !strings.Contains(out.String(), redacted) {
+			t.Fatalf("Expected %q to be printed, got:\n%s", redacted, out.String())
+		}
+	}
+
+	if !strings.Contains(out.String(), "service_name: from-file") {
+		t.Fatalf("Expected effective configuration to be printed, got:\n%s", out.String())
+	}
+}
+
+func TestConfigDisabledChannels(t *testing.T) {
+	for _, key := range []string{"SLACK_ENABLED", "SLACK_WEB_HOOK_URL", "TWILIO_ENABLED", "TWILIO_SID", "TWILIO_TOKEN"} {
+		t.Setenv(key, "")
+	}
+
+	mailOnly := `
+server:
+  host: 127.0.0.1
+  port: 9000
+slack_enabled: false
+twilio:
+  enabled: false
+mail:
+  email_sender: example@gmail.com
+  smtp_host: smtp.example.com
+  smtp_username: user
+  smtp_password: smtp-password
+`
+
+	config, err := internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.yaml", mailOnly)))
+	if err != nil {
+		t.Fatalf("Expected configuration of disabled channels not to be required, got: %s", err)
+	}
+
+	if config.SlackEnabled || config.Twilio.Enabled || !config.Mail.Enabled {
+		t.Fatalf("Expected only mail channel to be enabled, got: %+v", config)
+	}
+
+	slackEnabled := strings.Replace(mailOnly, "slack_enabled: false", "slack_enabled: true", 1)
+
+	expectedError := "slack_web_hook_url (SLACK_WEB_HOOK_URL) is required when slack_enabled is true"
+
+	_, err = internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.yaml", slackEnabled)))
+	if err == nil || !strings.Contains(err.Error(), expectedError) {
+		t.Fatalf("Expected configuration of enabled channel to be required, got: %v", err)
+	}
+
+	voiceEnabled := strings.Replace(mailOnly, "  enabled: false", "  enabled: false\n  voice:\n    enabled: true", 1)
+
+	expectedError = "twilio.sid (TWILIO_SID) is required when twilio.voice.enabled is true"
+
+	_, err = internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.yaml", voiceEnabled)))
+	if err == nil || !strings.Contains(err.Error(), expectedError) {
+		t.Fatalf("Expected Twilio credentials to be required by voice channel, got: %v", err)
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
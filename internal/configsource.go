@@ -0,0 +1,485 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const _redacted = "********"
+
+var _durationType = reflect.TypeOf(time.Duration(0))
+
+// ConfigOption configures the sources NewConfig loads the configuration from.
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	file            string
+	overrides       map[string]string
+	secretProviders map[string]SecretProvider
+}
+
+// WithConfigFile loads the configuration from a YAML(.yaml, .yml) or TOML(.toml) file. Keys of the file are the
+// snake cased names of the Config fields, e.g. server.port or twilio.sid.
+func WithConfigFile(path string) ConfigOption {
+	return func(o *configOptions) {
+		o.file = path
+	}
+}
+
+// WithConfigOverrides overrides the configuration with values keyed like in the configuration file, see ConfigFlags.
+func WithConfigOverrides(overrides map[string]string) ConfigOption {
+	return func(o *configOptions) {
+		o.overrides = overrides
+	}
+}
+
+// ConfigFlags registers a flag for every configuration field on the flag set, named after the key of the field in
+// the configuration file, e.g. -server.port. The returned overrides are filled in when the flag set is parsed.
+func ConfigFlags(fs *flag.FlagSet) map[string]string {
+	overrides := make(map[string]string)
+
+	for _, f := range configFields(reflect.ValueOf(&Config{}).Elem(), "", "") {
+		usage := "overrides " + f.key
+		if f.env != "" {
+			usage = "overrides " + f.env
+		}
+
+		if f.hasDefault {
+			usage += fmt.Sprintf(" (default %s)", f.def)
+		}
+
+		fs.Var(&overrideFlag{key: f.key, overrides: overrides}, f.key, usage)
+	}
+
+	return overrides
+}
+
+type overrideFlag struct {
+	key       string
+	overrides map[string]string
+}
+
+func (f *overrideFlag) String() string {
+	if f.overrides == nil {
+		return ""
+	}
+
+	return f.overrides[f.key]
+}
+
+func (f *overrideFlag) Set(value string) error {
+	f.overrides[f.key] = value
+
+	return nil
+}
+
+// configField is a leaf field of Config along with the names its sources refer to it by.
+type configField struct {
+	// key is the path of the field in the configuration file and the name of its flag.
+	key string
+	// namespace is the path of the Go field, as reported by the validator.
+	namespace  string
+	env        string
+	def        string
+	hasDefault bool
+	secret     bool
+	// restart tells that changes of the field are only applied on restart, not on reload.
+	restart bool
+	// tenant tells that the field can be set per tenant.
+	tenant bool
+	value  reflect.Value
+}
+
+// configFields flattens the struct into its leaf fields, descending into nested structs.
+func configFields(v reflect.Value, key, namespace string) []configField {
+	var fields []configField
+
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() || sf.Tag.Get("config") == "-" {
+			continue
+		}
+
+		f := configField{
+			key:       joinConfigKey(key, snakeCase(sf.Name)),
+			namespace: joinConfigKey(namespace, sf.Name),
+			secret:    sf.Tag.Get("secret") == "true",
+			restart:   sf.Tag.Get("reload") == "restart",
+			tenant:    sf.Tag.Get("tenant") == "true",
+			value:     v.Field(i),
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			for _, nested := range configFields(f.value, f.key, f.namespace) {
+				nested.restart = nested.restart || f.restart
+				nested.tenant = nested.tenant || f.tenant
+				fields = append(fields, nested)
+			}
+
+			continue
+		}
+
+		name, options, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		f.env = name
+		f.def, f.hasDefault = strings.CutPrefix(options, "default=")
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// loadConfig fills in the configuration from its sources, each overriding the previous one: defaults,
+// configuration file, environment variables and overrides. References held by secret fields are resolved last,
+// tenants are built on top of the result. All invalid values are reported at once.
+func loadConfig(config *Config, o configOptions) error {
+	fields := configFields(reflect.ValueOf(config).Elem(), "", "")
+
+	var (
+		errs         []error
+		tenantValues map[string]map[string]any
+	)
+
+	for _, f := range fields {
+		if f.hasDefault {
+			if err := setConfigValue(f.value, f.def); err != nil {
+				errs = append(errs, fmt.Errorf("default of %s: %v", f.key, err))
+			}
+		}
+	}
+
+	if o.file != "" {
+		values, err := readConfigFile(o.file)
+		if err != nil {
+			return err
+		}
+
+		var tenantErrs []error
+
+		tenantValues, tenantErrs = extractTenantValues(values, o.file)
+		errs = append(errs, tenantErrs...)
+		errs = append(errs, applyConfigValues(fields, values, o.file)...)
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+
+		// Like with envdecode, empty variables are considered unset.
+		if raw := os.Getenv(f.env); raw != "" {
+			if err := setConfigValue(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", f.env, err))
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if raw, ok := o.overrides[f.key]; ok {
+			if err := setConfigValue(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %v", f.key, err))
+			}
+		}
+	}
+
+	providers := defaultSecretProviders()
+
+	for scheme, provider := range o.secretProviders {
+		providers[scheme] = provider
+	}
+
+	errs = append(errs, resolveSecrets(fields, providers)...)
+
+	for _, id := range sortedKeys(tenantValues) {
+		tenant, tenantErrs := buildTenant(config, id, tenantValues[id], o.file, providers)
+
+		config.Tenants = append(config.Tenants, tenant)
+		errs = append(errs, tenantErrs...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile decodes the configuration file into flattened keys, the format is picked by its extension.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var tree map[string]any
+
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, expected .yaml, .yml or .toml", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	values := make(map[string]any)
+	flattenConfig("", tree, values)
+
+	return values, nil
+}
+
+func flattenConfig(prefix string, tree map[string]any, values map[string]any) {
+	for k, v := range tree {
+		key := joinConfigKey(prefix, k)
+
+		if table, ok := v.(map[string]any); ok {
+			flattenConfig(key, table, values)
+
+			continue
+		}
+
+		values[key] = v
+	}
+}
+
+func applyConfigValues(fields []configField, values map[string]any, file string) []error {
+	byKey := make(map[string]configField, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+
+	var errs []error
+
+	for _, key := range sortedKeys(values) {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, unknownConfigKey(fields, key, file))
+
+			continue
+		}
+
+		// Keys without a value, e.g. "host:" in YAML, leave the field as is.
+		if values[key] == nil {
+			continue
+		}
+
+		raw, ok := configScalar(values[key])
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s: expected a single value, got %T", file, key, values[key]))
+
+			continue
+		}
+
+		if err := setConfigValue(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", file, key, err))
+		}
+	}
+
+	return errs
+}
+
+func unknownConfigKey(fields []configField, key, file string) error {
+	for _, f := range fields {
+		if strings.HasPrefix(f.key, key+".") {
+			return fmt.Errorf("%s: %s: expected a table", file, key)
+		}
+	}
+
+	return fmt.Errorf("%s: unknown field %q", file, key)
+}
+
+// configScalar formats a decoded scalar the way it would be written in an environment variable.
+func configScalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool, int, int64, uint64, time.Duration:
+		return fmt.Sprint(v), true
+	default:
+		return "", false
+	}
+}
+
+func setConfigValue(v reflect.Value, raw string) error {
+	if v.Type() == _durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	//nolint: exhaustive
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// WriteRedacted writes the configuration as YAML with secrets masked, in the format of the configuration file.
+func (c *Config) WriteRedacted(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	tables := map[string]*yaml.Node{"": root}
+
+	add := func(key string, v any) error {
+		prefix, name := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			prefix, name = key[:i], key[i+1:]
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode %s: %v", key, err)
+		}
+
+		table := configTable(tables, prefix)
+		table.Content = append(table.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+
+		return nil
+	}
+
+	for _, f := range configFields(reflect.ValueOf(c).Elem(), "", "") {
+		if err := add(f.key, redactedValue(f)); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range c.Tenants {
+		apiKeys := make([]string, len(t.APIKeys))
+		for i := range apiKeys {
+			apiKeys[i] = _redacted
+		}
+
+		if err := add(joinConfigKey(joinConfigKey(_tenantsKey, t.ID), _apiKeysKey), apiKeys); err != nil {
+			return err
+		}
+
+		for _, f := range t.fields() {
+			if err := add(f.key, redactedValue(f)); err != nil {
+				return err
+			}
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+func redactedValue(f configField) any {
+	switch {
+	case f.secret && !f.value.IsZero():
+		return _redacted
+	case f.value.Type() == _durationType:
+		return f.value.Interface().(time.Duration).String()
+	default:
+		return f.value.Interface()
+	}
+}
+
+// configTable returns the mapping node of the table, creating it and its parents when missing.
+func configTable(tables map[string]*yaml.Node, key string) *yaml.Node {
+	if table, ok := tables[key]; ok {
+		return table
+	}
+
+	prefix, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		prefix, name = key[:i], key[i+1:]
+	}
+
+	table := &yaml.Node{Kind: yaml.MappingNode}
+	parent := configTable(tables, prefix)
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, table)
+	tables[key] = table
+
+	return table
+}
+
+func joinConfigKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+// snakeCase converts a Go field name to snake case, keeping initialisms together, e.g. SMTPHost to smtp_host.
+func snakeCase(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// CorrelationIDHeader is the header carrying the correlation id of a request.
+	CorrelationIDHeader = "X-Correlation-ID"
+
+	// RequestIDHeader is accepted as an alternative to CorrelationIDHeader.
+	RequestIDHeader = "X-Request-ID"
+)
+
+// correlationIDPattern restricts accepted correlation ids, so that they are safe to log and forward as headers.
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+type correlationIDContextKey struct{}
+
+// CorrelationIDMiddleware takes the correlation id of the request or generates a new one, stores it
+// in the request context and returns it in the response.
+func CorrelationIDMiddleware(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+		correlationID := r.Header.Get(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = r.Header.Get(RequestIDHeader)
+		}
+
+		if !correlationIDPattern.MatchString(correlationID) {
+			correlationID = uuid.NewString()
+		}
+
+		// Header is set before calling the handler so that it is part of every response, including errors.
+		w.Header().Set(CorrelationIDHeader, correlationID)
+
+		next(w, r.WithContext(WithCorrelationID(r.Context(), correlationID)), m)
+	}
+}
+
+// WithCorrelationID stores the correlation id in the context.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, correlationID)
+}
+
+// CorrelationIDFromContext retrieves the correlation id stored in the context, empty if there is none.
+func CorrelationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDContextKey{}).(string)
+
+	return correlationID
+}
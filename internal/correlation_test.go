@@ -0,0 +1,116 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+func TestCorrelationID(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name                  string
+		requestBody           *internal.SlackRequestBody
+		headers               map[string]string
+		expectedStatusCode    int
+		expectedCorrelationID string
+	}
+
+	tests := []test{
+		{
+			name:                  "correlation id header should be propagated",
+			requestBody:           &internal.SlackRequestBody{Message: "Hello"},
+			headers:               map[string]string{"X-Correlation-ID": "abc-123"},
+			expectedStatusCode:    http.StatusOK,
+			expectedCorrelationID: "abc-123",
+		},
+		{
+			name:                  "request id header should be accepted as correlation id",
+			requestBody:           &internal.SlackRequestBody{Message: "Hello"},
+			headers:               map[string]string{"X-Request-ID": "req-456"},
+			expectedStatusCode:    http.StatusOK,
+			expectedCorrelationID: "req-456",
+		},
+		{
+			name:                  "correlation id should be returned on error responses",
+			requestBody:           &internal.SlackRequestBody{},
+			headers:               map[string]string{"X-Correlation-ID": "abc-123"},
+			expectedStatusCode:    http.StatusBadRequest,
+			expectedCorrelationID: "abc-123",
+		},
+		{
+			name:               "correlation id should be generated when missing",
+			requestBody:        &internal.SlackRequestBody{Message: "Hello"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "invalid correlation id should be replaced",
+			requestBody:        &internal.SlackRequestBody{Message: "Hello"},
+			headers:            map[string]string{"X-Correlation-ID": "abc\n123"},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var notificationCorrelationID string
+
+			notifierMock := &mocks.NotifierMock{
+				NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+					notificationCorrelationID = internal.CorrelationIDFromContext(ctx)
+
+					return nil
+				},
+			}
+
+			mux := internal.NewMux(config, logger, notifierMock)
+
+			payload, err := json.Marshal(tc.requestBody)
+			if err != nil {
+				t.Fatalf("failed to marshal Slack message: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload))
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			res := httptest.NewRecorder()
+
+			mux.ServeHTTP(res, req)
+
+			if res.Result().StatusCode != tc.expectedStatusCode {
+				t.Fatalf("Different status codes, expected: %v, got: %v", tc.expectedStatusCode, res.Result().StatusCode)
+			}
+
+			correlationID := res.Result().Header.Get("X-Correlation-ID")
+
+			switch {
+			case tc.expectedCorrelationID != "" && correlationID != tc.expectedCorrelationID:
+				t.Fatalf("Different correlation ids, expected: %s, got: %s", tc.expectedCorrelationID, correlationID)
+			case tc.expectedCorrelationID == "" && (correlationID == "" || correlationID == tc.headers["X-Correlation-ID"]):
+				t.Fatalf("Expected correlation id to be generated, got: %q", correlationID)
+			}
+
+			if len(notifierMock.NotifySlackCalls()) > 0 && notificationCorrelationID != correlationID {
+				t.Fatalf("Different correlation ids in notifier, expected: %s, got: %s", correlationID, notificationCorrelationID)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discord holds Discord related configuration for sending notifications.
+type Discord struct {
+	webHookURL string
+	username   string
+	avatarURL  string
+	client     *http.Client
+	throttle   *Throttle
+	limits     *discordLimits
+}
+
+func newDiscord(config DiscordConfig) *Discord {
+	return &Discord{
+		webHookURL: config.WebHookURL,
+		username:   config.Username,
+		avatarURL:  config.AvatarURL,
+		client:     newProviderClient(),
+		limits:     &discordLimits{resets: make(map[string]time.Time)},
+	}
+}
+
+// discordLimits remembers until when the rate limit of every webhook is exhausted, as reported by Discord, so that
+// notifications wait for the reset instead of being rejected. Webhooks are keyed by their ID, so that their tokens
+// are not kept around, and are forgotten once their limit resets.
+type discordLimits struct {
+	mu     sync.Mutex
+	resets map[string]time.Time
+}
+
+// wait blocks until the rate limit of the webhook resets. When the reset is past the deadline of the context the
+// error asks to retry after it instead.
+func (l *discordLimits) wait(ctx context.Context, webHookURL string) error {
+	l.mu.Lock()
+	reset := l.resets[discordWebhookID(webHookURL)]
+	l.mu.Unlock()
+
+	delay := time.Until(reset)
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && reset.After(deadline) {
+		return RetryAfter(fmt.Errorf("rate limited for %v", delay.Round(time.Millisecond)), delay)
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *discordLimits) set(webHookURL string, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	for id, r := range l.resets {
+		if r.Before(now) {
+			delete(l.resets, id)
+		}
+	}
+
+	id := discordWebhookID(webHookURL)
+
+	if reset.After(l.resets[id]) {
+		l.resets[id] = reset
+	}
+}
+
+// discordWebhookID extracts the ID of the webhook from its URL, https://discord.com/api/webhooks/{id}/{token}.
+// URLs of another form are identified by their hash instead, the token must not be kept in memory longer than needed.
+func discordWebhookID(webHookURL string) string {
+	if u, err := url.Parse(webHookURL); err == nil {
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+		for i, s := range segments {
+			if s == "webhooks" && i+1 < len(segments) {
+				return u.Host + "/" + segments[i+1]
+			}
+		}
+	}
+
+	sum := sha256.Sum256([]byte(webHookURL))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// discordMessage is the message executed by the webhook.
+type discordMessage struct {
+	Content   string         `json:"content,omitempty"`
+	Embeds    []discordEmbed `json:"embeds,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Fields      []DiscordField `json:"fields,omitempty"`
+}
+
+// discordRateLimit is the body of 429 responses, RetryAfter is in seconds.
+type discordRateLimit struct {
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+	Global     bool    `json:"global"`
+}
+
+// newDiscordMessage maps the notification to a webhook message, the username and avatar of the notification take
+// precedence over the configured ones.
+func (d *Discord) newDiscordMessage(body *DiscordRequestBody) discordMessage {
+	msg := discordMessage{
+		Content:   body.Content,
+		Username:  body.Username,
+		AvatarURL: body.AvatarURL,
+	}
+
+	if msg.Username == "" {
+		msg.Username = d.username
+	}
+
+	if msg.AvatarURL == "" {
+		msg.AvatarURL = d.avatarURL
+	}
+
+	for _, e := range body.Embeds {
+		msg.Embeds = append(msg.Embeds, discordEmbed{
+			Title:       e.Title,
+			Description: e.Description,
+			URL:         e.URL,
+			Color:       parseDiscordColor(e.Color),
+			Fields:      e.Fields,
+		})
+	}
+
+	return msg
+}
+
+// parseDiscordColor converts the hex colour, e.g. #5865f2 or #fff, to the integer expected by Discord. The alpha of
+// colours with one is dropped.
+func parseDiscordColor(color string) int {
+	hex := strings.TrimPrefix(color, "#")
+
+	switch len(hex) {
+	case 3, 4:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 8:
+		hex = hex[:6]
+	}
+
+	// The colour is validated, invalid ones are not sent.
+	value, _ := strconv.ParseInt(hex, 16, 32)
+
+	return int(value)
+}
+
+// NotifyDiscord sends Discord notification through the webhook. Rate limits reported by Discord are waited for
+// before sending and retried after the delay it asks for.
+func (s *Service) NotifyDiscord(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_discordChannel, _discordProvider, err) }()
+
+	discord := s.providers.Load().discord
+	if discord == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _discordChannel)
+	}
+
+	payload, err := json.Marshal(discord.newDiscordMessage(msg.(*DiscordRequestBody)))
+	if err != nil {
+		return fmt.Errorf("failed to marshal Discord message: %v", err)
+	}
+
+	release, err := discord.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule Discord notification: %w", err)
+	}
+	defer release()
+
+	// Notifications addressed to a destination are sent to its webhook instead.
+	webHookURL := discord.webHookURL
+	if u := destinationURLFromContext(ctx); u != "" {
+		webHookURL = u
+	}
+
+	if err := discord.limits.wait(ctx, webHookURL); err != nil {
+		return fmt.Errorf("failed to send Discord notification: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webHookURL, bytes.NewReader(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), webHookURL)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	err = sendProviderRequest(ctx, discord.client, req, _discordChannel, _discordProvider,
+		func(resp *http.Response, body []byte) error {
+			return discord.checkResponse(webHookURL, resp, body)
+		},
+	)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send Discord notification: %w", err), webHookURL)
+	}
+
+	LoggerFromContext(ctx).Debug("Discord notification sent")
+
+	return nil
+}
+
+// checkResponse records the rate limit of the webhook and checks the response. Rate limited messages are retried
+// after the delay in the body of the response, or in the headers when the body has none.
+func (d *Discord) checkResponse(webHookURL string, resp *http.Response, body []byte) error {
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, ok := discordRateLimitReset(resp.Header); ok {
+			d.limits.set(webHookURL, reset)
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return responseStatusError(resp)
+	}
+
+	var limit discordRateLimit
+
+	//nolint: errcheck
+	json.Unmarshal(body, &limit)
+
+	after := time.Duration(limit.RetryAfter * float64(time.Second))
+	if after <= 0 {
+		if reset, ok := discordRateLimitReset(resp.Header); ok {
+			after = time.Until(reset)
+		}
+	}
+
+	d.limits.set(webHookURL, time.Now().Add(after))
+
+	return RetryAfter(fmt.Errorf("rate limited, retry after %v", after.Round(time.Millisecond)), after)
+}
+
+// discordRateLimitReset reads the time the rate limit resets at from X-RateLimit-Reset-After, seconds from now, or
+// X-RateLimit-Reset, seconds since the epoch, falling back to Retry-After.
+func discordRateLimitReset(header http.Header) (time.Time, bool) {
+	if after, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		return time.Now().Add(time.Duration(after * float64(time.Second))), true
+	}
+
+	if reset, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset"), 64); err == nil {
+		sec, frac := math.Modf(reset)
+
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+	}
+
+	if after, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(after) * time.Second), true
+	}
+
+	return time.Time{}, false
+}
+
+// checkDiscord verifies the webhook is reachable.
+func (s *Service) checkDiscord(ctx context.Context) error {
+	discord := s.providers.Load().discord
+
+	return checkURL(ctx, discord.client, discord.webHookURL)
+}
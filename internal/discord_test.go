@@ -0,0 +1,276 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// discordMessage is the part of the webhook message checked by the tests.
+type discordMessage struct {
+	Content   string `json:"content"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+	Embeds    []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Color       int    `json:"color"`
+		Fields      []struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Inline bool   `json:"inline"`
+		} `json:"fields"`
+	} `json:"embeds"`
+}
+
+func newDiscordConfig(t *testing.T, webHookURL string) *internal.Config {
+	t.Helper()
+
+	return newTestConfig(t, func(config *internal.Config) {
+		config.Discord = internal.DiscordConfig{Enabled: true, WebHookURL: webHookURL, Username: "Notifier"}
+	})
+}
+
+func TestDiscordNotification(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		messages []discordMessage
+		response = func(w http.ResponseWriter) { w.WriteHeader(http.StatusNoContent) }
+	)
+
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var msg discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		messages = append(messages, msg)
+		response(w)
+	}))
+	defer discord.Close()
+
+	config := newDiscordConfig(t, discord.URL)
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	embed := &internal.DiscordRequestBody{
+		Content: "Deployment finished",
+		Embeds: []internal.DiscordEmbed{{
+			Title:       "Version 1.2.3",
+			Description: "Live in production.",
+			Color:       "#5865F2",
+			Fields:      []internal.DiscordField{{Name: "Environment", Value: "production", Inline: true}},
+		}},
+		AvatarURL: "https://example.com/avatar.png",
+	}
+
+	type test struct {
+		name               string
+		requestBody        any
+		response           func(w http.ResponseWriter)
+		expectedStatusCode int
+		expectedMessages   int
+	}
+
+	tests := []test{
+		{
+			name:               "message with embed should be sent",
+			requestBody:        embed,
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   1,
+		},
+		{
+			name:               "message with embed only should be sent",
+			requestBody:        &internal.DiscordRequestBody{Embeds: []internal.DiscordEmbed{{Title: "Hello"}}},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   2,
+		},
+		{
+			name:               "message rejected by the webhook should not be retried",
+			requestBody:        &internal.DiscordRequestBody{Content: "Hello"},
+			response:           func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessages:   3,
+		},
+		{
+			name:               "message without content and embeds should be rejected",
+			requestBody:        &internal.DiscordRequestBody{Username: "Bot"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   3,
+		},
+		{
+			name: "embed with invalid colour should be rejected",
+			requestBody: &internal.DiscordRequestBody{
+				Embeds: []internal.DiscordEmbed{{Title: "Hello", Color: "blue"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   3,
+		},
+		{
+			name: "field without value should be rejected",
+			requestBody: &internal.DiscordRequestBody{
+				Embeds: []internal.DiscordEmbed{{Fields: []internal.DiscordField{{Name: "Environment"}}}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   3,
+		},
+	}
+
+	for _, tc := range tests {
+		mu.Lock()
+		if tc.response != nil {
+			response = tc.response
+		}
+		mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/discord", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		mu.Lock()
+		sent := len(messages)
+		mu.Unlock()
+
+		if sent != tc.expectedMessages {
+			t.Fatalf("%s: Expected %d messages sent, got: %d", tc.name, tc.expectedMessages, sent)
+		}
+	}
+
+	sent := messages[0]
+	if sent.Content != embed.Content || sent.Username != "Notifier" || sent.AvatarURL != embed.AvatarURL {
+		t.Fatalf("Expected content with configured username and own avatar, got: %+v", sent)
+	}
+
+	if len(sent.Embeds) != 1 || sent.Embeds[0].Title != "Version 1.2.3" || sent.Embeds[0].Color != 0x5865F2 ||
+		len(sent.Embeds[0].Fields) != 1 || !sent.Embeds[0].Fields[0].Inline {
+		t.Fatalf("Expected embed with colour and inline field, got: %+v", sent.Embeds)
+	}
+}
+
+func TestDiscordRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		times   []time.Time
+		limited bool
+	)
+
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		times = append(times, time.Now())
+
+		switch {
+		case limited:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"retry_after": 10}`)) //nolint: errcheck
+		case len(times) == 1:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			//nolint: errcheck
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.3, "global": false}`))
+		case len(times) == 2:
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset-After", "0.3")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer discord.Close()
+
+	config := newDiscordConfig(t, discord.URL)
+	config.Retry.Delay = time.Millisecond
+	s := internal.NewService(config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		err := internal.Retry(s.NotifyDiscord, config.Retry.MaxRetries, config.Retry.Delay)(ctx,
+			&internal.DiscordRequestBody{Content: "Hello"})
+		if err != nil {
+			t.Fatalf("Expected error to be nil but got: %v", err)
+		}
+	}
+
+	mu.Lock()
+	sent := times
+	limited = true
+	mu.Unlock()
+
+	if len(sent) != 3 {
+		t.Fatalf("Expected 3 requests, got: %d", len(sent))
+	}
+
+	if sent[1].Sub(sent[0]) < 300*time.Millisecond {
+		t.Fatalf("Expected retry after the delay of the response, got: %v", sent[1].Sub(sent[0]))
+	}
+
+	if sent[2].Sub(sent[1]) < 250*time.Millisecond {
+		t.Fatalf("Expected next message after the reset of the rate limit, got: %v", sent[2].Sub(sent[1]))
+	}
+
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := internal.Retry(s.NotifyDiscord, config.Retry.MaxRetries, config.Retry.Delay)(shortCtx,
+		&internal.DiscordRequestBody{Content: "Hello"})
+
+	var retryAfter *internal.RetryAfterError
+	if !errors.As(err, &retryAfter) || shortCtx.Err() != nil {
+		t.Fatalf("Expected rate limit error before the deadline, got: %v", err)
+	}
+}
+
+func TestDiscordRedirectNotFollowed(t *testing.T) {
+	t.Parallel()
+
+	var followed atomic.Bool
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer discord.Close()
+
+	config := newDiscordConfig(t, discord.URL+"/api/webhooks/1/token")
+	s := internal.NewService(config)
+
+	var permanent *internal.PermanentError
+
+	err := s.NotifyDiscord(context.Background(), &internal.DiscordRequestBody{Content: "Hello"})
+	if !errors.As(err, &permanent) || followed.Load() {
+		t.Fatalf("Expected redirect to fail permanently without being followed, got: %v", err)
+	}
+}
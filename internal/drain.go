@@ -0,0 +1,341 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const _pendingFileExt = ".json"
+
+// PendingNotification is a notification interrupted by shutdown, persisted to be resumed on the next start.
+type PendingNotification struct {
+	ID          string          `json:"id"`
+	Channel     string          `json:"channel"`
+	Tenant      string          `json:"tenant,omitempty"`
+	Destination string          `json:"destination,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// PendingStore persists notifications interrupted by shutdown.
+//
+// Implementations of PendingStore must be safe for concurrent use by multiple goroutines.
+type PendingStore interface {
+	Save(context.Context, PendingNotification) error
+	List(context.Context) ([]PendingNotification, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// FilePendingStore is a PendingStore keeping every notification in a separate file of a directory.
+type FilePendingStore struct {
+	dir string
+}
+
+// NewFilePendingStore is a constructor function for FilePendingStore.
+func NewFilePendingStore(dir string) (*FilePendingStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create pending notifications directory: %v", err)
+	}
+
+	return &FilePendingStore{dir: dir}, nil
+}
+
+var _ PendingStore = (*FilePendingStore)(nil)
+
+// Save writes the notification to its own file, the write is atomic so partially written files are never listed.
+func (s *FilePendingStore) Save(_ context.Context, n PendingNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending notification: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, n.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create pending notification file: %v", err)
+	}
+
+	//nolint: errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint: errcheck
+		tmp.Close()
+
+		return fmt.Errorf("failed to write pending notification: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pending notification: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path(n.ID))
+}
+
+// List reads all persisted notifications.
+func (s *FilePendingStore) List(_ context.Context) ([]PendingNotification, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending notifications directory: %v", err)
+	}
+
+	notifications := make([]PendingNotification, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), _pendingFileExt) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending notification: %v", err)
+		}
+
+		var n PendingNotification
+		if err := json.Unmarshal(data, &n); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending notification %s: %v", entry.Name(), err)
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+// Delete removes the persisted notification.
+func (s *FilePendingStore) Delete(_ context.Context, id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete pending notification: %v", err)
+	}
+
+	return nil
+}
+
+// Check reports whether notifications can be persisted to the directory.
+func (s *FilePendingStore) Check(_ context.Context) error {
+	return checkWritableDir(s.dir)
+}
+
+func (s *FilePendingStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+_pendingFileExt)
+}
+
+// checkWritableDir reports whether files can be created in the directory.
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".check.*.tmp")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %v", err)
+	}
+
+	//nolint: errcheck
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// persistOnShutdown saves the notification for resumption when its sending was interrupted by shutdown.
+// It reports whether the notification was persisted.
+func persistOnShutdown(ctx context.Context, store PendingStore, channel string, payload any) bool {
+	if store == nil || !errors.Is(context.Cause(ctx), ErrShuttingDown) {
+		return false
+	}
+
+	// The request context is already canceled at this point.
+	ctx = context.WithoutCancel(ctx)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		LoggerFromContext(ctx).Error("failed to marshal pending notification", "error", err)
+
+		return false
+	}
+
+	n := PendingNotification{
+		ID:          uuid.NewString(),
+		Channel:     channel,
+		Tenant:      TenantIDFromContext(ctx),
+		Destination: DestinationFromContext(ctx),
+		Payload:     data,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if err := store.Save(ctx, n); err != nil {
+		LoggerFromContext(ctx).Error("failed to persist pending notification", "error", err)
+
+		return false
+	}
+
+	LoggerFromContext(ctx).Info("notification persisted for resumption", "id", n.ID, "channel", channel)
+
+	return true
+}
+
+// ResumePending sends the notifications persisted during the previous shutdown. Notifications which can't be
+// sent before the context is canceled stay in the store, the ones which can never be sent are dropped.
+// Notifications of tenants are sent for the tenant they were made for, which notifier has to resolve, see
+// Tenants.Notifier.
+func ResumePending(ctx context.Context, config *Config, store PendingStore, notifier Notifier) error {
+	notifications, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		logger := LoggerFromContext(ctx).With("id", n.ID, "channel", n.Channel)
+
+		notifyCtx := WithChannel(ctx, n.Channel)
+		if n.Tenant != "" {
+			logger = logger.With("tenant", n.Tenant)
+			notifyCtx = WithTenantID(notifyCtx, n.Tenant)
+		}
+
+		if n.Destination != "" {
+			notifyCtx = WithDestination(notifyCtx, n.Destination)
+		}
+
+		effector, payload, err := decodePending(n, notifier)
+		if err != nil {
+			logger.Error("dropping pending notification", "error", err)
+
+			//nolint: errcheck
+			store.Delete(ctx, n.ID)
+
+			continue
+		}
+
+		err = Retry(effector, config.Retry.MaxRetries, config.Retry.Delay)(notifyCtx, payload)
+
+		var permanent *PermanentError
+		if errors.As(err, &permanent) {
+			logger.Error("dropping pending notification", "error", err)
+
+			//nolint: errcheck
+			store.Delete(ctx, n.ID)
+
+			continue
+		}
+
+		if err != nil {
+			logger.Error("failed to resume pending notification", "error", err)
+
+			continue
+		}
+
+		if err := store.Delete(ctx, n.ID); err != nil {
+			logger.Error("failed to delete resumed notification", "error", err)
+
+			continue
+		}
+
+		logger.Info("pending notification resumed")
+	}
+
+	return nil
+}
+
+// decodePending restores the payload of the notification in the form expected by the notifier.
+func decodePending(n PendingNotification, notifier Notifier) (Effector, any, error) {
+	switch n.Channel {
+	case _slackChannel:
+		var message string
+		if err := json.Unmarshal(n.Payload, &message); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifySlack, message, nil
+	case _smsChannel:
+		var body SMSRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifySMS, &body, nil
+	case _mailChannel:
+		var body MailRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyMail, &body, nil
+	case _webhookChannel:
+		var body WebhookRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyWebhook, &body, nil
+	case _teamsChannel:
+		var body TeamsRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyTeams, &body, nil
+	case _discordChannel:
+		var body DiscordRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyDiscord, &body, nil
+	case _telegramChannel:
+		var body TelegramRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyTelegram, &body, nil
+	case _chatChannel:
+		var body ChatRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyChat, &body, nil
+	case _matrixChannel:
+		var body MatrixRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyMatrix, &body, nil
+	case _fcmChannel, _apnsChannel:
+		var body PushRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		if n.Channel == _apnsChannel {
+			return notifier.NotifyAPNS, &body, nil
+		}
+
+		return notifier.NotifyFCM, &body, nil
+	case _webPushChannel:
+		var body WebPushRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyWebPush, &body, nil
+	case _voiceChannel:
+		var body VoiceRequestBody
+		if err := json.Unmarshal(n.Payload, &body); err != nil {
+			return nil, nil, err
+		}
+
+		return notifier.NotifyVoice, &body, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown channel %q", n.Channel)
+	}
+}
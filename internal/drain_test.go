@@ -0,0 +1,330 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+func TestPersistInterruptedNotificationOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := internal.NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySMSFunc: func(ctx context.Context, ifaceVal any) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock, internal.WithPendingStore(store))
+
+	requestBody := &internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"}
+
+	payload, err := json.Marshal(requestBody)
+	if err != nil {
+		t.Fatalf("failed to marshal SMS message: %v", err)
+	}
+
+	lifecycle, stop := context.WithCancelCause(context.Background())
+	time.AfterFunc(time.Millisecond*50, func() { stop(internal.ErrShuttingDown) })
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sms", bytes.NewBuffer(payload)).WithContext(lifecycle)
+	res := httptest.NewRecorder()
+
+	mux.ServeHTTP(res, req)
+
+	if res.Result().StatusCode != http.StatusAccepted {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusAccepted, res.Result().StatusCode)
+	}
+
+	pending, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 1 || pending[0].Channel != "sms" {
+		t.Fatalf("Expected one pending SMS notification, got: %+v", pending)
+	}
+
+	var resumed *internal.SMSRequestBody
+
+	notifierMock.NotifySMSFunc = func(ctx context.Context, ifaceVal any) error {
+		resumed = ifaceVal.(*internal.SMSRequestBody)
+
+		return nil
+	}
+
+	if err := internal.ResumePending(context.Background(), config, store, notifierMock); err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if resumed == nil || !reflect.DeepEqual(resumed, requestBody) {
+		t.Fatalf("Different resumed notifications, expected: %+v, got: %+v", requestBody, resumed)
+	}
+
+	pending, err = store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatalf("Expected resumed notification to be removed from store, got: %+v", pending)
+	}
+}
+
+func TestPermanentlyFailingPendingNotificationDropped(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := internal.NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, payload := range map[string]string{"permanent": `"permanent"`, "transient": `"transient"`} {
+		n := internal.PendingNotification{ID: id, Channel: "slack", Payload: json.RawMessage(payload)}
+		if err := store.Save(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+			if ifaceVal.(string) == "permanent" {
+				return internal.Permanent(errors.New("unknown destination"))
+			}
+
+			return errors.New("unavailable")
+		},
+	}
+
+	config.Retry = internal.RequestRetryConfig{MaxRetries: 1}
+
+	if err := internal.ResumePending(context.Background(), config, store, notifierMock); err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	pending, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 1 || pending[0].ID != "transient" {
+		t.Fatalf("Expected only the transiently failing notification to be kept, got: %+v", pending)
+	}
+}
+
+func TestNotificationNotPersistedOnClientCancellation(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := internal.NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock, internal.WithPendingStore(store))
+
+	payload, err := json.Marshal(&internal.SlackRequestBody{Message: "Hello"})
+	if err != nil {
+		t.Fatalf("failed to marshal Slack message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload)).WithContext(ctx)
+	res := httptest.NewRecorder()
+
+	mux.ServeHTTP(res, req)
+
+	if res.Result().StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusInternalServerError, res.Result().StatusCode)
+	}
+
+	pending, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatalf("Expected no pending notifications, got: %+v", pending)
+	}
+}
+
+func TestInterruptedVoiceCallPersistedWithoutCallbacks(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Twilio.Voice = internal.TwilioVoiceConfig{Enabled: true, CallbackURL: "https://notifier.example.com"}
+
+	store, err := internal.NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifyVoiceFunc: func(ctx context.Context, ifaceVal any) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock, internal.WithPendingStore(store))
+
+	payload, err := json.Marshal(&internal.VoiceRequestBody{
+		Message: "Alert", SendToNumber: "+35988357997", Acknowledge: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal voice call: %v", err)
+	}
+
+	lifecycle, stop := context.WithCancelCause(context.Background())
+	time.AfterFunc(time.Millisecond*50, func() { stop(internal.ErrShuttingDown) })
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/voice", bytes.NewBuffer(payload)).WithContext(lifecycle)
+	res := httptest.NewRecorder()
+
+	mux.ServeHTTP(res, req)
+
+	if res.Result().StatusCode != http.StatusAccepted {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusAccepted, res.Result().StatusCode)
+	}
+
+	var resumed *internal.VoiceRequestBody
+
+	notifierMock.NotifyVoiceFunc = func(ctx context.Context, ifaceVal any) error {
+		resumed = ifaceVal.(*internal.VoiceRequestBody)
+
+		return nil
+	}
+
+	if err := internal.ResumePending(context.Background(), config, store, notifierMock); err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if resumed == nil || resumed.Message != "Alert" || resumed.CallID != "" || resumed.CallbackKey != "" {
+		t.Fatalf("Expected the call to be resumed without callbacks, got: %+v", resumed)
+	}
+}
+
+func TestPendingNotificationOfRemovedTenantDropped(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Retry = internal.RequestRetryConfig{MaxRetries: 3}
+
+	tenantConfig := *config
+	config.Tenants = []internal.TenantConfig{{ID: "acme", APIKeys: []string{"acme-key"}, Config: &tenantConfig}}
+
+	tenants := internal.NewTenants()
+	if err := tenants.Reload(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := internal.NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := internal.PendingNotification{ID: "acme", Channel: "slack", Tenant: "acme", Payload: json.RawMessage(`"Hello"`)}
+	if err := store.Save(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tenant is removed before the notification is resumed.
+	withoutTenant := *config
+	withoutTenant.Tenants = nil
+
+	if err := tenants.Reload(context.Background(), &withoutTenant); err != nil {
+		t.Fatal(err)
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+			return nil
+		},
+	}
+
+	if err := internal.ResumePending(context.Background(), config, store, tenants.Notifier(notifierMock)); err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if len(notifierMock.NotifySlackCalls()) != 0 {
+		t.Fatalf("Expected notification of removed tenant not to be sent, got: %+v", notifierMock.NotifySlackCalls())
+	}
+
+	pending, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatalf("Expected notification of removed tenant to be dropped, got: %+v", pending)
+	}
+}
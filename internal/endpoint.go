@@ -2,16 +2,26 @@ package internal
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/dimfeld/httptreemux/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 // MakeSlackEndpoint creates endpoint for sending Slack notifications.
-func MakeSlackEndpoint(config *Config, notifier SlackNotifier) func(w http.ResponseWriter, r *http.Request) {
+func MakeSlackEndpoint(
+	config *Config,
+	notifier SlackNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
 	v := validator.New()
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -35,11 +45,30 @@ func MakeSlackEndpoint(config *Config, notifier SlackNotifier) func(w http.Respo
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay)
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
 		defer cancel()
 
+		ctx = WithChannel(ctx, _slackChannel)
+		if slackRequest.Destination != "" {
+			ctx = WithDestination(ctx, slackRequest.Destination)
+		}
+
 		err := Retry(notifier.NotifySlack, config.Retry.MaxRetries, config.Retry.Delay)(ctx, slackRequest.Message)
 		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, _slackChannel, slackRequest.Message) {
+				writeQueued(w)
+
+				return
+			}
+
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 
 			return
@@ -53,9 +82,13 @@ func MakeSlackEndpoint(config *Config, notifier SlackNotifier) func(w http.Respo
 	}
 }
 
-// MakeSMSEndpoint creates endpoint for sending SMS notifications.
-func MakeSMSEndpoint(config *Config, notifier SMSNotifier) func(w http.ResponseWriter, r *http.Request) {
-	v := validator.New()
+// MakeSMSEndpoint creates endpoint for sending SMS, MMS and WhatsApp notifications.
+func MakeSMSEndpoint(
+	config *Config,
+	notifier SMSNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	v := newRequestValidator()
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -72,17 +105,36 @@ func MakeSMSEndpoint(config *Config, notifier SMSNotifier) func(w http.ResponseW
 			return
 		}
 
-		if err := v.Struct(&smsRequest); err != nil {
+		if err := validateRequest(v, &smsRequest, smsRequest.Destination, "SendToNumber"); err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
 
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay)
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
 		defer cancel()
 
+		ctx = WithChannel(ctx, _smsChannel)
+		if smsRequest.Destination != "" {
+			ctx = WithDestination(ctx, smsRequest.Destination)
+		}
+
 		err := Retry(notifier.NotifySMS, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &smsRequest)
 		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, _smsChannel, &smsRequest) {
+				writeQueued(w)
+
+				return
+			}
+
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
 
 			return
@@ -97,7 +149,11 @@ func MakeSMSEndpoint(config *Config, notifier SMSNotifier) func(w http.ResponseW
 }
 
 // MakeMailEndpoint creates endpoint for sending SMS notifications.
-func MakeMailEndpoint(config *Config, notifier MailNotifier) func(w http.ResponseWriter, r *http.Request) {
+func MakeMailEndpoint(
+	config *Config,
+	notifier MailNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
 	v := validator.New()
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -115,17 +171,36 @@ func MakeMailEndpoint(config *Config, notifier MailNotifier) func(w http.Respons
 			return
 		}
 
-		if err := v.Struct(&mailRequest); err != nil {
+		if err := validateRequest(v, &mailRequest, mailRequest.Destination, "SendTo"); err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
 
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay)
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
 		defer cancel()
 
+		ctx = WithChannel(ctx, _mailChannel)
+		if mailRequest.Destination != "" {
+			ctx = WithDestination(ctx, mailRequest.Destination)
+		}
+
 		err := Retry(notifier.NotifyMail, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &mailRequest)
 		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, _mailChannel, &mailRequest) {
+				writeQueued(w)
+
+				return
+			}
+
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
 
 			return
@@ -138,3 +213,845 @@ func MakeMailEndpoint(config *Config, notifier MailNotifier) func(w http.Respons
 		}
 	}
 }
+
+// MakeWebhookEndpoint creates endpoint for sending webhook notifications.
+func MakeWebhookEndpoint(
+	config *Config,
+	notifier WebhookNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var webhookRequest WebhookRequestBody
+		if err := decoder.Decode(&webhookRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := v.Struct(&webhookRequest); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		if err := checkWebhookHeaders(webhookRequest.Headers); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		// The ID is kept across retries and resumption, so that receivers can recognize duplicates.
+		if webhookRequest.ID == "" {
+			webhookRequest.ID = uuid.NewString()
+		}
+
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
+		defer cancel()
+
+		ctx = WithChannel(ctx, _webhookChannel)
+		if webhookRequest.Destination != "" {
+			ctx = WithDestination(ctx, webhookRequest.Destination)
+		}
+
+		err := Retry(notifier.NotifyWebhook, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &webhookRequest)
+		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, _webhookChannel, &webhookRequest) {
+				writeQueued(w)
+
+				return
+			}
+
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		response := fmt.Sprintf(`{"status": "Notification send.", "id": %q}`, webhookRequest.ID)
+		if _, err := w.Write([]byte(response)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeTeamsEndpoint creates endpoint for sending Microsoft Teams notifications.
+func MakeTeamsEndpoint(
+	config *Config,
+	notifier TeamsNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makeChannelEndpoint[TeamsRequestBody](config, _teamsChannel, notifier.NotifyTeams, store, "")
+}
+
+// MakeDiscordEndpoint creates endpoint for sending Discord notifications.
+func MakeDiscordEndpoint(
+	config *Config,
+	notifier DiscordNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makeChannelEndpoint[DiscordRequestBody](config, _discordChannel, notifier.NotifyDiscord, store, "")
+}
+
+// MakeTelegramEndpoint creates endpoint for sending Telegram notifications.
+func MakeTelegramEndpoint(
+	config *Config,
+	notifier TelegramNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makeChannelEndpoint[TelegramRequestBody](config, _telegramChannel, notifier.NotifyTelegram, store, "ChatID")
+}
+
+// MakeChatEndpoint creates endpoint for sending Mattermost and Rocket.Chat notifications.
+func MakeChatEndpoint(
+	config *Config,
+	notifier ChatNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makeChannelEndpoint[ChatRequestBody](config, _chatChannel, notifier.NotifyChat, store, "")
+}
+
+// MakeMatrixEndpoint creates endpoint for sending Matrix notifications.
+func MakeMatrixEndpoint(
+	config *Config,
+	notifier MatrixNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makeChannelEndpoint[MatrixRequestBody](config, _matrixChannel, notifier.NotifyMatrix, store, "Room")
+}
+
+// addressedRequest is a request body which can be addressed to a destination managed through the admin API.
+type addressedRequest[T any] interface {
+	*T
+	destination() string
+}
+
+// identifiedRequest is a request body sent once under its ID, however often it is retried. identify generates the
+// ID when the client hasn't set it and returns it.
+type identifiedRequest interface {
+	identify() string
+}
+
+func (b *TeamsRequestBody) destination() string    { return b.Destination }
+func (b *DiscordRequestBody) destination() string  { return b.Destination }
+func (b *TelegramRequestBody) destination() string { return b.Destination }
+func (b *ChatRequestBody) destination() string     { return b.Destination }
+func (b *MatrixRequestBody) destination() string   { return b.Destination }
+
+// identify keeps the ID across retries and resumption, so that the homeserver posts the message once.
+func (b *MatrixRequestBody) identify() string {
+	if b.ID == "" {
+		b.ID = uuid.NewString()
+	}
+
+	return b.ID
+}
+
+// makeChannelEndpoint creates endpoint sending the requests of the channel, decoded into T, through notify.
+// addressField is the field of T holding the address of the recipient, left out of validation of requests addressed
+// to a destination, it is empty for channels sending to the configured webhook only.
+func makeChannelEndpoint[T any, P addressedRequest[T]](
+	config *Config, channel string, notify Effector, store PendingStore, addressField string,
+) http.HandlerFunc {
+	v := newRequestValidator()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		request := P(new(T))
+		if err := decoder.Decode(request); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := validateRequest(v, request, request.destination(), addressField); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		response := `{"status": "Notification send."}`
+		if identified, ok := any(request).(identifiedRequest); ok {
+			response = fmt.Sprintf(`{"status": "Notification send.", "id": %q}`, identified.identify())
+		}
+
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
+		defer cancel()
+
+		ctx = WithChannel(ctx, channel)
+		if request.destination() != "" {
+			ctx = WithDestination(ctx, request.destination())
+		}
+
+		err := Retry(notify, config.Retry.MaxRetries, config.Retry.Delay)(ctx, request)
+		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, channel, request) {
+				writeQueued(w)
+
+				return
+			}
+
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		if _, err := w.Write([]byte(response)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeFCMEndpoint creates endpoint for sending push notifications through Firebase Cloud Messaging.
+func MakeFCMEndpoint(
+	config *Config,
+	notifier FCMNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makePushEndpoint(config, _fcmChannel, notifier.NotifyFCM, store)
+}
+
+// MakeAPNSEndpoint creates endpoint for sending push notifications through Apple Push Notification service.
+func MakeAPNSEndpoint(
+	config *Config,
+	notifier APNSNotifier,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return makePushEndpoint(config, _apnsChannel, notifier.NotifyAPNS, store)
+}
+
+// makePushEndpoint creates endpoint for sending push notifications through the channel. Notifications to
+// unregistered device tokens are answered with 410, so that clients stop sending to them.
+func makePushEndpoint(config *Config, channel string, notify Effector, store PendingStore) http.HandlerFunc {
+	v := validator.New()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var pushRequest PushRequestBody
+		if err := decoder.Decode(&pushRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := validateRequest(v, &pushRequest, pushRequest.Destination, "Token"); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
+		defer cancel()
+
+		ctx = WithChannel(ctx, channel)
+		if pushRequest.Destination != "" {
+			ctx = WithDestination(ctx, pushRequest.Destination)
+		}
+
+		err := Retry(notify, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &pushRequest)
+		if err != nil {
+			if isInvalidNotification(err) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+				return
+			}
+
+			if errors.Is(err, ErrUnregisteredToken) {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusGone)
+
+				return
+			}
+
+			if persistOnShutdown(ctx, store, channel, &pushRequest) {
+				writeQueued(w)
+
+				return
+			}
+
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		if _, err := w.Write([]byte(`{"status": "Notification send."}`)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeWebPushEndpoint creates endpoint for sending web push notifications to every subscription of a user, or to the
+// subscription of the request. Subscriptions the push service reports as expired or unsubscribed are removed.
+func MakeWebPushEndpoint(
+	config *Config,
+	notifier WebPushNotifier,
+	subscriptions SubscriptionStore,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	v := newRequestValidator()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var webPushRequest WebPushRequestBody
+		if err := decoder.Decode(&webPushRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := v.Struct(&webPushRequest); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
+		defer cancel()
+
+		ctx = WithChannel(ctx, _webPushChannel)
+		tenant := TenantIDFromContext(ctx)
+
+		targets := []PushSubscription{}
+		if webPushRequest.Subscription != nil {
+			targets = append(targets, *webPushRequest.Subscription)
+		} else {
+			var err error
+			if targets, err = subscriptions.List(ctx, tenant, webPushRequest.User); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		if len(targets) == 0 {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, ErrNoSubscriptions.Error()), http.StatusNotFound)
+
+			return
+		}
+
+		var (
+			sent, removed, queued int
+			failed                []error
+		)
+
+		// Every subscription is retried on its own, so that a failing push service doesn't repeat the others.
+		for _, sub := range targets {
+			body := webPushRequest
+			body.Subscription = &sub
+
+			err := Retry(notifier.NotifyWebPush, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &body)
+
+			switch {
+			case err == nil:
+				sent++
+			case errors.Is(err, ErrUnregisteredToken):
+				removed++
+
+				if webPushRequest.Subscription != nil {
+					continue
+				}
+
+				// The subscription is removed even when the request is canceled in the meantime.
+				err := subscriptions.Delete(context.WithoutCancel(ctx), tenant, webPushRequest.User, sub.Endpoint)
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					LoggerFromContext(ctx).Error("failed to remove expired web push subscription", "error", err)
+				}
+			case persistOnShutdown(ctx, store, _webPushChannel, &body):
+				queued++
+			default:
+				failed = append(failed, err)
+			}
+		}
+
+		switch {
+		case sent == 0 && len(failed) > 0 && isInvalidNotification(failed[0]):
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, failed[0].Error()), http.StatusBadRequest)
+		case sent == 0 && len(failed) > 0:
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, failed[0].Error()), http.StatusInternalServerError)
+		case sent == 0 && queued > 0:
+			writeQueued(w)
+		case sent == 0:
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, ErrUnregisteredToken.Error()), http.StatusGone)
+		default:
+			response := fmt.Sprintf(`{"status": "Notification send.", "sent": %d, "removed": %d, "queued": %d, "failed": %d}`,
+				sent, removed, queued, len(failed))
+			if _, err := w.Write([]byte(response)); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+		}
+	}
+}
+
+// MakeVAPIDKeyEndpoint creates endpoint returning the VAPID public key of the tenant, the application server key
+// browsers subscribe with.
+func MakeVAPIDKeyEndpoint(config *Config, tenants *Tenants) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		c := config
+		if tenant, ok := tenants.Tenant(TenantIDFromContext(r.Context())); ok {
+			c = tenant.Config
+		}
+
+		_, publicKey, err := parseVAPIDKey(c.WebPush.VAPIDPrivateKey)
+		if err != nil {
+			http.Error(w, `{"error": "Internal error"}`, http.StatusInternalServerError)
+
+			return
+		}
+
+		if _, err := fmt.Fprintf(w, `{"public_key": %q}`, publicKey); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeSaveSubscriptionEndpoint creates endpoint registering web push subscription of a user. Subscriptions on push
+// services the tenant does not allow are rejected, notifications could never be pushed to them.
+func MakeSaveSubscriptionEndpoint(
+	config *Config,
+	tenants *Tenants,
+	subscriptions SubscriptionStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	v := newRequestValidator()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var subscriptionRequest SubscriptionRequestBody
+		if err := decoder.Decode(&subscriptionRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := v.Struct(&subscriptionRequest); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		c := config
+		if tenant, ok := tenants.Tenant(TenantIDFromContext(r.Context())); ok {
+			c = tenant.Config
+		}
+
+		// The endpoint is validated, it is known to parse.
+		endpoint, _ := url.Parse(subscriptionRequest.Subscription.Endpoint)
+		if !pushServiceAllowed(parseHosts(c.WebPush.AllowedHosts), endpoint) {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, ErrPushServiceNotAllowed.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		err := subscriptions.Save(r.Context(), TenantIDFromContext(r.Context()), subscriptionRequest.User,
+			subscriptionRequest.Subscription)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+
+		//nolint: errcheck
+		w.Write([]byte(`{"status": "Subscription saved."}`))
+	}
+}
+
+// MakeDeleteSubscriptionEndpoint creates endpoint removing web push subscription of a user, identified by its
+// endpoint, e.g. when the user unsubscribes in the browser.
+func MakeDeleteSubscriptionEndpoint(subscriptions SubscriptionStore) func(w http.ResponseWriter, r *http.Request) {
+	v := newRequestValidator()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var subscriptionRequest SubscriptionRequestBody
+		if err := decoder.Decode(&subscriptionRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := v.StructPartial(&subscriptionRequest, "User", "Subscription.Endpoint"); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		err := subscriptions.Delete(r.Context(), TenantIDFromContext(r.Context()), subscriptionRequest.User,
+			subscriptionRequest.Subscription.Endpoint)
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		//nolint: errcheck
+		w.Write([]byte(`{"status": "Subscription deleted."}`))
+	}
+}
+
+// MakeVoiceEndpoint creates endpoint for placing voice calls, the call is looked up by the returned ID.
+func MakeVoiceEndpoint(
+	config *Config,
+	notifier VoiceNotifier,
+	calls CallStore,
+	store PendingStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		decoder := json.NewDecoder(r.Body)
+
+		//nolint: errcheck
+		defer r.Body.Close()
+
+		var voiceRequest VoiceRequestBody
+		if err := decoder.Decode(&voiceRequest); err != nil {
+			http.Error(w, `{"error": "Bad request"}`, http.StatusBadRequest)
+
+			return
+		}
+
+		if err := validateRequest(v, &voiceRequest, voiceRequest.Destination, "SendToNumber"); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusBadRequest)
+
+			return
+		}
+
+		key, err := newCallbackKey()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		// Identity of the call is never taken from the client.
+		voiceRequest.CallID = uuid.NewString()
+		voiceRequest.CallbackKey = key
+
+		now := time.Now().UTC()
+		call := VoiceCall{
+			ID:          voiceRequest.CallID,
+			Tenant:      TenantIDFromContext(r.Context()),
+			To:          voiceRequest.SendToNumber,
+			Destination: voiceRequest.Destination,
+			Status:      CallStatusQueued,
+			CallbackKey: key,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		if err := calls.Save(r.Context(), call); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(
+			r.Context(), time.Duration(config.Retry.MaxRetries+5)*config.Retry.Delay,
+		)
+		defer cancel()
+
+		ctx = WithChannel(ctx, _voiceChannel)
+		if voiceRequest.Destination != "" {
+			ctx = WithDestination(ctx, voiceRequest.Destination)
+		}
+
+		err = Retry(notifier.NotifyVoice, config.Retry.MaxRetries, config.Retry.Delay)(ctx, &voiceRequest)
+		if err != nil {
+			if persistOnShutdown(ctx, store, _voiceChannel, &voiceRequest) {
+				writeQueued(w)
+
+				return
+			}
+
+			failed := func(c *VoiceCall) { c.Status = CallStatusFailed }
+			if err := calls.Update(context.WithoutCancel(ctx), call.ID, failed); err != nil {
+				LoggerFromContext(ctx).Error("failed to record failed voice call", "error", err)
+			}
+
+			status := http.StatusInternalServerError
+			if isInvalidNotification(err) {
+				status = http.StatusBadRequest
+			}
+
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), status)
+
+			return
+		}
+
+		if _, err := fmt.Fprintf(w, `{"status": "Call placed.", "id": %q}`, call.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeVoiceCallEndpoint creates endpoint returning the state of voice call of the tenant.
+func MakeVoiceCallEndpoint(calls CallStore) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		call, err := calls.Call(r.Context(), httptreemux.ContextParams(r.Context())["id"])
+		if errors.Is(err, ErrNotFound) || err == nil && call.Tenant != TenantIDFromContext(r.Context()) {
+			http.Error(w, fmt.Sprintf(`{"error": "call %s"}`, ErrNotFound.Error()), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(call); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// MakeVoiceStatusCallbackEndpoint creates endpoint recording the status of voice calls reported by Twilio.
+func MakeVoiceStatusCallbackEndpoint(calls CallStore) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call, ok := callbackCall(w, r, calls)
+		if !ok {
+			return
+		}
+
+		status := r.PostForm.Get("CallStatus")
+
+		err := calls.Update(r.Context(), call.ID, func(c *VoiceCall) {
+			c.SID = r.PostForm.Get("CallSid")
+			c.setStatus(status)
+			call = *c
+		})
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+
+			return
+		}
+
+		if call.finished() && call.Status == status {
+			observeVoiceCall(status)
+			LoggerFromContext(r.Context()).Info("Voice call finished",
+				"call_id", call.ID, "sid", call.SID, "status", status, "acknowledged", call.Acknowledged)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MakeVoiceGatherCallbackEndpoint creates endpoint recording the key pressed by the callee of voice call, as reported
+// by Twilio. It answers with the TwiML continuing the call.
+func MakeVoiceGatherCallbackEndpoint(
+	config *Config,
+	tenants *Tenants,
+	calls CallStore,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call, ok := callbackCall(w, r, calls)
+		if !ok {
+			return
+		}
+
+		acknowledged := r.PostForm.Get("Digits") == _acknowledgeDigit
+		if acknowledged {
+			if err := calls.Update(r.Context(), call.ID, func(c *VoiceCall) { c.Acknowledged = true }); err != nil {
+				http.Error(w, "Internal error", http.StatusInternalServerError)
+
+				return
+			}
+
+			LoggerFromContext(r.Context()).Info("Voice call acknowledged", "call_id", call.ID)
+		}
+
+		c := config
+		if tenant, ok := tenants.Tenant(call.Tenant); ok {
+			c = tenant.Config
+		}
+
+		twiml, err := gatherResponse(c, acknowledged)
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+
+		//nolint: errcheck
+		w.Write(twiml)
+	}
+}
+
+// callbackCall looks up the call of the callback, the key in the URL of the callback has to be the key of the call.
+func callbackCall(w http.ResponseWriter, r *http.Request, calls CallStore) (VoiceCall, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+
+		return VoiceCall{}, false
+	}
+
+	query := r.URL.Query()
+
+	call, err := calls.Call(r.Context(), query.Get("id"))
+	if err != nil || subtle.ConstantTimeCompare([]byte(call.CallbackKey), []byte(query.Get("key"))) != 1 {
+		http.Error(w, "Not found", http.StatusNotFound)
+
+		return VoiceCall{}, false
+	}
+
+	return call, true
+}
+
+// isInvalidNotification reports whether the notification was rejected for what the client asked for, e.g. an
+// unknown destination, rather than for failing to send it.
+func isInvalidNotification(err error) bool {
+	return errors.Is(err, ErrUnknownDestination) || errors.Is(err, ErrWebhookURLNotAllowed) ||
+		errors.Is(err, ErrMissingWebhookURL) || errors.Is(err, ErrMissingTelegramChat) ||
+		errors.Is(err, ErrUnknownChatEndpoint) || errors.Is(err, ErrMissingMatrixRoom) ||
+		errors.Is(err, ErrUnknownMatrixRoom) || errors.Is(err, ErrUnsupportedSMSFeature) ||
+		errors.Is(err, ErrPushServiceNotAllowed) || isTwilioRejection(err)
+}
+
+// newRequestValidator creates validator knowing the validations specific to the requests.
+func newRequestValidator() *validator.Validate {
+	v := validator.New()
+
+	//nolint: errcheck
+	v.RegisterValidation("telegram_chat_id", func(fl validator.FieldLevel) bool {
+		return telegramChatIDPattern.MatchString(fl.Field().String())
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("matrix_room", func(fl validator.FieldLevel) bool {
+		return matrixRoomPattern.MatchString(fl.Field().String())
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("sms_address", func(fl validator.FieldLevel) bool {
+		return v.Var(strings.TrimPrefix(fl.Field().String(), _whatsappPrefix), "e164") == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("webpush_p256dh", func(fl validator.FieldLevel) bool {
+		_, err := parseP256DH(fl.Field().String())
+
+		return err == nil
+	})
+
+	//nolint: errcheck
+	v.RegisterValidation("webpush_auth", func(fl validator.FieldLevel) bool {
+		_, err := parseAuthSecret(fl.Field().String())
+
+		return err == nil
+	})
+
+	return v
+}
+
+// validateRequest validates the request body. Requests addressed to a destination are sent to the address of the
+// destination, so that their address field, if any, is left out.
+func validateRequest(v *validator.Validate, body any, destination, addressField string) error {
+	if destination == "" || addressField == "" {
+		return v.Struct(body)
+	}
+
+	return v.StructExcept(body, addressField)
+}
+
+// writeQueued responds that the notification was persisted to be sent after restart.
+func writeQueued(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusAccepted)
+
+	//nolint: errcheck
+	w.Write([]byte(`{"status": "Notification queued."}`))
+}
+
+// MakeDisabledChannelEndpoint creates endpoint answering requests to a channel which is not enabled.
+func MakeDisabledChannelEndpoint(channel string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		http.Error(w, fmt.Sprintf(`{"error": "Channel %s is not enabled."}`, channel), http.StatusNotImplemented)
+	}
+}
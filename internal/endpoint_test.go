@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kkereziev/notifier/internal"
@@ -20,10 +21,10 @@ import (
 
 const _dotEnvFileName = ".env.test"
 
-var logger *log.Logger
+var logger *slog.Logger
 
 func init() {
-	logger = log.New(io.Discard, "", log.LstdFlags)
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 }
 
 func TestSlackNotificationPositiveCases(t *testing.T) {
@@ -255,14 +256,14 @@ func TestSMSNotificationNegativeCases(t *testing.T) {
 			requestBody:        &internal.SMSRequestBody{SendToNumber: "+35988357997"},
 			expectedStatusCode: http.StatusBadRequest,
 			//nolint: lll
-			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.Message' Error:Field validation for 'Message' failed on the 'required' tag"}`,
+			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.Message' Error:Field validation for 'Message' failed on the 'required_without_all' tag"}`,
 		},
 		{
 			name:               "request should fail on validation because of invalid number",
 			requestBody:        &internal.SMSRequestBody{Message: "Hello", SendToNumber: "35"},
 			expectedStatusCode: http.StatusBadRequest,
 			//nolint: lll
-			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.SendToNumber' Error:Field validation for 'SendToNumber' failed on the 'e164' tag"}`,
+			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.SendToNumber' Error:Field validation for 'SendToNumber' failed on the 'sms_address' tag"}`,
 		},
 		{
 			name:               "request should fail on validation because of missing number",
@@ -271,6 +272,22 @@ func TestSMSNotificationNegativeCases(t *testing.T) {
 			//nolint: lll
 			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.SendToNumber' Error:Field validation for 'SendToNumber' failed on the 'required' tag"}`,
 		},
+		{
+			name:               "request should fail on validation because of invalid WhatsApp number",
+			requestBody:        &internal.SMSRequestBody{Message: "Hello", SendToNumber: "whatsapp:35"},
+			expectedStatusCode: http.StatusBadRequest,
+			//nolint: lll
+			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.SendToNumber' Error:Field validation for 'SendToNumber' failed on the 'sms_address' tag"}`,
+		},
+		{
+			name: "request should fail on validation because of message sent with content template",
+			requestBody: &internal.SMSRequestBody{
+				Message: "Hello", SendToNumber: "whatsapp:+35988357997", ContentSID: "HX" + strings.Repeat("0", 32),
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			//nolint: lll
+			expectedResponseMessage: `{"error": "Key: 'SMSRequestBody.Message' Error:Field validation for 'Message' failed on the 'excluded_with' tag"}`,
+		},
 	}
 
 	if err := loadEnv(); err != nil {
@@ -492,6 +509,78 @@ func TestMailNotificationNegativeCases(t *testing.T) {
 	}
 }
 
+func TestDisabledChannels(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.SlackEnabled = false
+	config.Twilio.Enabled = false
+
+	notifierMock := &mocks.NotifierMock{
+		NotifyMailFunc: func(ctx context.Context, ifaceVal any) error {
+			return nil
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock)
+
+	type test struct {
+		name               string
+		url                string
+		requestBody        any
+		expectedStatusCode int
+	}
+
+	tests := []test{
+		{
+			name:               "disabled Slack channel should not be implemented",
+			url:                "/api/v1/slack",
+			requestBody:        &internal.SlackRequestBody{Message: "Hello"},
+			expectedStatusCode: http.StatusNotImplemented,
+		},
+		{
+			name:               "disabled SMS channel should not be implemented",
+			url:                "/api/v1/sms",
+			requestBody:        &internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"},
+			expectedStatusCode: http.StatusNotImplemented,
+		},
+		{
+			name:               "enabled mail channel should notify successfully",
+			url:                "/api/v1/mail",
+			requestBody:        &internal.MailRequestBody{Message: "Hello", SendTo: "example@gmail.com", Subject: "Test"},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := json.Marshal(tc.requestBody)
+			if err != nil {
+				t.Fatalf("failed to marshal request: %v", err)
+			}
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, tc.url, bytes.NewBuffer(payload)))
+
+			if res.Result().StatusCode != tc.expectedStatusCode {
+				t.Fatalf("Different status codes, expected: %v, got: %v", tc.expectedStatusCode, res.Result().StatusCode)
+			}
+		})
+	}
+
+	if len(notifierMock.NotifySlackCalls()) != 0 || len(notifierMock.NotifySMSCalls()) != 0 {
+		t.Fatal("Expected disabled channels not to be notified")
+	}
+}
+
 func loadEnv() error {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -507,3 +596,26 @@ func loadEnv() error {
 
 	return nil
 }
+
+// newTestConfig loads the test configuration without throttling and rate limiting, with short retries, and lets
+// mutate adjust it to the test.
+func newTestConfig(t *testing.T, mutate func(config *internal.Config)) *internal.Config {
+	t.Helper()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Throttle = internal.ThrottleConfig{}
+	config.Retry = internal.RequestRetryConfig{MaxRetries: 3, Delay: 100 * time.Millisecond}
+	config.RateLimit = internal.RateLimitConfig{}
+
+	mutate(config)
+
+	return config
+}
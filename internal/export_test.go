@@ -0,0 +1,9 @@
+package internal
+
+import "net/http"
+
+// SetWebPushClient replaces the client web push notifications are sent with, so that tests can trust the
+// certificate of their push service.
+func (s *Service) SetWebPushClient(client *http.Client) {
+	s.providers.Load().webPush.client = client
+}
@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_fcmScope     = "https://www.googleapis.com/auth/firebase.messaging"
+	_fcmGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	_fcmTokenURL  = "https://oauth2.googleapis.com/token"
+	// _fcmTokenLifetime is the longest lifetime of access tokens Google issues for service accounts.
+	_fcmTokenLifetime = time.Hour
+)
+
+// serviceAccount holds the fields of the JSON key of a Google service account used for FCM.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// parseServiceAccount parses the JSON key of the service account.
+func parseServiceAccount(s string) (serviceAccount, *rsa.PrivateKey, error) {
+	var account serviceAccount
+	if err := json.Unmarshal([]byte(s), &account); err != nil {
+		return serviceAccount{}, nil, fmt.Errorf("invalid service account JSON: %v", err)
+	}
+
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return serviceAccount{}, nil, errors.New("service account has no project_id, client_email or private_key")
+	}
+
+	if account.TokenURI == "" {
+		account.TokenURI = _fcmTokenURL
+	}
+
+	signer, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return serviceAccount{}, nil, err
+	}
+
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return serviceAccount{}, nil, errors.New("service account key is not an RSA key")
+	}
+
+	return account, key, nil
+}
+
+// FCM holds Firebase Cloud Messaging related configuration for sending push notifications through the HTTP v1 API.
+type FCM struct {
+	baseURL     string
+	projectID   string
+	clientEmail string
+	tokenURL    string
+	key         *rsa.PrivateKey
+	client      *http.Client
+	throttle    *Throttle
+	token       *cachedToken
+}
+
+func newFCM(config FCMConfig) *FCM {
+	// The configuration is validated, the service account is known to parse.
+	account, key, _ := parseServiceAccount(config.ServiceAccount)
+
+	f := &FCM{
+		baseURL:     config.BaseURL,
+		projectID:   account.ProjectID,
+		clientEmail: account.ClientEmail,
+		tokenURL:    account.TokenURI,
+		key:         key,
+		client:      newProviderClient(),
+		token:       &cachedToken{},
+	}
+
+	if config.ProjectID != "" {
+		f.projectID = config.ProjectID
+	}
+
+	if config.TokenURL != "" {
+		f.tokenURL = config.TokenURL
+	}
+
+	return f
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification *fcmNotification  `json:"notification,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroidConfig `json:"android,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroidConfig struct {
+	Priority string `json:"priority,omitempty"`
+	// TTL is a duration in seconds with the s suffix, e.g. 3600s.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// fcmErrorResponse is the body of failed requests, the error code in the details tells why the message was rejected.
+type fcmErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// newFCMMessage maps the notification to a message of the HTTP v1 API, TTL and priority apply to Android devices.
+func newFCMMessage(token string, body *PushRequestBody) fcmRequest {
+	msg := fcmMessage{Token: token, Data: body.Data}
+
+	if body.Title != "" || body.Body != "" {
+		msg.Notification = &fcmNotification{Title: body.Title, Body: body.Body}
+	}
+
+	if body.Priority != "" || body.TTL != nil {
+		msg.Android = &fcmAndroidConfig{Priority: strings.ToUpper(body.Priority)}
+
+		if body.TTL != nil {
+			msg.Android.TTL = strconv.Itoa(*body.TTL) + "s"
+		}
+	}
+
+	return fcmRequest{Message: msg}
+}
+
+// NotifyFCM sends push notification to the device token through Firebase Cloud Messaging.
+func (s *Service) NotifyFCM(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_fcmChannel, _fcmProvider, err) }()
+
+	fcm := s.providers.Load().fcm
+	if fcm == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _fcmChannel)
+	}
+
+	body := msg.(*PushRequestBody)
+
+	payload, err := json.Marshal(newFCMMessage(body.Token, body))
+	if err != nil {
+		return fmt.Errorf("failed to marshal FCM message: %v", err)
+	}
+
+	release, err := fcm.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule FCM notification: %w", err)
+	}
+	defer release()
+
+	token, err := fcm.token.get(ctx, fcm.issueToken)
+	if err != nil {
+		return fmt.Errorf("failed to authorize FCM notification: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/v1/projects/%s/messages:send", strings.TrimSuffix(fcm.baseURL, "/"),
+		url.PathEscape(fcm.projectID))
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	err = sendProviderRequest(ctx, fcm.client, req, _fcmChannel, _fcmProvider,
+		func(resp *http.Response, data []byte) error {
+			return fcm.checkResponse(token, resp, data)
+		},
+	)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send FCM notification: %w", err), token)
+	}
+
+	LoggerFromContext(ctx).Debug("FCM notification sent")
+
+	return nil
+}
+
+// checkResponse checks the response of the HTTP v1 API. Unregistered tokens are reported as ErrUnregisteredToken,
+// rejected access tokens are dropped so that the retry issues a new one and throttled messages are retried after the
+// Retry-After header.
+func (f *FCM) checkResponse(token string, resp *http.Response, data []byte) error {
+	err := responseStatusError(resp)
+	if err == nil {
+		return nil
+	}
+
+	var res fcmErrorResponse
+
+	//nolint: errcheck
+	json.Unmarshal(data, &res)
+
+	reason := res.Error.Status
+
+	for _, d := range res.Error.Details {
+		if d.ErrorCode != "" {
+			reason = d.ErrorCode
+		}
+	}
+
+	switch {
+	case reason == "UNREGISTERED":
+		return Permanent(fmt.Errorf("%w: %s", ErrUnregisteredToken, res.Error.Message))
+	case resp.StatusCode == http.StatusUnauthorized:
+		f.token.invalidate(token)
+
+		return fmt.Errorf("access token rejected: %s", res.Error.Message)
+	}
+
+	if reason != "" {
+		err = fmt.Errorf("%w: %s: %s", err, reason, res.Error.Message)
+	}
+
+	if after, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && after > 0 {
+		return RetryAfter(err, time.Duration(after)*time.Second)
+	}
+
+	return err
+}
+
+// issueToken exchanges a JWT signed with the key of the service account for an access token.
+func (f *FCM) issueToken(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+
+	assertion, err := signJWT(
+		map[string]string{"alg": "RS256", "typ": "JWT"},
+		map[string]any{
+			"iss":   f.clientEmail,
+			"scope": _fcmScope,
+			"aud":   f.tokenURL,
+			"iat":   now.Unix(),
+			"exp":   now.Add(_fcmTokenLifetime).Unix(),
+		},
+		f.key,
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	form := url.Values{"grant_type": {_fcmGrantType}, "assertion": {assertion}}
+
+	req, err := http.NewRequest(http.MethodPost, f.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	err = sendProviderRequest(ctx, f.client, req, _fcmChannel, _fcmProvider,
+		func(resp *http.Response, data []byte) error {
+			if err := responseStatusError(resp); err != nil {
+				return fmt.Errorf("token request failed: %w: %s", err, strings.TrimSpace(string(data)))
+			}
+
+			return json.Unmarshal(data, &res)
+		},
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if res.AccessToken == "" {
+		return "", time.Time{}, errors.New("token response has no access token")
+	}
+
+	// The token is renewed a minute before it expires, so that it doesn't expire in flight.
+	return res.AccessToken, now.Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute), nil
+}
+
+// checkFCM verifies an access token can be issued for the service account.
+func (s *Service) checkFCM(ctx context.Context) error {
+	fcm := s.providers.Load().fcm
+
+	_, err := fcm.token.get(ctx, fcm.issueToken)
+
+	return err
+}
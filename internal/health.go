@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	_livenessURL       = "/healthz"
+	_readinessURL      = "/readyz"
+	_providerHealthURL = "/healthz/providers"
+
+	_healthStatusOK    = "ok"
+	_healthStatusError = "error"
+)
+
+// ErrShuttingDown is reported by readiness once the server has started shutting down.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// HealthCheck checks a single dependency of the service.
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+// HealthReport is the response body of health endpoints.
+type HealthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type healthResult struct {
+	err       error
+	checkedAt time.Time
+}
+
+// Health tracks liveness and readiness of the service and the reachability of its providers.
+//
+// Health is safe for concurrent use by multiple goroutines.
+type Health struct {
+	config       HealthConfig
+	shuttingDown atomic.Bool
+
+	mu        sync.Mutex
+	readiness []HealthCheck
+	providers []HealthCheck
+	cache     map[string]healthResult
+}
+
+// NewHealth is a constructor function for Health.
+func NewHealth(config HealthConfig) *Health {
+	return &Health{
+		config: config,
+		cache:  make(map[string]healthResult),
+	}
+}
+
+// AddReadinessCheck registers a check of a dependency the service can't serve requests without, e.g. a store.
+func (h *Health) AddReadinessCheck(checks ...HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.readiness = append(h.readiness, checks...)
+}
+
+// AddProviderCheck registers a deep check of a notification provider.
+func (h *Health) AddProviderCheck(checks ...HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.providers = append(h.providers, checks...)
+}
+
+// SetShuttingDown marks the service as not ready, so that no new traffic is routed to it.
+func (h *Health) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Readiness runs the readiness checks.
+func (h *Health) Readiness(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	checks := h.readiness
+	h.mu.Unlock()
+
+	report := h.run(ctx, checks, false)
+	report.Checks["config"] = _healthStatusOK
+
+	if h.shuttingDown.Load() {
+		report.Status = _healthStatusError
+		report.Checks["shutdown"] = ErrShuttingDown.Error()
+	}
+
+	return report
+}
+
+// Providers runs the provider checks, results are cached for the configured TTL.
+func (h *Health) Providers(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	checks := h.providers
+	h.mu.Unlock()
+
+	return h.run(ctx, checks, true)
+}
+
+func (h *Health) run(ctx context.Context, checks []HealthCheck, cached bool) HealthReport {
+	report := HealthReport{
+		Status: _healthStatusOK,
+		Checks: make(map[string]string, len(checks)),
+	}
+
+	results := make([]error, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+
+			results[i] = h.check(ctx, check, cached)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	for i, check := range checks {
+		report.Checks[check.Name] = _healthStatusOK
+
+		if results[i] != nil {
+			report.Status = _healthStatusError
+			report.Checks[check.Name] = results[i].Error()
+		}
+	}
+
+	return report
+}
+
+func (h *Health) check(ctx context.Context, check HealthCheck, cached bool) error {
+	if cached {
+		h.mu.Lock()
+		result, ok := h.cache[check.Name]
+		h.mu.Unlock()
+
+		if ok && time.Since(result.checkedAt) < h.config.CacheTTL {
+			return result.err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.config.CheckTimeout)
+	defer cancel()
+
+	err := check.Check(ctx)
+
+	if cached {
+		h.mu.Lock()
+		h.cache[check.Name] = healthResult{err: err, checkedAt: time.Now()}
+		h.mu.Unlock()
+	}
+
+	return err
+}
+
+// MakeLivenessEndpoint creates endpoint reporting that the process is alive.
+func MakeLivenessEndpoint() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, HealthReport{Status: _healthStatusOK})
+	}
+}
+
+// MakeReadinessEndpoint creates endpoint reporting whether the service can accept traffic.
+func MakeReadinessEndpoint(health *Health) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, health.Readiness(r.Context()))
+	}
+}
+
+// MakeProviderHealthEndpoint creates endpoint reporting whether the notification providers are reachable.
+func MakeProviderHealthEndpoint(health *Health) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, health.Providers(r.Context()))
+	}
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if report.Status != _healthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	//nolint: errcheck
+	json.NewEncoder(w).Encode(report)
+}
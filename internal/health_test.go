@@ -0,0 +1,203 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var providerCalls atomic.Int32
+
+	health := internal.NewHealth(internal.HealthConfig{CheckTimeout: time.Second, CacheTTL: time.Minute})
+	health.AddProviderCheck(internal.HealthCheck{
+		Name: "provider",
+		Check: func(ctx context.Context) error {
+			providerCalls.Add(1)
+
+			return errors.New("unreachable")
+		},
+	})
+
+	mux := internal.NewMux(config, logger, &mocks.NotifierMock{}, internal.WithHealth(health))
+
+	type test struct {
+		name               string
+		url                string
+		shuttingDown       bool
+		expectedStatusCode int
+		expectedChecks     map[string]string
+	}
+
+	tests := []test{
+		{
+			name:               "liveness should report ok",
+			url:                "/healthz",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "readiness should report ok before shutdown",
+			url:                "/readyz",
+			expectedStatusCode: http.StatusOK,
+			expectedChecks:     map[string]string{"config": "ok"},
+		},
+		{
+			name:               "provider checks should report failing provider",
+			url:                "/healthz/providers",
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedChecks:     map[string]string{"provider": "unreachable"},
+		},
+		{
+			name:               "readiness should fail after shutdown started",
+			url:                "/readyz",
+			shuttingDown:       true,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedChecks:     map[string]string{"shutdown": internal.ErrShuttingDown.Error()},
+		},
+		{
+			name:               "liveness should report ok after shutdown started",
+			url:                "/healthz",
+			shuttingDown:       true,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		if tc.shuttingDown {
+			health.SetShuttingDown()
+		}
+
+		res := httptest.NewRecorder()
+
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf(
+				"%s: different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode, res.Result().StatusCode,
+			)
+		}
+
+		var report internal.HealthReport
+		if err := json.NewDecoder(res.Result().Body).Decode(&report); err != nil {
+			t.Fatalf("%s: failed to decode health report: %v", tc.name, err)
+		}
+
+		for check, status := range tc.expectedChecks {
+			if report.Checks[check] != status {
+				t.Fatalf("%s: different status of %s, expected: %s, got: %s", tc.name, check, status, report.Checks[check])
+			}
+		}
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz/providers", nil))
+
+	if providerCalls.Load() != 1 {
+		t.Fatalf("Expected provider check result to be cached, got %d calls", providerCalls.Load())
+	}
+}
+
+func TestSlackHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name        string
+		status      int
+		expectError bool
+	}
+
+	tests := []test{
+		{name: "webhook responding with client error should be reachable", status: http.StatusBadRequest},
+		{
+			name:        "webhook responding with server error should be unreachable",
+			status:      http.StatusBadGateway,
+			expectError: true,
+		},
+	}
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			config, err := internal.NewConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config.SlackWebHookURL = server.URL
+
+			for _, check := range internal.NewService(config).HealthChecks() {
+				if check.Name != "slack_webhook" {
+					continue
+				}
+
+				err := check.Check(context.Background())
+				if (err != nil) != tc.expectError {
+					t.Fatalf("Expected error: %v, got: %v", tc.expectError, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreReadinessChecks(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	pending, err := internal.NewFilePendingStore(filepath.Join(dir, "pending"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptions, err := internal.NewFileSubscriptionStore(filepath.Join(dir, "subscriptions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	health := internal.NewHealth(internal.HealthConfig{CheckTimeout: time.Second})
+	health.AddReadinessCheck(
+		internal.HealthCheck{Name: "pending_store", Check: pending.Check},
+		internal.HealthCheck{Name: "subscription_store", Check: subscriptions.Check},
+	)
+
+	if report := health.Readiness(context.Background()); report.Status != "ok" {
+		t.Fatalf("Expected stores to be ready, got: %+v", report)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "pending")); err != nil {
+		t.Fatal(err)
+	}
+
+	report := health.Readiness(context.Background())
+	if report.Status != "error" || report.Checks["pending_store"] == "ok" || report.Checks["subscription_store"] != "ok" {
+		t.Fatalf("Expected missing pending directory to fail readiness, got: %+v", report)
+	}
+}
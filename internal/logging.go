@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const (
+	_logFormatJSON = "json"
+	_logFormatText = "text"
+)
+
+type loggerContextKey struct{}
+
+// NewLogger is a constructor function for the structured logger of the application.
+func NewLogger(config LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %v", config.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch config.Format {
+	case _logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case _logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", config.Format)
+	}
+}
+
+// WithLogger stores request-scoped logger in the context.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext retrieves the request-scoped logger stored in the context, falling back to the default logger.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+
+	return logger
+}
@@ -0,0 +1,106 @@
+package internal_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes of the logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func TestRequestScopedLogging(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Retry.Delay = time.Millisecond
+
+	var out syncBuffer
+
+	jsonLogger, err := internal.NewLogger(internal.LogConfig{Level: "info", Format: "json"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+			calls++
+			if calls == 1 {
+				return errors.New("failed")
+			}
+
+			return nil
+		},
+	}
+
+	mux := internal.NewMux(config, jsonLogger, notifierMock)
+
+	payload, err := json.Marshal(&internal.SlackRequestBody{Message: "Hello"})
+	if err != nil {
+		t.Fatalf("failed to marshal Slack message: %v", err)
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload)))
+
+	lines := map[string]map[string]any{}
+
+	scanner := bufio.NewScanner(&out.buf)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Expected JSON log line, got: %s", scanner.Text())
+		}
+
+		lines[line["msg"].(string)] = line
+	}
+
+	for _, msg := range []string{"request started", "attempt failed, retrying", "request completed"} {
+		if _, ok := lines[msg]; !ok {
+			t.Fatalf("Expected %q to be logged, got: %v", msg, lines)
+		}
+
+		if lines[msg]["trace_id"] != lines["request started"]["trace_id"] {
+			t.Fatalf("Expected %q to be logged with request trace id, got: %v", msg, lines[msg])
+		}
+	}
+
+	completed := lines["request completed"]
+
+	if completed["status"] != float64(http.StatusOK) {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusOK, completed["status"])
+	}
+
+	if completed["size"] == float64(0) {
+		t.Fatalf("Expected response size to be logged, got: %v", completed["size"])
+	}
+}
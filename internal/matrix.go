@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_matrixClientAPI = "/_matrix/client/v3"
+	// _matrixHTMLFormat is the format of the formatted body of messages.
+	_matrixHTMLFormat = "org.matrix.custom.html"
+)
+
+var (
+	// ErrMissingMatrixRoom is returned when a notification names no room and none is configured.
+	ErrMissingMatrixRoom = errors.New("matrix room is missing")
+
+	// ErrUnknownMatrixRoom is returned when the alias of the room of a notification is not known to the homeserver.
+	ErrUnknownMatrixRoom = errors.New("unknown matrix room")
+)
+
+// matrixRoomPattern matches IDs of rooms, e.g. !abc:example.com, and their aliases, e.g. #alerts:example.com.
+var matrixRoomPattern = regexp.MustCompile(`^[!#][^:\s]+:[^\s]+$`)
+
+// Matrix holds configuration for sending notifications to Matrix rooms through the client-server API.
+type Matrix struct {
+	homeserverURL string
+	accessToken   string
+	room          string
+	client        *http.Client
+	throttle      *Throttle
+	// rooms caches the IDs of the resolved aliases.
+	rooms sync.Map
+}
+
+// matrixMessage is the content of m.room.message events.
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// matrixResponse holds the fields of the responses of the client-server API read by the service.
+type matrixResponse struct {
+	EventID      string `json:"event_id"`
+	RoomID       string `json:"room_id"`
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMS int64  `json:"retry_after_ms"`
+}
+
+// MatrixTransactionID derives the transaction ID the notification is sent with from its ID. The homeserver ignores
+// repeated sends with the same transaction ID, so that a notification is posted once however often it is retried.
+func MatrixTransactionID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+
+	return "notifier-" + hex.EncodeToString(sum[:16])
+}
+
+// NotifyMatrix sends the notification as m.room.message event to the room of the notification or the configured one.
+func (s *Service) NotifyMatrix(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_matrixChannel, _matrixProvider, err) }()
+
+	matrix := s.providers.Load().matrix
+	if matrix == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _matrixChannel)
+	}
+
+	body := msg.(*MatrixRequestBody)
+
+	room := body.Room
+	if room == "" {
+		room = matrix.room
+	}
+
+	if room == "" {
+		return Permanent(ErrMissingMatrixRoom)
+	}
+
+	content := matrixMessage{MsgType: "m.text", Body: body.Message}
+	if body.Notice {
+		content.MsgType = "m.notice"
+	}
+
+	if body.HTML != "" {
+		content.Format = _matrixHTMLFormat
+		content.FormattedBody = body.HTML
+	}
+
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Matrix message: %v", err)
+	}
+
+	release, err := matrix.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule Matrix notification: %w", err)
+	}
+	defer release()
+
+	roomID, err := matrix.resolveRoom(ctx, room)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to resolve Matrix room: %w", err), matrix.accessToken)
+	}
+
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%s", url.PathEscape(roomID), MatrixTransactionID(body.ID))
+
+	var res matrixResponse
+
+	err = matrix.do(ctx, http.MethodPut, path, payload, &res)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send Matrix notification: %w", err), matrix.accessToken)
+	}
+
+	LoggerFromContext(ctx).Debug("Matrix notification sent", "id", body.ID, "event_id", res.EventID)
+
+	return nil
+}
+
+// resolveRoom returns the ID of the room, resolving aliases through the room directory of the homeserver.
+func (m *Matrix) resolveRoom(ctx context.Context, room string) (string, error) {
+	if !strings.HasPrefix(room, "#") {
+		return room, nil
+	}
+
+	if roomID, ok := m.rooms.Load(room); ok {
+		return roomID.(string), nil
+	}
+
+	var res matrixResponse
+	if err := m.do(ctx, http.MethodGet, "/directory/room/"+url.PathEscape(room), nil, &res); err != nil {
+		if res.ErrCode == "M_NOT_FOUND" {
+			return "", Permanent(fmt.Errorf("%w: %s", ErrUnknownMatrixRoom, room))
+		}
+
+		return "", err
+	}
+
+	if res.RoomID == "" {
+		return "", fmt.Errorf("alias %s resolved to no room", room)
+	}
+
+	m.rooms.Store(room, res.RoomID)
+
+	return res.RoomID, nil
+}
+
+// do calls the client-server API, decoding the response into res. Rate limited requests are retried after the delay
+// the homeserver asks for, other client errors are permanent.
+func (m *Matrix) do(ctx context.Context, method, path string, payload []byte, res *matrixResponse) error {
+	u := strings.TrimSuffix(m.homeserverURL, "/") + _matrixClientAPI + path
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return sendProviderRequest(ctx, m.client, req, _matrixChannel, _matrixProvider,
+		func(resp *http.Response, data []byte) error {
+			//nolint: errcheck
+			json.Unmarshal(data, res)
+
+			err := responseStatusError(resp)
+			if err == nil {
+				return nil
+			}
+
+			if res.ErrCode != "" {
+				err = fmt.Errorf("%w: %s: %s", err, res.ErrCode, res.Error)
+			}
+
+			if res.RetryAfterMS > 0 {
+				return RetryAfter(err, time.Duration(res.RetryAfterMS)*time.Millisecond)
+			}
+
+			return err
+		},
+	)
+}
+
+// checkMatrix verifies the access token with the whoami endpoint.
+func (s *Service) checkMatrix(ctx context.Context) error {
+	matrix := s.providers.Load().matrix
+
+	var res matrixResponse
+	if err := matrix.do(ctx, http.MethodGet, "/account/whoami", nil, &res); err != nil {
+		return redactError(err, matrix.accessToken)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// matrixEvent is the content of m.room.message events checked by the tests.
+type matrixEvent struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// homeserver fakes the client-server API of a Matrix homeserver. Events are stored by room and transaction ID, like
+// homeservers do to make sends idempotent.
+type homeserver struct {
+	mu          sync.Mutex
+	events      map[string]matrixEvent
+	sends       int
+	resolves    int
+	rateLimited bool
+}
+
+func (h *homeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errcode": "M_UNKNOWN_TOKEN", "error": "Invalid access token"}`)) //nolint: errcheck
+
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/_matrix/client/v3")
+
+	switch {
+	case r.Method == http.MethodGet && path == "/directory/room/%23alerts:example.com":
+		h.resolves++
+
+		w.Write([]byte(`{"room_id": "!alerts:example.com", "servers": ["example.com"]}`)) //nolint: errcheck
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/directory/room/"):
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errcode": "M_NOT_FOUND", "error": "Room alias not found"}`)) //nolint: errcheck
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/rooms/%21forbidden:example.com/"):
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errcode": "M_FORBIDDEN", "error": "User not in room"}`)) //nolint: errcheck
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/rooms/"):
+		h.sends++
+
+		if h.rateLimited {
+			h.rateLimited = false
+
+			w.WriteHeader(http.StatusTooManyRequests)
+			//nolint: errcheck
+			w.Write([]byte(`{"errcode": "M_LIMIT_EXCEEDED", "error": "Too many requests", "retry_after_ms": 300}`))
+
+			return
+		}
+
+		var event matrixEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if _, ok := h.events[path]; !ok {
+			h.events[path] = event
+		}
+
+		fmt.Fprintf(w, `{"event_id": "$%d"}`, len(h.events))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestMatrixNotification(t *testing.T) {
+	t.Parallel()
+
+	hs := &homeserver{events: make(map[string]matrixEvent)}
+
+	server := httptest.NewServer(hs)
+	defer server.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Matrix = internal.MatrixConfig{
+			Enabled: true, HomeserverURL: server.URL, AccessToken: "access-token", Room: "!ops:example.com",
+		}
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	type test struct {
+		name               string
+		requestBody        any
+		rateLimited        bool
+		expectedStatusCode int
+		expectedSends      int
+		expectedEvents     int
+	}
+
+	tests := []test{
+		{
+			name: "formatted message should be sent to the configured room",
+			requestBody: &internal.MatrixRequestBody{
+				ID: "incident-1", Message: "Disk full", HTML: "<b>Disk full</b>",
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedSends:      1,
+			expectedEvents:     1,
+		},
+		{
+			name:               "repeated notification should be sent with the same transaction",
+			requestBody:        &internal.MatrixRequestBody{ID: "incident-1", Message: "Disk full"},
+			expectedStatusCode: http.StatusOK,
+			expectedSends:      2,
+			expectedEvents:     1,
+		},
+		{
+			name: "notice should be sent to the room of the alias",
+			requestBody: &internal.MatrixRequestBody{
+				ID: "incident-2", Message: "Disk cleaned", Room: "#alerts:example.com", Notice: true,
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedSends:      3,
+			expectedEvents:     2,
+		},
+		{
+			name:               "rate limited message should be retried after the delay",
+			requestBody:        &internal.MatrixRequestBody{Message: "Hello", Room: "#alerts:example.com"},
+			rateLimited:        true,
+			expectedStatusCode: http.StatusOK,
+			expectedSends:      5,
+			expectedEvents:     3,
+		},
+		{
+			name:               "message to a room the user is not in should not be retried",
+			requestBody:        &internal.MatrixRequestBody{Message: "Hello", Room: "!forbidden:example.com"},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedSends:      5,
+			expectedEvents:     3,
+		},
+		{
+			name:               "message to an unknown alias should be rejected",
+			requestBody:        &internal.MatrixRequestBody{Message: "Hello", Room: "#unknown:example.com"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedSends:      5,
+			expectedEvents:     3,
+		},
+		{
+			name:               "message to an invalid room should be rejected",
+			requestBody:        &internal.MatrixRequestBody{Message: "Hello", Room: "alerts"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedSends:      5,
+			expectedEvents:     3,
+		},
+	}
+
+	for _, tc := range tests {
+		hs.mu.Lock()
+		hs.rateLimited = tc.rateLimited
+		hs.mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/matrix", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		hs.mu.Lock()
+		sends, events := hs.sends, len(hs.events)
+		hs.mu.Unlock()
+
+		if sends != tc.expectedSends || events != tc.expectedEvents {
+			t.Fatalf("%s: Expected %d sends of %d events, got: %d sends of %d events", tc.name, tc.expectedSends,
+				tc.expectedEvents, sends, events)
+		}
+	}
+
+	if hs.resolves != 1 {
+		t.Fatalf("Expected alias to be resolved once, got: %d", hs.resolves)
+	}
+
+	txnID := internal.MatrixTransactionID("incident-1")
+
+	event := hs.events["/rooms/%21ops:example.com/send/m.room.message/"+txnID]
+	if event != (matrixEvent{
+		MsgType: "m.text", Body: "Disk full", Format: "org.matrix.custom.html", FormattedBody: "<b>Disk full</b>",
+	}) {
+		t.Fatalf("Expected formatted message in the configured room, got: %+v", hs.events)
+	}
+
+	txnID = internal.MatrixTransactionID("incident-2")
+
+	event = hs.events["/rooms/%21alerts:example.com/send/m.room.message/"+txnID]
+	if event != (matrixEvent{MsgType: "m.notice", Body: "Disk cleaned"}) {
+		t.Fatalf("Expected notice in the room of the alias, got: %+v", hs.events)
+	}
+}
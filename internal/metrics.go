@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	_metricsNamespace = "notifier"
+
+	_slackProvider    = "slack_webhook"
+	_twilioProvider   = "twilio"
+	_smtpProvider     = "smtp"
+	_webhookProvider  = "webhook"
+	_teamsProvider    = "teams_webhook"
+	_discordProvider  = "discord_webhook"
+	_telegramProvider = "telegram_bot"
+	_chatProvider     = "chat_webhook"
+	_matrixProvider   = "matrix"
+	_fcmProvider      = "fcm"
+	_apnsProvider     = "apns"
+	_webPushProvider  = "web_push"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: _metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: _metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: _metricsNamespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being handled.",
+	})
+
+	notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: _metricsNamespace,
+		Name:      "notifications_total",
+		Help:      "Number of notification sends by result.",
+	}, []string{"channel", "provider", "result"})
+
+	providerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: _metricsNamespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of requests to notification providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"channel", "provider"})
+
+	retryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: _metricsNamespace,
+		Name:      "retry_attempts_total",
+		Help:      "Number of attempts made by Retry by result.",
+	}, []string{"channel", "result"})
+
+	voiceCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: _metricsNamespace,
+		Name:      "voice_calls_total",
+		Help:      "Number of finished voice calls by status reported by Twilio.",
+	}, []string{"status"})
+)
+
+type channelContextKey struct{}
+
+// WithChannel stores the name of the notification channel in the context, it is used to label
+// the metrics of everything done on behalf of the channel.
+func WithChannel(ctx context.Context, channel string) context.Context {
+	return context.WithValue(ctx, channelContextKey{}, channel)
+}
+
+// ChannelFromContext retrieves the name of the notification channel stored in the context.
+func ChannelFromContext(ctx context.Context) string {
+	channel, ok := ctx.Value(channelContextKey{}).(string)
+	if !ok {
+		return "unknown"
+	}
+
+	return channel
+}
+
+// MetricsMiddleware records count, latency and in-flight number of requests.
+func MetricsMiddleware(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		tNow := time.Now()
+		rw := newResponseWriter(w)
+
+		next(rw, r, m)
+
+		route := httptreemux.ContextRoute(r.Context())
+		status := strconv.Itoa(rw.status)
+
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(tNow).Seconds())
+	}
+}
+
+func observeRetryAttempt(ctx context.Context, err error) {
+	retryAttempts.WithLabelValues(ChannelFromContext(ctx), result(err, "success", "failure")).Inc()
+}
+
+func observeNotification(channel, provider string, err error) {
+	notifications.WithLabelValues(channel, provider, result(err, "sent", "failed")).Inc()
+}
+
+func observeVoiceCall(status string) {
+	voiceCalls.WithLabelValues(status).Inc()
+}
+
+func observeProviderDuration(channel, provider string, start time.Time) {
+	providerDuration.WithLabelValues(channel, provider).Observe(time.Since(start).Seconds())
+}
+
+func result(err error, success, failure string) string {
+	if err != nil {
+		return failure
+	}
+
+	return success
+}
+
+// responseWriter captures status code and size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader captures the status code of the response.
+func (rw *responseWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write captures the size of the response.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+
+	return n, err
+}
@@ -0,0 +1,68 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+			return nil
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock)
+
+	payload, err := json.Marshal(&internal.SlackRequestBody{Message: "Hello"})
+	if err != nil {
+		t.Fatalf("failed to marshal Slack message: %v", err)
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload)))
+
+	res := httptest.NewRecorder()
+
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if res.Result().StatusCode != http.StatusOK {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusOK, res.Result().StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Result().Body)
+	if err != nil {
+		t.Fatalf("Error reading response body: %v", err)
+	}
+
+	expectedSeries := []string{
+		`notifier_http_requests_total{method="POST",route="/api/v1/slack",status="200"}`,
+		`notifier_retry_attempts_total{channel="slack",result="success"}`,
+		`notifier_http_requests_in_flight`,
+	}
+
+	for _, series := range expectedSeries {
+		if !strings.Contains(string(body), series) {
+			t.Fatalf("Expected metrics to contain %s, got:\n%s", series, body)
+		}
+	}
+}
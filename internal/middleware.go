@@ -1,13 +1,14 @@
 package internal
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CORSMiddleware setups CORS for pre-flight requests.
@@ -16,7 +17,8 @@ func CORSMiddleware(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "POST")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+CorrelationIDHeader+", "+RequestIDHeader)
+			w.Header().Set("Access-Control-Expose-Headers", CorrelationIDHeader)
 			w.WriteHeader(http.StatusNoContent)
 
 			return
@@ -27,7 +29,7 @@ func CORSMiddleware(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 }
 
 // RecoverMiddleware recovers the server from unexpected crashes.
-func RecoverMiddleware(logger *log.Logger) func(httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+func RecoverMiddleware(logger *slog.Logger) func(httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
 			// Defer a function to recover from a panic and set the err return
@@ -37,7 +39,7 @@ func RecoverMiddleware(logger *log.Logger) func(httptreemux.HandlerFunc) httptre
 					trace := debug.Stack()
 
 					// Stack trace will be provided.
-					logger.Printf("PANIC [%v] TRACE[%s]", rec, trace)
+					logger.Error("panic recovered", "panic", rec, "trace", string(trace))
 				}
 			}()
 
@@ -46,21 +48,31 @@ func RecoverMiddleware(logger *log.Logger) func(httptreemux.HandlerFunc) httptre
 	}
 }
 
-// LoggingMiddleware logs request and response objects.
-func LoggingMiddleware(logger *log.Logger) func(httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+// LoggingMiddleware logs request and response objects and stores request-scoped logger in the request context.
+func LoggingMiddleware(logger *slog.Logger) func(httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
-			traceID := uuid.New()
 			tNow := time.Now().UTC()
 
-			log.Println(
-				"request started", "trace id", traceID, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr,
+			traceID := uuid.NewString()
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				traceID = sc.TraceID().String()
+			}
+
+			requestLogger := logger.With("trace_id", traceID, "correlation_id", CorrelationIDFromContext(r.Context()))
+
+			requestLogger.Info("request started",
+				"method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr,
 			)
 
-			next(w, r, m)
+			rw := newResponseWriter(w)
 
-			log.Println("request completed", "trace ID", traceID, "method", r.Method,
-				"path", r.URL.Path, "remoteaddr", r.RemoteAddr, "since", time.Since(tNow))
+			next(rw, r.WithContext(WithLogger(r.Context(), requestLogger)), m)
+
+			requestLogger.Info("request completed",
+				"method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr,
+				"status", rw.status, "size", rw.size, "since", time.Since(tNow),
+			)
 		}
 	}
 }
@@ -19,15 +19,45 @@ var _ internal.Notifier = &NotifierMock{}
 //
 //		// make and configure a mocked internal.Notifier
 //		mockedNotifier := &NotifierMock{
+//			NotifyAPNSFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyAPNS method")
+//			},
+//			NotifyChatFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyChat method")
+//			},
+//			NotifyDiscordFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyDiscord method")
+//			},
+//			NotifyFCMFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyFCM method")
+//			},
 //			NotifyMailFunc: func(contextMoqParam context.Context, ifaceVal any) error {
 //				panic("mock out the NotifyMail method")
 //			},
+//			NotifyMatrixFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyMatrix method")
+//			},
 //			NotifySMSFunc: func(contextMoqParam context.Context, ifaceVal any) error {
 //				panic("mock out the NotifySMS method")
 //			},
 //			NotifySlackFunc: func(contextMoqParam context.Context, ifaceVal any) error {
 //				panic("mock out the NotifySlack method")
 //			},
+//			NotifyTeamsFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyTeams method")
+//			},
+//			NotifyTelegramFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyTelegram method")
+//			},
+//			NotifyVoiceFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyVoice method")
+//			},
+//			NotifyWebPushFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyWebPush method")
+//			},
+//			NotifyWebhookFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+//				panic("mock out the NotifyWebhook method")
+//			},
 //		}
 //
 //		// use mockedNotifier in code that requires internal.Notifier
@@ -35,17 +65,75 @@ var _ internal.Notifier = &NotifierMock{}
 //
 //	}
 type NotifierMock struct {
+	// NotifyAPNSFunc mocks the NotifyAPNS method.
+	NotifyAPNSFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyChatFunc mocks the NotifyChat method.
+	NotifyChatFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyDiscordFunc mocks the NotifyDiscord method.
+	NotifyDiscordFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyFCMFunc mocks the NotifyFCM method.
+	NotifyFCMFunc func(contextMoqParam context.Context, ifaceVal any) error
+
 	// NotifyMailFunc mocks the NotifyMail method.
 	NotifyMailFunc func(contextMoqParam context.Context, ifaceVal any) error
 
+	// NotifyMatrixFunc mocks the NotifyMatrix method.
+	NotifyMatrixFunc func(contextMoqParam context.Context, ifaceVal any) error
+
 	// NotifySMSFunc mocks the NotifySMS method.
 	NotifySMSFunc func(contextMoqParam context.Context, ifaceVal any) error
 
 	// NotifySlackFunc mocks the NotifySlack method.
 	NotifySlackFunc func(contextMoqParam context.Context, ifaceVal any) error
 
+	// NotifyTeamsFunc mocks the NotifyTeams method.
+	NotifyTeamsFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyTelegramFunc mocks the NotifyTelegram method.
+	NotifyTelegramFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyVoiceFunc mocks the NotifyVoice method.
+	NotifyVoiceFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyWebPushFunc mocks the NotifyWebPush method.
+	NotifyWebPushFunc func(contextMoqParam context.Context, ifaceVal any) error
+
+	// NotifyWebhookFunc mocks the NotifyWebhook method.
+	NotifyWebhookFunc func(contextMoqParam context.Context, ifaceVal any) error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// NotifyAPNS holds details about calls to the NotifyAPNS method.
+		NotifyAPNS []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyChat holds details about calls to the NotifyChat method.
+		NotifyChat []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyDiscord holds details about calls to the NotifyDiscord method.
+		NotifyDiscord []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyFCM holds details about calls to the NotifyFCM method.
+		NotifyFCM []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
 		// NotifyMail holds details about calls to the NotifyMail method.
 		NotifyMail []struct {
 			// ContextMoqParam is the contextMoqParam argument value.
@@ -53,6 +141,13 @@ type NotifierMock struct {
 			// IfaceVal is the ifaceVal argument value.
 			IfaceVal any
 		}
+		// NotifyMatrix holds details about calls to the NotifyMatrix method.
+		NotifyMatrix []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
 		// NotifySMS holds details about calls to the NotifySMS method.
 		NotifySMS []struct {
 			// ContextMoqParam is the contextMoqParam argument value.
@@ -67,10 +162,199 @@ type NotifierMock struct {
 			// IfaceVal is the ifaceVal argument value.
 			IfaceVal any
 		}
+		// NotifyTeams holds details about calls to the NotifyTeams method.
+		NotifyTeams []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyTelegram holds details about calls to the NotifyTelegram method.
+		NotifyTelegram []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyVoice holds details about calls to the NotifyVoice method.
+		NotifyVoice []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyWebPush holds details about calls to the NotifyWebPush method.
+		NotifyWebPush []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+		// NotifyWebhook holds details about calls to the NotifyWebhook method.
+		NotifyWebhook []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// IfaceVal is the ifaceVal argument value.
+			IfaceVal any
+		}
+	}
+	lockNotifyAPNS     sync.RWMutex
+	lockNotifyChat     sync.RWMutex
+	lockNotifyDiscord  sync.RWMutex
+	lockNotifyFCM      sync.RWMutex
+	lockNotifyMail     sync.RWMutex
+	lockNotifyMatrix   sync.RWMutex
+	lockNotifySMS      sync.RWMutex
+	lockNotifySlack    sync.RWMutex
+	lockNotifyTeams    sync.RWMutex
+	lockNotifyTelegram sync.RWMutex
+	lockNotifyVoice    sync.RWMutex
+	lockNotifyWebPush  sync.RWMutex
+	lockNotifyWebhook  sync.RWMutex
+}
+
+// NotifyAPNS calls NotifyAPNSFunc.
+func (mock *NotifierMock) NotifyAPNS(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyAPNSFunc == nil {
+		panic("NotifierMock.NotifyAPNSFunc: method is nil but Notifier.NotifyAPNS was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyAPNS.Lock()
+	mock.calls.NotifyAPNS = append(mock.calls.NotifyAPNS, callInfo)
+	mock.lockNotifyAPNS.Unlock()
+	return mock.NotifyAPNSFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyAPNSCalls gets all the calls that were made to NotifyAPNS.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyAPNSCalls())
+func (mock *NotifierMock) NotifyAPNSCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyAPNS.RLock()
+	calls = mock.calls.NotifyAPNS
+	mock.lockNotifyAPNS.RUnlock()
+	return calls
+}
+
+// NotifyChat calls NotifyChatFunc.
+func (mock *NotifierMock) NotifyChat(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyChatFunc == nil {
+		panic("NotifierMock.NotifyChatFunc: method is nil but Notifier.NotifyChat was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyChat.Lock()
+	mock.calls.NotifyChat = append(mock.calls.NotifyChat, callInfo)
+	mock.lockNotifyChat.Unlock()
+	return mock.NotifyChatFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyChatCalls gets all the calls that were made to NotifyChat.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyChatCalls())
+func (mock *NotifierMock) NotifyChatCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyChat.RLock()
+	calls = mock.calls.NotifyChat
+	mock.lockNotifyChat.RUnlock()
+	return calls
+}
+
+// NotifyDiscord calls NotifyDiscordFunc.
+func (mock *NotifierMock) NotifyDiscord(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyDiscordFunc == nil {
+		panic("NotifierMock.NotifyDiscordFunc: method is nil but Notifier.NotifyDiscord was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyDiscord.Lock()
+	mock.calls.NotifyDiscord = append(mock.calls.NotifyDiscord, callInfo)
+	mock.lockNotifyDiscord.Unlock()
+	return mock.NotifyDiscordFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyDiscordCalls gets all the calls that were made to NotifyDiscord.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyDiscordCalls())
+func (mock *NotifierMock) NotifyDiscordCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyDiscord.RLock()
+	calls = mock.calls.NotifyDiscord
+	mock.lockNotifyDiscord.RUnlock()
+	return calls
+}
+
+// NotifyFCM calls NotifyFCMFunc.
+func (mock *NotifierMock) NotifyFCM(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyFCMFunc == nil {
+		panic("NotifierMock.NotifyFCMFunc: method is nil but Notifier.NotifyFCM was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
 	}
-	lockNotifyMail  sync.RWMutex
-	lockNotifySMS   sync.RWMutex
-	lockNotifySlack sync.RWMutex
+	mock.lockNotifyFCM.Lock()
+	mock.calls.NotifyFCM = append(mock.calls.NotifyFCM, callInfo)
+	mock.lockNotifyFCM.Unlock()
+	return mock.NotifyFCMFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyFCMCalls gets all the calls that were made to NotifyFCM.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyFCMCalls())
+func (mock *NotifierMock) NotifyFCMCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyFCM.RLock()
+	calls = mock.calls.NotifyFCM
+	mock.lockNotifyFCM.RUnlock()
+	return calls
 }
 
 // NotifyMail calls NotifyMailFunc.
@@ -109,6 +393,42 @@ func (mock *NotifierMock) NotifyMailCalls() []struct {
 	return calls
 }
 
+// NotifyMatrix calls NotifyMatrixFunc.
+func (mock *NotifierMock) NotifyMatrix(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyMatrixFunc == nil {
+		panic("NotifierMock.NotifyMatrixFunc: method is nil but Notifier.NotifyMatrix was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyMatrix.Lock()
+	mock.calls.NotifyMatrix = append(mock.calls.NotifyMatrix, callInfo)
+	mock.lockNotifyMatrix.Unlock()
+	return mock.NotifyMatrixFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyMatrixCalls gets all the calls that were made to NotifyMatrix.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyMatrixCalls())
+func (mock *NotifierMock) NotifyMatrixCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyMatrix.RLock()
+	calls = mock.calls.NotifyMatrix
+	mock.lockNotifyMatrix.RUnlock()
+	return calls
+}
+
 // NotifySMS calls NotifySMSFunc.
 func (mock *NotifierMock) NotifySMS(contextMoqParam context.Context, ifaceVal any) error {
 	if mock.NotifySMSFunc == nil {
@@ -180,3 +500,183 @@ func (mock *NotifierMock) NotifySlackCalls() []struct {
 	mock.lockNotifySlack.RUnlock()
 	return calls
 }
+
+// NotifyTeams calls NotifyTeamsFunc.
+func (mock *NotifierMock) NotifyTeams(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyTeamsFunc == nil {
+		panic("NotifierMock.NotifyTeamsFunc: method is nil but Notifier.NotifyTeams was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyTeams.Lock()
+	mock.calls.NotifyTeams = append(mock.calls.NotifyTeams, callInfo)
+	mock.lockNotifyTeams.Unlock()
+	return mock.NotifyTeamsFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyTeamsCalls gets all the calls that were made to NotifyTeams.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyTeamsCalls())
+func (mock *NotifierMock) NotifyTeamsCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyTeams.RLock()
+	calls = mock.calls.NotifyTeams
+	mock.lockNotifyTeams.RUnlock()
+	return calls
+}
+
+// NotifyTelegram calls NotifyTelegramFunc.
+func (mock *NotifierMock) NotifyTelegram(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyTelegramFunc == nil {
+		panic("NotifierMock.NotifyTelegramFunc: method is nil but Notifier.NotifyTelegram was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyTelegram.Lock()
+	mock.calls.NotifyTelegram = append(mock.calls.NotifyTelegram, callInfo)
+	mock.lockNotifyTelegram.Unlock()
+	return mock.NotifyTelegramFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyTelegramCalls gets all the calls that were made to NotifyTelegram.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyTelegramCalls())
+func (mock *NotifierMock) NotifyTelegramCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyTelegram.RLock()
+	calls = mock.calls.NotifyTelegram
+	mock.lockNotifyTelegram.RUnlock()
+	return calls
+}
+
+// NotifyVoice calls NotifyVoiceFunc.
+func (mock *NotifierMock) NotifyVoice(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyVoiceFunc == nil {
+		panic("NotifierMock.NotifyVoiceFunc: method is nil but Notifier.NotifyVoice was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyVoice.Lock()
+	mock.calls.NotifyVoice = append(mock.calls.NotifyVoice, callInfo)
+	mock.lockNotifyVoice.Unlock()
+	return mock.NotifyVoiceFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyVoiceCalls gets all the calls that were made to NotifyVoice.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyVoiceCalls())
+func (mock *NotifierMock) NotifyVoiceCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyVoice.RLock()
+	calls = mock.calls.NotifyVoice
+	mock.lockNotifyVoice.RUnlock()
+	return calls
+}
+
+// NotifyWebPush calls NotifyWebPushFunc.
+func (mock *NotifierMock) NotifyWebPush(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyWebPushFunc == nil {
+		panic("NotifierMock.NotifyWebPushFunc: method is nil but Notifier.NotifyWebPush was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyWebPush.Lock()
+	mock.calls.NotifyWebPush = append(mock.calls.NotifyWebPush, callInfo)
+	mock.lockNotifyWebPush.Unlock()
+	return mock.NotifyWebPushFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyWebPushCalls gets all the calls that were made to NotifyWebPush.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyWebPushCalls())
+func (mock *NotifierMock) NotifyWebPushCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyWebPush.RLock()
+	calls = mock.calls.NotifyWebPush
+	mock.lockNotifyWebPush.RUnlock()
+	return calls
+}
+
+// NotifyWebhook calls NotifyWebhookFunc.
+func (mock *NotifierMock) NotifyWebhook(contextMoqParam context.Context, ifaceVal any) error {
+	if mock.NotifyWebhookFunc == nil {
+		panic("NotifierMock.NotifyWebhookFunc: method is nil but Notifier.NotifyWebhook was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}{
+		ContextMoqParam: contextMoqParam,
+		IfaceVal:        ifaceVal,
+	}
+	mock.lockNotifyWebhook.Lock()
+	mock.calls.NotifyWebhook = append(mock.calls.NotifyWebhook, callInfo)
+	mock.lockNotifyWebhook.Unlock()
+	return mock.NotifyWebhookFunc(contextMoqParam, ifaceVal)
+}
+
+// NotifyWebhookCalls gets all the calls that were made to NotifyWebhook.
+// Check the length with:
+//
+//	len(mockedNotifier.NotifyWebhookCalls())
+func (mock *NotifierMock) NotifyWebhookCalls() []struct {
+	ContextMoqParam context.Context
+	IfaceVal        any
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		IfaceVal        any
+	}
+	mock.lockNotifyWebhook.RLock()
+	calls = mock.calls.NotifyWebhook
+	mock.lockNotifyWebhook.RUnlock()
+	return calls
+}
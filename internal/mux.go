@@ -2,16 +2,52 @@ package internal
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"net/http"
 
 	"github.com/dimfeld/httptreemux/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
-	_apiURLPattern    = "/api/v1"
-	_slackEndpointURL = "/slack"
-	_smsEndpointURL   = "/sms"
-	_mailEndpointURL  = "/mail"
+	_apiURLPattern       = "/api/v1"
+	_slackEndpointURL    = "/slack"
+	_smsEndpointURL      = "/sms"
+	_mailEndpointURL     = "/mail"
+	_webhookEndpointURL  = "/webhook"
+	_teamsEndpointURL    = "/teams"
+	_discordEndpointURL  = "/discord"
+	_telegramEndpointURL = "/telegram"
+	_chatEndpointURL     = "/chat"
+	_matrixEndpointURL   = "/matrix"
+	_fcmEndpointURL      = "/fcm"
+	_apnsEndpointURL     = "/apns"
+	_webPushEndpointURL  = "/webpush"
+	// Subscriptions and VAPID key are served under the web push endpoint.
+	_subscriptionsURL = "/subscriptions"
+	_vapidKeyURL      = "/vapid-key"
+	_voiceEndpointURL = "/voice"
+	// Calls are looked up under the voice endpoint.
+	_voiceCallURL = "/calls/:id"
+	// Twilio calls back outside of the API, it can't authenticate with API keys.
+	_voiceCallbackURLPattern = "/callbacks/voice"
+	_voiceStatusURL          = "/status"
+	_voiceGatherURL          = "/gather"
+	_metricsURL              = "/metrics"
+
+	_slackChannel    = "slack"
+	_smsChannel      = "sms"
+	_mailChannel     = "mail"
+	_webhookChannel  = "webhook"
+	_teamsChannel    = "teams"
+	_discordChannel  = "discord"
+	_telegramChannel = "telegram"
+	_chatChannel     = "chat"
+	_matrixChannel   = "matrix"
+	_fcmChannel      = "fcm"
+	_apnsChannel     = "apns"
+	_webPushChannel  = "webpush"
+	_voiceChannel    = "voice"
 )
 
 // SlackNotifier manages sending of notification via Slack.
@@ -29,6 +65,56 @@ type MailNotifier interface {
 	NotifyMail(context.Context, any) error
 }
 
+// WebhookNotifier manages sending of notification via webhooks.
+type WebhookNotifier interface {
+	NotifyWebhook(context.Context, any) error
+}
+
+// TeamsNotifier manages sending of notification via Microsoft Teams.
+type TeamsNotifier interface {
+	NotifyTeams(context.Context, any) error
+}
+
+// DiscordNotifier manages sending of notification via Discord.
+type DiscordNotifier interface {
+	NotifyDiscord(context.Context, any) error
+}
+
+// TelegramNotifier manages sending of notification via Telegram.
+type TelegramNotifier interface {
+	NotifyTelegram(context.Context, any) error
+}
+
+// ChatNotifier manages sending of notification via Mattermost and Rocket.Chat.
+type ChatNotifier interface {
+	NotifyChat(context.Context, any) error
+}
+
+// MatrixNotifier manages sending of notification via Matrix.
+type MatrixNotifier interface {
+	NotifyMatrix(context.Context, any) error
+}
+
+// FCMNotifier manages sending of push notification via Firebase Cloud Messaging.
+type FCMNotifier interface {
+	NotifyFCM(context.Context, any) error
+}
+
+// APNSNotifier manages sending of push notification via Apple Push Notification service.
+type APNSNotifier interface {
+	NotifyAPNS(context.Context, any) error
+}
+
+// WebPushNotifier manages sending of notification via Web Push.
+type WebPushNotifier interface {
+	NotifyWebPush(context.Context, any) error
+}
+
+// VoiceNotifier manages placing of voice calls.
+type VoiceNotifier interface {
+	NotifyVoice(context.Context, any) error
+}
+
 // Notifier is manages notification sending.
 //
 // Implementations of Notifier must be save for concurrent use by multiple goroutines.
@@ -38,25 +124,319 @@ type Notifier interface {
 	SlackNotifier
 	SMSNotifier
 	MailNotifier
+	WebhookNotifier
+	TeamsNotifier
+	DiscordNotifier
+	TelegramNotifier
+	ChatNotifier
+	MatrixNotifier
+	FCMNotifier
+	APNSNotifier
+	WebPushNotifier
+	VoiceNotifier
+}
+
+// MuxOption customizes dependencies of the multiplexer.
+type MuxOption func(*muxOptions)
+
+type muxOptions struct {
+	rateLimiter   RateLimiter
+	health        *Health
+	pendingStore  PendingStore
+	tenants       *Tenants
+	admin         *Admin
+	subscriptions SubscriptionStore
+	calls         CallStore
+}
+
+// WithRateLimiter sets the RateLimiter used for incoming requests, by default limits are kept in memory.
+func WithRateLimiter(limiter RateLimiter) MuxOption {
+	return func(o *muxOptions) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithHealth sets Health reported by the health endpoints, by default only liveness and shutdown are reported.
+func WithHealth(health *Health) MuxOption {
+	return func(o *muxOptions) {
+		o.health = health
+	}
+}
+
+// WithPendingStore sets the store notifications interrupted by shutdown are persisted to, by default they fail.
+func WithPendingStore(store PendingStore) MuxOption {
+	return func(o *muxOptions) {
+		o.pendingStore = store
+	}
+}
+
+// WithTenants serves the tenants, each authenticated by its API keys and sending through its own providers with its
+// own rate limits. By default a single tenant is served through notifier.
+func WithTenants(tenants *Tenants) MuxOption {
+	return func(o *muxOptions) {
+		o.tenants = tenants
+	}
+}
+
+// WithAdmin serves the admin API managing destinations and provider credentials, by default it is disabled and
+// notifications can't be addressed to destinations.
+func WithAdmin(admin *Admin) MuxOption {
+	return func(o *muxOptions) {
+		o.admin = admin
+	}
+}
+
+// WithSubscriptionStore sets the store web push subscriptions are kept in, by default they are kept in memory of
+// the multiplexer.
+func WithSubscriptionStore(store SubscriptionStore) MuxOption {
+	return func(o *muxOptions) {
+		o.subscriptions = store
+	}
+}
+
+// WithCallStore sets the store the state of voice calls is kept in, by default it is kept in memory of the
+// multiplexer.
+func WithCallStore(store CallStore) MuxOption {
+	return func(o *muxOptions) {
+		o.calls = store
+	}
 }
 
 // NewMux is a constructor function for creating new multiplexer for the HTTP server.
-func NewMux(config *Config, logger *log.Logger, notifier Notifier) *httptreemux.ContextMux {
+func NewMux(config *Config, logger *slog.Logger, notifier Notifier, opts ...MuxOption) *httptreemux.ContextMux {
 	mux := httptreemux.NewContextMux()
 
-	registerRoutes(config, logger, mux, notifier)
+	o := muxOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.rateLimiter == nil {
+		o.rateLimiter = NewMemoryRateLimiter()
+	}
+
+	if o.health == nil {
+		o.health = NewHealth(config.Health)
+	}
+
+	if o.tenants == nil {
+		o.tenants = NewTenants()
+	}
+
+	if o.subscriptions == nil {
+		o.subscriptions = NewMemorySubscriptionStore()
+	}
+
+	if o.calls == nil {
+		o.calls = NewMemoryCallStore()
+	}
+
+	registerRoutes(config, logger, mux, notifier, o)
 
 	return mux
 }
 
-func registerRoutes(config *Config, logger *log.Logger, m *httptreemux.ContextMux, notifier Notifier) {
+func registerRoutes(config *Config, logger *slog.Logger, m *httptreemux.ContextMux, notifier Notifier, o muxOptions) {
+	m.Handler(http.MethodGet, _metricsURL, promhttp.Handler())
+	m.GET(_livenessURL, MakeLivenessEndpoint())
+	m.GET(_readinessURL, MakeReadinessEndpoint(o.health))
+	m.GET(_providerHealthURL, MakeProviderHealthEndpoint(o.health))
+
 	g := m.NewGroup(_apiURLPattern)
 
+	g.Use(CorrelationIDMiddleware)
 	g.Use(CORSMiddleware)
 	g.Use(RecoverMiddleware(logger))
+	g.Use(TracingMiddleware)
 	g.Use(LoggingMiddleware(logger))
+	g.Use(MetricsMiddleware)
+
+	g.Use(TenantMiddleware(o.tenants))
+
+	notifier = o.admin.Notifier(o.tenants.Notifier(notifier))
+	destination := BodyFieldKey("destination")
+
+	registerChannel(g, config, o, _slackChannel, _slackEndpointURL, MakeSlackEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_slackChannel, FirstKey(destination, StaticKey(c.SlackWebHookURL)))
+		},
+	)
+
+	registerChannel(g, config, o, _smsChannel, _smsEndpointURL, MakeSMSEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_smsChannel, FirstKey(BodyFieldKey("send_to_number"), destination))
+		},
+	)
+
+	registerChannel(g, config, o, _mailChannel, _mailEndpointURL, MakeMailEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_mailChannel, FirstKey(BodyFieldKey("send_to"), destination))
+		},
+	)
+
+	registerChannel(g, config, o, _webhookChannel, _webhookEndpointURL,
+		MakeWebhookEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_webhookChannel, FirstKey(BodyFieldKey("url"), destination, StaticKey(c.Webhook.URL)))
+		},
+	)
+
+	registerChannel(g, config, o, _teamsChannel, _teamsEndpointURL, MakeTeamsEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_teamsChannel, FirstKey(destination, StaticKey(c.Teams.WebHookURL)))
+		},
+	)
+
+	registerChannel(g, config, o, _discordChannel, _discordEndpointURL,
+		MakeDiscordEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_discordChannel, FirstKey(destination, StaticKey(c.Discord.WebHookURL)))
+		},
+	)
+
+	registerChannel(g, config, o, _telegramChannel, _telegramEndpointURL,
+		MakeTelegramEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_telegramChannel,
+				FirstKey(BodyFieldKey("chat_id"), destination, StaticKey(c.Telegram.ChatID)))
+		},
+	)
+
+	registerChannel(g, config, o, _chatChannel, _chatEndpointURL, MakeChatEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_chatChannel,
+				FirstKey(BodyFieldKey("endpoint"), destination, StaticKey(c.Chat.WebHookURL)))
+		},
+	)
+
+	registerChannel(g, config, o, _matrixChannel, _matrixEndpointURL,
+		MakeMatrixEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_matrixChannel, FirstKey(BodyFieldKey("room"), destination, StaticKey(c.Matrix.Room)))
+		},
+	)
+
+	registerChannel(g, config, o, _fcmChannel, _fcmEndpointURL, MakeFCMEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_fcmChannel, FirstKey(BodyFieldKey("token"), destination))
+		},
+	)
+
+	registerChannel(g, config, o, _apnsChannel, _apnsEndpointURL, MakeAPNSEndpoint(config, notifier, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_apnsChannel, FirstKey(BodyFieldKey("token"), destination))
+		},
+	)
+
+	webPushRules := func(c *Config) []RateLimitRule {
+		return c.RateLimit.Rules(_webPushChannel, BodyFieldKey("user"))
+	}
+
+	registerChannel(g, config, o, _webPushChannel, _webPushEndpointURL,
+		MakeWebPushEndpoint(config, notifier, o.subscriptions, o.pendingStore), webPushRules,
+	)
+
+	registerSubscriptionRoutes(g, config, o, webPushRules)
+
+	registerChannel(g, config, o, _voiceChannel, _voiceEndpointURL,
+		MakeVoiceEndpoint(config, notifier, o.calls, o.pendingStore),
+		func(c *Config) []RateLimitRule {
+			return c.RateLimit.Rules(_voiceChannel, FirstKey(BodyFieldKey("send_to_number"), destination))
+		},
+	)
+
+	registerVoiceRoutes(config, logger, m, g, o)
+
+	if o.admin != nil {
+		registerAdminRoutes(config, logger, m, o.admin)
+	}
+}
+
+func registerAdminRoutes(config *Config, logger *slog.Logger, m *httptreemux.ContextMux, admin *Admin) {
+	g := m.NewGroup(_adminURLPattern)
+
+	g.Use(CorrelationIDMiddleware)
+	g.Use(RecoverMiddleware(logger))
+	g.Use(TracingMiddleware)
+	g.Use(LoggingMiddleware(logger))
+	g.Use(MetricsMiddleware)
+	g.Use(AdminAuthMiddleware(config.Admin.Token))
+
+	g.GET(_credentialsURL, MakeListCredentialsEndpoint(admin))
+	g.POST(_credentialsURL, MakeCreateCredentialEndpoint(admin))
+	g.POST(_rotateCredentialURL, MakeRotateCredentialEndpoint(admin))
+	g.DELETE(_credentialURL, MakeRevokeCredentialEndpoint(admin))
+
+	g.GET(_destinationsURL, MakeListDestinationsEndpoint(admin))
+	g.POST(_destinationsURL, MakeCreateDestinationEndpoint(admin))
+	g.POST(_rotateDestinationURL, MakeRotateDestinationEndpoint(admin))
+	g.DELETE(_destinationURL, MakeRevokeDestinationEndpoint(admin))
+}
+
+// registerSubscriptionRoutes registers the endpoints managing web push subscriptions, limited like the web push
+// channel.
+func registerSubscriptionRoutes(
+	g *httptreemux.ContextGroup, config *Config, o muxOptions, rules func(*Config) []RateLimitRule,
+) {
+	group := g.NewGroup(_webPushEndpointURL)
+
+	if !config.channelEnabled(_webPushChannel) && !o.tenants.channelEnabled(_webPushChannel) {
+		disabled := MakeDisabledChannelEndpoint(_webPushChannel)
+
+		group.GET(_vapidKeyURL, disabled)
+		group.POST(_subscriptionsURL, disabled)
+		group.DELETE(_subscriptionsURL, disabled)
+
+		return
+	}
+
+	group.Use(tenantChannelMiddleware(config, o.tenants, _webPushChannel))
+	group.Use(tenantRateLimitMiddleware(o.rateLimiter, config, o.tenants, rules))
+	group.GET(_vapidKeyURL, MakeVAPIDKeyEndpoint(config, o.tenants))
+	group.POST(_subscriptionsURL, MakeSaveSubscriptionEndpoint(config, o.tenants, o.subscriptions))
+	group.DELETE(_subscriptionsURL, MakeDeleteSubscriptionEndpoint(o.subscriptions))
+}
+
+// registerVoiceRoutes registers the endpoint looking up voice calls and the callbacks Twilio reports the progress of
+// calls to. Callbacks are authenticated by the key of the call in their URL.
+func registerVoiceRoutes(
+	config *Config, logger *slog.Logger, m *httptreemux.ContextMux, g *httptreemux.ContextGroup, o muxOptions,
+) {
+	if !config.channelEnabled(_voiceChannel) && !o.tenants.channelEnabled(_voiceChannel) {
+		return
+	}
+
+	group := g.NewGroup(_voiceEndpointURL)
+	group.Use(tenantChannelMiddleware(config, o.tenants, _voiceChannel))
+	group.GET(_voiceCallURL, MakeVoiceCallEndpoint(o.calls))
+
+	callbacks := m.NewGroup(_voiceCallbackURLPattern)
+
+	callbacks.Use(CorrelationIDMiddleware)
+	callbacks.Use(RecoverMiddleware(logger))
+	callbacks.Use(TracingMiddleware)
+	callbacks.Use(LoggingMiddleware(logger))
+	callbacks.Use(MetricsMiddleware)
+
+	callbacks.POST(_voiceStatusURL, MakeVoiceStatusCallbackEndpoint(o.calls))
+	callbacks.POST(_voiceGatherURL, MakeVoiceGatherCallbackEndpoint(config, o.tenants, o.calls))
+}
+
+// registerChannel registers the endpoint of the channel when the deployment or any tenant has it enabled, limited by
+// the rate limit rules built from the configuration of the tenant of the request.
+func registerChannel(
+	g *httptreemux.ContextGroup, config *Config, o muxOptions, channel, url string, endpoint http.HandlerFunc,
+	rules func(*Config) []RateLimitRule,
+) {
+	// Disabled channels answer with 501 instead of a bare 404, telling the client the channel exists but isn't set up.
+	if !config.channelEnabled(channel) && !o.tenants.channelEnabled(channel) {
+		g.POST(url, MakeDisabledChannelEndpoint(channel))
+
+		return
+	}
 
-	g.POST(_slackEndpointURL, MakeSlackEndpoint(config, notifier))
-	g.POST(_smsEndpointURL, MakeSMSEndpoint(config, notifier))
-	g.POST(_mailEndpointURL, MakeMailEndpoint(config, notifier))
+	group := g.NewGroup(url)
+	group.Use(tenantChannelMiddleware(config, o.tenants, channel))
+	group.Use(tenantRateLimitMiddleware(o.rateLimiter, config, o.tenants, rules))
+	group.POST("", endpoint)
 }
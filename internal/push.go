@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_pushPriorityHigh   = "high"
+	_pushPriorityNormal = "normal"
+)
+
+// ErrUnregisteredToken is returned when the push service reports the device token or web push subscription is no
+// longer valid, e.g. because the app was uninstalled. Destinations and subscriptions with such a token are removed.
+var ErrUnregisteredToken = errors.New("device token is unregistered")
+
+// cachedToken caches an access token of a push service until shortly before it expires.
+type cachedToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// get returns the cached token or a new one from issue, issued tokens are cached until they expire.
+func (c *cachedToken) get(ctx context.Context, issue func(context.Context) (string, time.Time, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	token, expires, err := issue(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.token, c.expires = token, expires
+
+	return token, nil
+}
+
+// invalidate drops the cached token, e.g. when the push service rejects it.
+func (c *cachedToken) invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// signJWT encodes the header and claims as a JWT signed with the key, RS256 for RSA and ES256 for ECDSA keys.
+func signJWT(header, claims any, key crypto.Signer) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	var signature []byte
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		// JWS expects the fixed size concatenation of r and s rather than ASN.1.
+		r, s, serr := ecdsa.Sign(rand.Reader, key, digest[:])
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature, err = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), serr
+	default:
+		err = fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key. Newlines may be escaped as \n, as is
+// common for keys passed through environment variables.
+func parsePrivateKey(s string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(s, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported private key format")
+}
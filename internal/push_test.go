@@ -0,0 +1,377 @@
+package internal_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// pemKey encodes the key as PEM encoded PKCS #8 private key.
+func pemKey(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// fakeFCM fakes the token endpoint of Google and the HTTP v1 API of FCM.
+type fakeFCM struct {
+	mu       sync.Mutex
+	issued   int
+	messages []map[string]any
+	// expireToken makes the next message request fail as if the access token expired.
+	expireToken bool
+}
+
+func (f *fakeFCM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/token":
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" ||
+			strings.Count(r.FormValue("assertion"), ".") != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		f.issued++
+
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, f.issued)
+	case "/v1/projects/notifier-test/messages:send":
+		if f.expireToken || r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", f.issued) {
+			f.expireToken = false
+
+			w.WriteHeader(http.StatusUnauthorized)
+			//nolint: errcheck
+			w.Write([]byte(`{"error": {"code": 401, "message": "Invalid credentials", "status": "UNAUTHENTICATED"}}`))
+
+			return
+		}
+
+		var req struct {
+			Message map[string]any `json:"message"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if req.Message["token"] == "unregistered" {
+			w.WriteHeader(http.StatusNotFound)
+			//nolint: errcheck
+			w.Write([]byte(`{"error": {"code": 404, "message": "Requested entity was not found.", "status": "NOT_FOUND",
+				"details": [{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError", "errorCode": "UNREGISTERED"}]}}`))
+
+			return
+		}
+
+		f.messages = append(f.messages, req.Message)
+
+		fmt.Fprintf(w, `{"name": "projects/notifier-test/messages/%d"}`, len(f.messages))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestFCMNotification(t *testing.T) {
+	t.Parallel()
+
+	fcm := &fakeFCM{}
+
+	server := httptest.NewServer(fcm)
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "notifier-test",
+		"client_email": "notifier@notifier-test.iam.gserviceaccount.com",
+		"private_key":  pemKey(t, key),
+		"token_uri":    server.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.FCM = internal.FCMConfig{Enabled: true, ServiceAccount: string(account), BaseURL: server.URL}
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	ttl := 60
+
+	type test struct {
+		name               string
+		requestBody        any
+		expireToken        bool
+		expectedStatusCode int
+		expectedMessages   int
+		expectedIssued     int
+	}
+
+	tests := []test{
+		{
+			name: "notification should be sent with an issued access token",
+			requestBody: &internal.PushRequestBody{
+				Token: "device", Title: "Hello", Body: "World", Data: map[string]string{"id": "1"}, TTL: &ttl,
+				Priority: "high",
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   1,
+			expectedIssued:     1,
+		},
+		{
+			name:               "access token should be reused",
+			requestBody:        &internal.PushRequestBody{Token: "device", Data: map[string]string{"sync": "true"}},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   2,
+			expectedIssued:     1,
+		},
+		{
+			name:               "rejected access token should be issued again",
+			requestBody:        &internal.PushRequestBody{Token: "device", Title: "Hello"},
+			expireToken:        true,
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   3,
+			expectedIssued:     2,
+		},
+		{
+			name:               "unregistered token should be reported as gone",
+			requestBody:        &internal.PushRequestBody{Token: "unregistered", Title: "Hello"},
+			expectedStatusCode: http.StatusGone,
+			expectedMessages:   3,
+			expectedIssued:     2,
+		},
+		{
+			name: "data overriding the aps dictionary should be rejected",
+			requestBody: &internal.PushRequestBody{
+				Token: "device", Title: "Hello", Data: map[string]string{"aps": "{}"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   3,
+			expectedIssued:     2,
+		},
+		{
+			name:               "unknown priority should be rejected",
+			requestBody:        &internal.PushRequestBody{Token: "device", Title: "Hello", Priority: "urgent"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   3,
+			expectedIssued:     2,
+		},
+	}
+
+	for _, tc := range tests {
+		fcm.mu.Lock()
+		fcm.expireToken = tc.expireToken
+		fcm.mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/fcm", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		fcm.mu.Lock()
+		messages, issued := len(fcm.messages), fcm.issued
+		fcm.mu.Unlock()
+
+		if messages != tc.expectedMessages || issued != tc.expectedIssued {
+			t.Fatalf("%s: Expected %d messages with %d tokens, got: %d messages with %d tokens", tc.name,
+				tc.expectedMessages, tc.expectedIssued, messages, issued)
+		}
+	}
+
+	msg, err := json.Marshal(fcm.messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"android":{"priority":"HIGH","ttl":"60s"},"data":{"id":"1"},` +
+		`"notification":{"body":"World","title":"Hello"},"token":"device"}`
+	if string(msg) != expected {
+		t.Fatalf("Different messages, expected: %s, got: %s", expected, msg)
+	}
+}
+
+// apnsRequest is a request received by the fake APNs server.
+type apnsRequest struct {
+	header  http.Header
+	payload map[string]any
+}
+
+func TestAPNSNotification(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		requests []apnsRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason": "MissingProviderToken"}`)) //nolint: errcheck
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/3/device/0a1b":
+		case "/3/device/dead":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason": "Unregistered", "timestamp": 1700000000000}`)) //nolint: errcheck
+
+			return
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason": "BadDeviceToken"}`)) //nolint: errcheck
+
+			return
+		}
+
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		requests = append(requests, apnsRequest{header: r.Header, payload: payload})
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.APNS = internal.APNSConfig{
+			Enabled: true, Key: pemKey(t, key), KeyID: "KEY123", TeamID: "TEAM123", Topic: "com.example.app",
+			BaseURL: server.URL,
+		}
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	type test struct {
+		name               string
+		requestBody        any
+		expectedStatusCode int
+		expectedHeaders    map[string]string
+		expectedPayload    string
+	}
+
+	tests := []test{
+		{
+			name: "alert should be sent with high priority",
+			requestBody: &internal.PushRequestBody{
+				Token: "0a1b", Title: "Hello", Body: "World", Data: map[string]string{"id": "1"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Apns-Topic": "com.example.app", "Apns-Push-Type": "alert", "Apns-Priority": "10",
+			},
+			expectedPayload: `{"aps":{"alert":{"body":"World","title":"Hello"},"sound":"default"},"id":"1"}`,
+		},
+		{
+			name:               "notification without alert should be sent in the background",
+			requestBody:        &internal.PushRequestBody{Token: "0a1b", Data: map[string]string{"sync": "true"}},
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders:    map[string]string{"Apns-Push-Type": "background", "Apns-Priority": "5"},
+			expectedPayload:    `{"aps":{"content-available":1},"sync":"true"}`,
+		},
+		{
+			name:               "unregistered token should be reported as gone",
+			requestBody:        &internal.PushRequestBody{Token: "dead", Title: "Hello"},
+			expectedStatusCode: http.StatusGone,
+		},
+		{
+			name:               "invalid token should be reported as gone",
+			requestBody:        &internal.PushRequestBody{Token: "beef", Title: "Hello"},
+			expectedStatusCode: http.StatusGone,
+		},
+	}
+
+	for _, tc := range tests {
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/apns", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		if tc.expectedPayload == "" {
+			continue
+		}
+
+		mu.Lock()
+		received := requests
+		mu.Unlock()
+
+		if len(received) != 1 {
+			t.Fatalf("%s: Expected 1 request, got: %d", tc.name, len(received))
+		}
+
+		for k, v := range tc.expectedHeaders {
+			if got := received[0].header.Get(k); got != v {
+				t.Fatalf("%s: Different %s headers, expected: %s, got: %s", tc.name, k, v, got)
+			}
+		}
+
+		got, err := json.Marshal(received[0].payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != tc.expectedPayload {
+			t.Fatalf("%s: Different payloads, expected: %s, got: %s", tc.name, tc.expectedPayload, got)
+		}
+	}
+}
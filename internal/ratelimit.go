@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+const (
+	_apiKeyHeader = "X-API-Key"
+
+	// _maxPeekBodySize limits how much of the request body is read when looking up a destination.
+	_maxPeekBodySize = 1 << 20
+
+	// _memoryRateLimiterSweepSize is the number of tracked keys after which idle buckets are evicted.
+	_memoryRateLimiterSweepSize = 10000
+)
+
+// RateLimit describes a token bucket - Rate tokens are added every second up to Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter manages rate limits shared by all requests with the same key.
+//
+// Implementations of RateLimiter must be safe for concurrent use by multiple goroutines. The in-memory
+// implementation is MemoryRateLimiter, horizontally scaled deployments should provide one backed by shared storage.
+type RateLimiter interface {
+	// Allow takes a token for the given key and reports whether the request may proceed.
+	// When it may not, the returned duration is how long the caller should wait before trying again.
+	Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// RateLimitKeyFunc extracts the key a request is limited by. Empty key means the rule does not apply.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitRule is a single rate limit applied to incoming requests.
+type RateLimitRule struct {
+	Name  string
+	Limit RateLimit
+	Key   RateLimitKeyFunc
+}
+
+// RateLimitMiddleware rejects requests exceeding any of the given rules with 429 Too Many Requests.
+func RateLimitMiddleware(limiter RateLimiter, rules ...RateLimitRule) httptreemux.MiddlewareFunc {
+	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+			for _, rule := range rules {
+				key := rule.Key(r)
+				if key == "" {
+					continue
+				}
+
+				// Keys can be secrets, e.g. API keys or webhook URLs, limiters only get to see their digest.
+				sum := sha256.Sum256([]byte(key))
+
+				allowed, retryAfter, err := limiter.Allow(r.Context(), rule.Name+":"+hex.EncodeToString(sum[:]), rule.Limit)
+				if err != nil {
+					// The limiter backend being unavailable should not take the whole service down.
+					continue
+				}
+
+				if !allowed {
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+					http.Error(w, fmt.Sprintf(`{"error": "Too many requests, rate limit %s exceeded"}`, rule.Name),
+						http.StatusTooManyRequests)
+
+					return
+				}
+			}
+
+			next(w, r, m)
+		}
+	}
+}
+
+// ClientKey identifies the API client by its API key, falling back to its remote address. Only API keys
+// authenticated by TenantMiddleware are used, otherwise clients could bypass the limit by changing the header.
+func ClientKey(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != "" {
+		return key
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// StaticKey limits all requests under the same key.
+func StaticKey(key string) RateLimitKeyFunc {
+	return func(*http.Request) string {
+		return key
+	}
+}
+
+// FirstKey limits requests by the first non-empty key, e.g. the phone number or else the name of the destination.
+func FirstKey(keys ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				return k
+			}
+		}
+
+		return ""
+	}
+}
+
+// BodyFieldKey limits requests by the value of a top level string field in their JSON body.
+// The body is restored afterwards, so that it can be decoded again by the endpoint.
+func BodyFieldKey(field string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if r.Body == nil {
+			return ""
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, _maxPeekBodySize))
+
+		//nolint: errcheck
+		r.Body.Close()
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err != nil {
+			return ""
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return ""
+		}
+
+		value, _ := fields[field].(string)
+
+		return value
+	}
+}
+
+// MemoryRateLimiter is a RateLimiter keeping its token buckets in memory.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewMemoryRateLimiter is a constructor function for MemoryRateLimiter.
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+var _ RateLimiter = (*MemoryRateLimiter)(nil)
+
+// Allow takes a token from the bucket for the given key.
+func (l *MemoryRateLimiter) Allow(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= _memoryRateLimiterSweepSize {
+			l.sweep(now)
+		}
+
+		b = newTokenBucket(now, limit)
+		l.buckets[key] = b
+	}
+
+	allowed, retryAfter := b.allow(now, limit)
+
+	return allowed, retryAfter, nil
+}
+
+// sweep evicts buckets which have refilled completely, since they are indistinguishable from new ones.
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// tokenBucket is not safe for concurrent use, callers must synchronize access to it.
+type tokenBucket struct {
+	tokens float64
+	limit  RateLimit
+	last   time.Time
+}
+
+func newTokenBucket(now time.Time, limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(limit.Burst),
+		limit:  limit,
+		last:   now,
+	}
+}
+
+// refill adds the tokens accumulated since the last call.
+func (b *tokenBucket) refill(now time.Time, limit RateLimit) {
+	b.limit = limit
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+}
+
+// allow takes a token if one is available, otherwise reports how long until one is.
+func (b *tokenBucket) allow(now time.Time, limit RateLimit) (bool, time.Duration) {
+	b.refill(now, limit)
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0
+	}
+
+	return false, b.wait(1 - b.tokens)
+}
+
+// reserve takes a token even when none is available and returns how long the caller has to wait before using it.
+func (b *tokenBucket) reserve(now time.Time, limit RateLimit) time.Duration {
+	b.refill(now, limit)
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return b.wait(-b.tokens)
+}
+
+// cancel returns a reserved token which was not used.
+func (b *tokenBucket) cancel() {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+1)
+}
+
+// wait returns the time needed to accumulate the given amount of tokens.
+func (b *tokenBucket) wait(tokens float64) time.Duration {
+	if b.limit.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(tokens / b.limit.Rate * float64(time.Second))
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now, b.limit)
+
+	return b.tokens >= float64(b.limit.Burst)
+}
@@ -0,0 +1,198 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := internal.NewMemoryRateLimiter()
+	limit := internal.RateLimit{Rate: 1, Burst: 2}
+
+	for i := 0; i < limit.Burst; i++ {
+		allowed, _, err := limiter.Allow(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatalf("Expected error to be nil but got: %s", err)
+		}
+
+		if !allowed {
+			t.Fatalf("Expected request %d to be allowed within burst", i+1)
+		}
+	}
+
+	allowed, retryAfter, err := limiter.Allow(context.Background(), "key", limit)
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if allowed {
+		t.Fatalf("Expected request to be rejected after burst has been exhausted")
+	}
+
+	if retryAfter <= 0 {
+		t.Fatalf("Expected positive retry after, got: %v", retryAfter)
+	}
+
+	allowed, _, err = limiter.Allow(context.Background(), "other key", limit)
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if !allowed {
+		t.Fatalf("Expected request with different key to be allowed")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name               string
+		rateLimit          internal.RateLimitConfig
+		clients            []string
+		apiKeys            []string
+		destinations       []string
+		expectedStatusCode int
+	}
+
+	tests := []test{
+		{
+			name:               "requests of the same client should be limited",
+			rateLimit:          internal.RateLimitConfig{ClientRate: 0.001, ClientBurst: 1},
+			clients:            []string{"192.0.2.1:1234", "192.0.2.1:1234"},
+			destinations:       []string{"+35988357997", "+35988357998"},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "requests of different clients should not share limit",
+			rateLimit:          internal.RateLimitConfig{ClientRate: 0.001, ClientBurst: 1},
+			clients:            []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			destinations:       []string{"+35988357997", "+35988357997"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "requests of the same client should be limited whatever API key they carry",
+			rateLimit:          internal.RateLimitConfig{ClientRate: 0.001, ClientBurst: 1},
+			clients:            []string{"192.0.2.1:1234", "192.0.2.1:1234"},
+			apiKeys:            []string{"client", "other client"},
+			destinations:       []string{"+35988357997", "+35988357998"},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "requests to the same destination should be limited",
+			rateLimit:          internal.RateLimitConfig{DestinationRate: 0.001, DestinationBurst: 1},
+			clients:            []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			destinations:       []string{"+35988357997", "+35988357997"},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "requests to the same channel should be limited",
+			rateLimit:          internal.RateLimitConfig{ChannelRate: 0.001, ChannelBurst: 1},
+			clients:            []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			destinations:       []string{"+35988357997", "+35988357998"},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+	}
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := internal.NewConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config.RateLimit = tc.rateLimit
+
+			notifierMock := &mocks.NotifierMock{
+				NotifySMSFunc: func(contextMoqParam context.Context, ifaceVal any) error {
+					return nil
+				},
+			}
+
+			mux := internal.NewMux(config, logger, notifierMock)
+
+			var res *httptest.ResponseRecorder
+
+			for i, client := range tc.clients {
+				payload, err := json.Marshal(&internal.SMSRequestBody{Message: "Hello", SendToNumber: tc.destinations[i]})
+				if err != nil {
+					t.Fatalf("failed to marshal SMS message: %v", err)
+				}
+
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/sms", bytes.NewBuffer(payload))
+				req.RemoteAddr = client
+
+				if i < len(tc.apiKeys) {
+					req.Header.Set("X-API-Key", tc.apiKeys[i])
+				}
+
+				res = httptest.NewRecorder()
+
+				mux.ServeHTTP(res, req)
+			}
+
+			if res.Result().StatusCode != tc.expectedStatusCode {
+				t.Fatalf("Different status codes, expected: %v, got: %v", tc.expectedStatusCode, res.Result().StatusCode)
+			}
+
+			if tc.expectedStatusCode == http.StatusTooManyRequests && res.Result().Header.Get("Retry-After") == "" {
+				t.Fatalf("Expected Retry-After header to be set")
+			}
+		})
+	}
+}
+
+// keyRecorder is a RateLimiter recording the keys it is asked about.
+type keyRecorder struct {
+	keys []string
+}
+
+func (k *keyRecorder) Allow(_ context.Context, key string, _ internal.RateLimit) (bool, time.Duration, error) {
+	k.keys = append(k.keys, key)
+
+	return true, 0, nil
+}
+
+func TestRateLimitMiddlewareHashesKeys(t *testing.T) {
+	t.Parallel()
+
+	const secret = "https://hooks.slack.com/services/T000/B000/secret"
+
+	limiter := &keyRecorder{}
+	rule := internal.RateLimitRule{
+		Name:  "channel",
+		Limit: internal.RateLimit{Rate: 1, Burst: 1},
+		Key:   internal.StaticKey(secret),
+	}
+
+	handler := internal.RateLimitMiddleware(limiter, rule)(
+		func(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+			w.WriteHeader(http.StatusOK)
+		},
+	)
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/slack", nil), nil)
+
+	if len(limiter.keys) != 1 {
+		t.Fatalf("Expected one key, got: %v", limiter.keys)
+	}
+
+	if !strings.HasPrefix(limiter.keys[0], "channel:") || strings.Contains(limiter.keys[0], secret) {
+		t.Fatalf("Expected the key to be a digest, got: %s", limiter.keys[0])
+	}
+}
@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConfigLoader loads and validates the configuration from its sources.
+type ConfigLoader func() (*Config, error)
+
+// Reloader reloads the configuration without restart. A new configuration is validated before it is applied,
+// changes of fields which require restart are ignored.
+//
+// Reloader is safe for concurrent use by multiple goroutines.
+type Reloader struct {
+	mu      sync.Mutex
+	current *Config
+	load    ConfigLoader
+	apply   []func(*Config)
+	logger  *slog.Logger
+}
+
+// NewReloader is a constructor function for Reloader.
+func NewReloader(current *Config, load ConfigLoader, logger *slog.Logger) *Reloader {
+	return &Reloader{current: current, load: load, logger: logger}
+}
+
+// OnReload registers the function applying a reloaded configuration, functions are called in registration order.
+func (r *Reloader) OnReload(apply func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apply = append(r.apply, apply)
+}
+
+// Current returns the configuration in effect.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload loads the configuration and applies it when it is valid and differs from the current one.
+// On error the current configuration stays in effect.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		r.logger.Error("configuration reload failed, keeping current configuration", "error", err)
+
+		return fmt.Errorf("config reload: %w", err)
+	}
+
+	changes := diffConfig(r.current, next)
+
+	applied := 0
+
+	for _, c := range changes {
+		if c.restart {
+			r.logger.Warn("configuration change requires restart, ignored", "key", c.key, "old", c.old, "new", c.new)
+
+			continue
+		}
+
+		r.logger.Info("configuration changed", "key", c.key, "old", c.old, "new", c.new)
+
+		applied++
+	}
+
+	if applied == 0 {
+		r.logger.Debug("configuration reloaded, nothing to apply")
+
+		return nil
+	}
+
+	for _, apply := range r.apply {
+		apply(next)
+	}
+
+	r.current = next
+
+	r.logger.Info("configuration reloaded", "changes", applied)
+
+	return nil
+}
+
+// WatchFile reloads the configuration whenever the modification time or size of the file changes,
+// checking it every interval until the context is done.
+func (r *Reloader) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			r.logger.Warn("failed to check configuration file", "path", path, "error", err)
+
+			continue
+		}
+
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+
+		last = info
+
+		r.logger.Info("configuration file changed, reloading", "path", path)
+
+		//nolint: errcheck
+		r.Reload()
+	}
+}
+
+// Refresh reloads the configuration every interval until the context is done, picking up rotated secrets.
+func (r *Reloader) Refresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			//nolint: errcheck
+			r.Reload()
+		}
+	}
+}
+
+// configChange is a change of a configuration field, with secrets masked.
+type configChange struct {
+	key     string
+	old     any
+	new     any
+	restart bool
+}
+
+// diffConfig lists the fields which differ between the configurations. Fields requiring restart are reset in
+// next to their current value, so that next describes the configuration actually in effect once applied.
+func diffConfig(current, next *Config) []configChange {
+	changes := diffFields(
+		configFields(reflect.ValueOf(current).Elem(), "", ""),
+		configFields(reflect.ValueOf(next).Elem(), "", ""),
+	)
+
+	return append(changes, diffTenants(current.Tenants, next.Tenants)...)
+}
+
+func diffFields(currentFields, nextFields []configField) []configChange {
+	var changes []configChange
+
+	for i, f := range nextFields {
+		if reflect.DeepEqual(f.value.Interface(), currentFields[i].value.Interface()) {
+			continue
+		}
+
+		changes = append(changes, configChange{
+			key:     f.key,
+			old:     redactedValue(currentFields[i]),
+			new:     redactedValue(f),
+			restart: f.restart,
+		})
+
+		if f.restart {
+			f.value.Set(currentFields[i].value)
+		}
+	}
+
+	return changes
+}
+
+// diffTenants lists the tenants which were added or removed and the changes of the tenants which were kept.
+func diffTenants(current, next []TenantConfig) []configChange {
+	byID := make(map[string]TenantConfig, len(current))
+	for _, t := range current {
+		byID[t.ID] = t
+	}
+
+	var changes []configChange
+
+	for _, t := range next {
+		key := joinConfigKey(_tenantsKey, t.ID)
+
+		prev, ok := byID[t.ID]
+		if !ok {
+			changes = append(changes, configChange{key: key, old: "absent", new: "present"})
+
+			continue
+		}
+
+		delete(byID, t.ID)
+
+		if !slices.Equal(prev.APIKeys, t.APIKeys) {
+			changes = append(changes, configChange{key: joinConfigKey(key, _apiKeysKey), old: _redacted, new: _redacted})
+		}
+
+		changes = append(changes, diffFields(prev.fields(), t.fields())...)
+	}
+
+	for _, id := range sortedKeys(byID) {
+		changes = append(changes, configChange{key: joinConfigKey(_tenantsKey, id), old: "present", new: "absent"})
+	}
+
+	return changes
+}
+
+// ReloadableHandler is an http.Handler whose underlying handler can be swapped atomically, requests in progress
+// complete with the handler they started with.
+type ReloadableHandler struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+// NewReloadableHandler is a constructor function for ReloadableHandler.
+func NewReloadableHandler(h http.Handler) *ReloadableHandler {
+	r := &ReloadableHandler{}
+	r.Swap(h)
+
+	return r
+}
+
+// Swap replaces the handler serving new requests.
+func (r *ReloadableHandler) Swap(h http.Handler) {
+	r.handler.Store(&h)
+}
+
+// ServeHTTP serves the request with the current handler.
+func (r *ReloadableHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	(*r.handler.Load()).ServeHTTP(w, req)
+}
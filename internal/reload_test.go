@@ -0,0 +1,186 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		name            string
+		load            func() (*internal.Config, error)
+		expectedError   bool
+		expectedApplied bool
+		expectedLogs    []string
+	}
+
+	tests := []test{
+		{
+			name: "valid changes should be applied and logged with secrets masked",
+			load: func() (*internal.Config, error) {
+				next := *current
+				next.Retry.MaxRetries = current.Retry.MaxRetries + 1
+				next.Twilio.Token = "new-twilio-token"
+
+				return &next, nil
+			},
+			expectedApplied: true,
+			expectedLogs:    []string{"configuration changed", "key=retry.max_retries", "key=twilio.token"},
+		},
+		{
+			name: "changes requiring restart should be ignored",
+			load: func() (*internal.Config, error) {
+				next := *current
+				next.Server.Port = current.Server.Port + 1
+
+				return &next, nil
+			},
+			expectedLogs: []string{"configuration change requires restart, ignored", "key=server.port"},
+		},
+		{
+			name: "added tenants should be applied",
+			load: func() (*internal.Config, error) {
+				next := *current
+				next.Tenants = []internal.TenantConfig{{ID: "acme", APIKeys: []string{"acme-key"}, Config: current}}
+
+				return &next, nil
+			},
+			expectedApplied: true,
+			expectedLogs:    []string{"configuration changed", "key=tenants.acme"},
+		},
+		{
+			name: "invalid configuration should be rejected",
+			load: func() (*internal.Config, error) {
+				return nil, errors.New("invalid configuration")
+			},
+			expectedError: true,
+			expectedLogs:  []string{"configuration reload failed, keeping current configuration"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out syncBuffer
+
+			reloader := internal.NewReloader(current, tc.load, slog.New(slog.NewTextHandler(&out, nil)))
+
+			var applied *internal.Config
+
+			reloader.OnReload(func(c *internal.Config) { applied = c })
+
+			if err := reloader.Reload(); (err != nil) != tc.expectedError {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectedError, err)
+			}
+
+			if (applied != nil) != tc.expectedApplied {
+				t.Fatalf("Expected configuration to be applied: %v, got: %+v", tc.expectedApplied, applied)
+			}
+
+			if tc.expectedApplied && reloader.Current() != applied {
+				t.Fatal("Expected applied configuration to be current")
+			}
+
+			if !tc.expectedApplied && reloader.Current() != current {
+				t.Fatal("Expected current configuration to stay in effect")
+			}
+
+			logs := out.buf.String()
+
+			for _, expected := range tc.expectedLogs {
+				if !strings.Contains(logs, expected) {
+					t.Fatalf("Expected logs to contain %q, got:\n%s", expected, logs)
+				}
+			}
+
+			if strings.Contains(logs, "new-twilio-token") {
+				t.Fatalf("Expected secrets to be masked, got:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestReloadOnConfigFileChange(t *testing.T) {
+	t.Setenv("MAX_RETRIES", "")
+	t.Setenv("SERVER_PORT", "")
+
+	path := writeConfigFile(t, "config.yaml", _testConfigFile)
+
+	load := func() (*internal.Config, error) {
+		return internal.NewConfig(internal.WithConfigFile(path))
+	}
+
+	current, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloader := internal.NewReloader(current, load, logger)
+
+	applied := make(chan *internal.Config, 1)
+	reloader.OnReload(func(c *internal.Config) { applied <- c })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go reloader.WatchFile(ctx, path, time.Millisecond*10)
+
+	// Give the watcher time to take the initial state of the file.
+	time.Sleep(time.Millisecond * 50)
+
+	changed := strings.Replace(_testConfigFile, "port: 9000", "port: 9001", 1) + "retry:\n  max_retries: 7\n"
+	if err := os.WriteFile(path, []byte(changed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case c := <-applied:
+		if c.Retry.MaxRetries != 7 {
+			t.Fatalf("Expected reloaded retries, got: %v", c.Retry.MaxRetries)
+		}
+
+		if c.Server.Port != current.Server.Port {
+			t.Fatalf("Expected port requiring restart to be kept, got: %v", c.Server.Port)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("Expected configuration to be reloaded on file change")
+	}
+}
+
+func TestReloadableHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := internal.NewReloadableHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.Swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if res.Result().StatusCode != http.StatusAccepted {
+		t.Fatalf("Different status codes, expected: %v, got: %v", http.StatusAccepted, res.Result().StatusCode)
+	}
+}
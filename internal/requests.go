@@ -1,19 +1,220 @@
 package internal
 
+// Destination of the request bodies is the name of a destination managed through the admin API. Notifications
+// addressed to a destination are sent to its address instead of the address of the request, or the configured webhook.
+
 // SlackRequestBody is an object containing data for Slack notification endpoint.
 type SlackRequestBody struct {
-	Message string `validate:"required" json:"message"`
+	Message     string `validate:"required" json:"message"`
+	Destination string `json:"destination,omitempty"`
 }
 
 // SMSRequestBody is an object containing data for SMS notification endpoint.
 type SMSRequestBody struct {
-	Message      string `validate:"required" json:"message"`
-	SendToNumber string `validate:"required,e164" json:"send_to_number"`
+	Message string `validate:"required_without_all=ContentSID MediaURLs,excluded_with=ContentSID" json:"message"`
+	// SendToNumber is a phone number, or a WhatsApp number prefixed with whatsapp:, e.g. whatsapp:+15005550006.
+	SendToNumber string `validate:"required,sms_address" json:"send_to_number"`
+	// MediaURLs are sent as MMS, WhatsApp messages carry a single media.
+	MediaURLs []string `validate:"max=10,excluded_with=ContentSID,dive,http_url" json:"media_urls,omitempty"`
+	// ContentSID is the pre-approved WhatsApp content template sent instead of the message, filled in by
+	// ContentVariables.
+	ContentSID       string            `validate:"omitempty,len=34,startswith=HX,alphanum" json:"content_sid,omitempty"`
+	ContentVariables map[string]string `validate:"excluded_without=ContentSID,max=100" json:"content_variables,omitempty"`
+	Destination      string            `json:"destination,omitempty"`
 }
 
 // MailRequestBody is an object containing data for mail notification endpoint.
 type MailRequestBody struct {
+	Message     string `validate:"required" json:"message"`
+	SendTo      string `validate:"required,email" json:"send_to"`
+	Subject     string `validate:"required" json:"subject"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// WebhookRequestBody is an object containing data for webhook notification endpoint.
+type WebhookRequestBody struct {
+	// ID identifies the notification to the receiver, e.g. to ignore duplicates. It is generated when not set.
+	ID      string         `validate:"omitempty,max=128,printascii" json:"id,omitempty"`
+	Message string         `validate:"required" json:"message"`
+	Event   string         `json:"event,omitempty"`
+	Data    map[string]any `json:"data,omitempty"`
+	// URL is sent to instead of the configured webhook, its host must be allowed by the configuration.
+	URL string `validate:"omitempty,url" json:"url,omitempty"`
+	// Headers are added to the request, on top of the configured ones.
+	Headers     map[string]string `json:"headers,omitempty"`
+	Destination string            `json:"destination,omitempty"`
+}
+
+// TeamsRequestBody is an object containing data for Microsoft Teams notification endpoint.
+type TeamsRequestBody struct {
+	Title       string        `validate:"max=256" json:"title,omitempty"`
+	Text        string        `validate:"required" json:"text"`
+	Facts       []TeamsFact   `validate:"max=20,dive" json:"facts,omitempty"`
+	Actions     []TeamsAction `validate:"max=6,dive" json:"actions,omitempty"`
+	Destination string        `json:"destination,omitempty"`
+}
+
+// TeamsFact is a name and value pair shown in the fact set of the card.
+type TeamsFact struct {
+	Name  string `validate:"required" json:"name"`
+	Value string `validate:"required" json:"value"`
+}
+
+// TeamsAction is a button of the card opening the URL.
+type TeamsAction struct {
+	Title string `validate:"required" json:"title"`
+	URL   string `validate:"required,http_url" json:"url"`
+}
+
+// DiscordRequestBody is an object containing data for Discord notification endpoint. Either content or embeds are
+// required.
+type DiscordRequestBody struct {
+	Content string         `validate:"required_without=Embeds,max=2000" json:"content,omitempty"`
+	Embeds  []DiscordEmbed `validate:"max=10,dive" json:"embeds,omitempty"`
+	// Username and AvatarURL override the name and avatar of the webhook.
+	Username    string `validate:"max=80" json:"username,omitempty"`
+	AvatarURL   string `validate:"omitempty,http_url" json:"avatar_url,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// DiscordEmbed is a rich content block of the message, with a colour of its left border, e.g. #ff0000.
+type DiscordEmbed struct {
+	Title       string         `validate:"max=256" json:"title,omitempty"`
+	Description string         `validate:"max=4096" json:"description,omitempty"`
+	URL         string         `validate:"omitempty,http_url" json:"url,omitempty"`
+	Color       string         `validate:"omitempty,hexcolor" json:"color,omitempty"`
+	Fields      []DiscordField `validate:"max=25,dive" json:"fields,omitempty"`
+}
+
+// DiscordField is a name and value pair of the embed, inline fields are shown side by side.
+type DiscordField struct {
+	Name   string `validate:"required,max=256" json:"name"`
+	Value  string `validate:"required,max=1024" json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// TelegramRequestBody is an object containing data for Telegram notification endpoint. The title and message are
+// escaped for the parse mode, MarkdownV2 by default.
+type TelegramRequestBody struct {
+	Title   string `validate:"max=256" json:"title,omitempty"`
+	Message string `validate:"required,max=4000" json:"message"`
+	// ChatID is the ID of the chat or the username of the channel, e.g. @alerts, sent to instead of the configured one.
+	ChatID    string `validate:"omitempty,telegram_chat_id" json:"chat_id,omitempty"`
+	ParseMode string `validate:"omitempty,oneof=MarkdownV2 HTML" json:"parse_mode,omitempty"`
+	// Silent sends the message without sound.
+	Silent      bool   `json:"silent,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// ChatRequestBody is an object containing data for Mattermost and Rocket.Chat notification endpoint. Either text or
+// attachments are required.
+type ChatRequestBody struct {
+	Text string `validate:"required_without=Attachments,max=16383" json:"text,omitempty"`
+	// Channel overrides the channel of the webhook, e.g. town-square or @username.
+	Channel     string            `validate:"max=64" json:"channel,omitempty"`
+	Username    string            `validate:"max=64" json:"username,omitempty"`
+	IconURL     string            `validate:"omitempty,http_url" json:"icon_url,omitempty"`
+	IconEmoji   string            `validate:"max=64" json:"icon_emoji,omitempty"`
+	Attachments []SlackAttachment `validate:"max=20,dive" json:"attachments,omitempty"`
+	// Props are metadata of the post, only supported by Mattermost.
+	Props map[string]any `json:"props,omitempty"`
+	// Endpoint is the name of a configured webhook, sent to instead of the default one.
+	Endpoint    string `json:"endpoint,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// MatrixRequestBody is an object containing data for Matrix notification endpoint.
+type MatrixRequestBody struct {
+	// ID identifies the notification, it is sent once however often it is retried. It is generated when not set.
+	ID      string `validate:"omitempty,max=128,printascii" json:"id,omitempty"`
 	Message string `validate:"required" json:"message"`
-	SendTo  string `validate:"required,email" json:"send_to"`
-	Subject string `validate:"required" json:"subject"`
+	// HTML is the formatted body of the message, clients not supporting it show the message instead.
+	HTML string `json:"html,omitempty"`
+	// Room is the ID or alias of the room, e.g. #alerts:example.com, sent to instead of the configured one.
+	Room string `validate:"omitempty,matrix_room" json:"room,omitempty"`
+	// Notice sends the message as m.notice, which clients show as sent by a bot.
+	Notice      bool   `json:"notice,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// PushRequestBody is an object containing data for the FCM and APNs push notification endpoints. Notifications
+// without title and body are delivered to the app in the background, with the data only.
+type PushRequestBody struct {
+	Token string            `validate:"required,max=4096,printascii" json:"token"`
+	Title string            `validate:"max=256" json:"title,omitempty"`
+	Body  string            `validate:"max=2048" json:"body,omitempty"`
+	Data  map[string]string `validate:"max=50,dive,keys,required,ne=aps,endkeys" json:"data,omitempty"`
+	// TTL is how long in seconds the push service keeps the notification while the device is offline, 0 means it is
+	// delivered right away or not at all.
+	TTL         *int   `validate:"omitempty,min=0,max=2419200" json:"ttl,omitempty"`
+	Priority    string `validate:"omitempty,oneof=high normal" json:"priority,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// PushSubscription is the PushSubscription of the browser, as serialized by its toJSON method.
+type PushSubscription struct {
+	Endpoint       string               `validate:"required,http_url,startswith=https://,max=2048" json:"endpoint"`
+	ExpirationTime *int64               `json:"expirationTime,omitempty"`
+	Keys           PushSubscriptionKeys `json:"keys"`
+}
+
+// PushSubscriptionKeys are the public key of the browser and the authentication secret the payloads of web push
+// notifications are encrypted with.
+type PushSubscriptionKeys struct {
+	P256DH string `validate:"required,webpush_p256dh" json:"p256dh"`
+	Auth   string `validate:"required,webpush_auth" json:"auth"`
+}
+
+// SubscriptionRequestBody is an object containing data for registering and removing web push subscriptions of a user.
+type SubscriptionRequestBody struct {
+	User         string           `validate:"required,max=256" json:"user"`
+	Subscription PushSubscription `json:"subscription"`
+}
+
+// WebPushRequestBody is an object containing data for web push notification endpoint. Notifications are sent to
+// every subscription of the user, or to the subscription of the request.
+type WebPushRequestBody struct {
+	User         string            `validate:"required_without=Subscription,max=256" json:"user,omitempty"`
+	Subscription *PushSubscription `json:"subscription,omitempty"`
+	Title        string            `validate:"max=256" json:"title,omitempty"`
+	Body         string            `validate:"max=2048" json:"body,omitempty"`
+	// URL is opened when the notification is clicked, if the service worker of the app does so.
+	URL  string            `validate:"omitempty,http_url" json:"url,omitempty"`
+	Data map[string]string `validate:"max=50,dive,keys,required,endkeys" json:"data,omitempty"`
+	// TTL is how long in seconds the push service keeps the notification while the browser is offline.
+	TTL     *int   `validate:"omitempty,min=0,max=2419200" json:"ttl,omitempty"`
+	Urgency string `validate:"omitempty,oneof=very-low low normal high" json:"urgency,omitempty"`
+	// Topic replaces pending notifications of the same topic, which aren't delivered yet.
+	Topic string `validate:"omitempty,max=32,alphanum" json:"topic,omitempty"`
+}
+
+// VoiceRequestBody is an object containing data for voice call endpoint, the message is read to the callee.
+type VoiceRequestBody struct {
+	Message      string `validate:"required,max=4000" json:"message"`
+	SendToNumber string `validate:"required,e164" json:"send_to_number"`
+	// Repeat is how many times the message is read, it is read once by default.
+	Repeat int `validate:"min=0,max=10" json:"repeat,omitempty"`
+	// Acknowledge asks the callee to acknowledge the alert by pressing 1.
+	Acknowledge bool   `json:"acknowledge,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// CallID and CallbackKey identify the call to the callbacks of Twilio, they are set by the endpoint. They are
+	// left out of pending notifications: calls are kept in memory only, so that a call resumed after restart is
+	// placed without callbacks which would find no call.
+	CallID      string `json:"-"`
+	CallbackKey string `json:"-"`
+}
+
+// CredentialRequestBody is an object containing data for creating and rotating provider credentials.
+type CredentialRequestBody struct {
+	Provider string            `json:"provider"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Values   map[string]string `json:"values"`
+}
+
+// DestinationRequestBody is an object containing data for creating and rotating destinations.
+type DestinationRequestBody struct {
+	Name    string `json:"name"`
+	Channel string `json:"channel"`
+	Tenant  string `json:"tenant,omitempty"`
+	Address string `json:"address"`
 }
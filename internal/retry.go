@@ -2,26 +2,86 @@ package internal
 
 import (
 	"context"
-	"log"
+	"errors"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Effector is a function that performs some action and returns an error.
 type Effector func(context.Context, any) error
 
+// PermanentError wraps an error which retrying can't fix, e.g. a notification addressed to an unknown destination.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks the error as one which retrying can't fix, Retry returns it right away.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// RetryAfterError wraps an error of a provider which asked to retry no sooner than after the delay, e.g. on rate
+// limiting.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter marks the error as one which should be retried no sooner than after the delay.
+func RetryAfter(err error, after time.Duration) error {
+	return &RetryAfterError{Err: err, After: after}
+}
+
 // Retry is a function that retries effector function for a given number of times with a given delay.
+// Permanent errors are not retried. Errors asking for a longer delay are retried after it, unless it would pass the
+// deadline of the context, then the error is returned right away.
 func Retry(effector Effector, retries int, delay time.Duration) Effector {
 	return func(ctx context.Context, arg any) error {
 		for r := 1; ; r++ {
-			err := effector(ctx, arg)
-			if err == nil || r >= retries {
+			attemptCtx, span := tracer().Start(ctx, "retry.attempt", trace.WithAttributes(attribute.Int("retry.attempt", r)))
+
+			err := effector(attemptCtx, arg)
+			observeRetryAttempt(ctx, err)
+			endSpan(span, err)
+
+			var permanent *PermanentError
+			if err == nil || r >= retries || errors.As(err, &permanent) {
+				return err
+			}
+
+			wait := delay
+
+			var retryAfter *RetryAfterError
+			if errors.As(err, &retryAfter) && retryAfter.After > wait {
+				wait = retryAfter.After
+			}
+
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) && wait > delay {
 				return err
 			}
 
-			log.Printf("Attempt %d failed, reason %v, retrying in %v", r, err, delay)
+			LoggerFromContext(ctx).Warn("attempt failed, retrying", "attempt", r, "error", err, "delay", wait)
 
 			select {
-			case <-time.After(delay):
+			case <-time.After(wait):
 			case <-ctx.Done():
 				return ctx.Err()
 			}
@@ -111,3 +111,65 @@ func TestRetryNegativeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name       string
+		after      time.Duration
+		ctxTimeout time.Duration
+		attempts   int
+		minElapsed time.Duration
+	}
+
+	tests := []test{
+		{
+			name:       "error asking for a longer delay should be retried after it",
+			after:      time.Millisecond * 300,
+			ctxTimeout: time.Second * 5,
+			attempts:   2,
+			minElapsed: time.Millisecond * 300,
+		},
+		{
+			name:       "error asking for a delay past the deadline should be returned right away",
+			after:      time.Second * 10,
+			ctxTimeout: time.Second * 1,
+			attempts:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.ctxTimeout)
+			defer cancel()
+
+			var attempts int
+
+			effector := func(ctx context.Context, a any) error {
+				attempts++
+				if attempts > 1 {
+					return nil
+				}
+
+				return internal.RetryAfter(errors.New("rate limited"), tc.after)
+			}
+
+			start := time.Now()
+
+			err := internal.Retry(effector, 3, time.Millisecond)(ctx, "")
+			if tc.attempts == 1 && err == nil {
+				t.Fatal("Expected error to be returned")
+			}
+
+			if attempts != tc.attempts || time.Since(start) < tc.minElapsed {
+				t.Fatalf("Expected %d attempts after %v, got: %d after %v", tc.attempts, tc.minElapsed, attempts,
+					time.Since(start))
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	_fileSecretScheme = "file"
+	_envSecretScheme  = "env"
+
+	_secretResolveTimeout = 10 * time.Second
+)
+
+// SecretProvider resolves references to secrets kept outside of the configuration, e.g. in a vault.
+//
+// Implementations of SecretProvider must be safe for concurrent use by multiple goroutines.
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// SecretProviderFunc is an adapter to allow the use of ordinary functions as SecretProvider.
+type SecretProviderFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+// Resolve calls f(ctx, ref).
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+// FileSecretProvider reads secrets from files, e.g. file:///run/secrets/twilio_token as mounted by Docker or
+// Kubernetes. Trailing newlines are trimmed.
+type FileSecretProvider struct{}
+
+var _ SecretProvider = FileSecretProvider{}
+
+// Resolve reads the file.
+func (FileSecretProvider) Resolve(_ context.Context, ref *url.URL) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider reads secrets from another environment variable, e.g. env://TWILIO_TOKEN_V2.
+type EnvSecretProvider struct{}
+
+var _ SecretProvider = EnvSecretProvider{}
+
+// Resolve looks up the variable.
+func (EnvSecretProvider) Resolve(_ context.Context, ref *url.URL) (string, error) {
+	value, ok := os.LookupEnv(ref.Host)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Host)
+	}
+
+	return value, nil
+}
+
+// WithSecretProvider resolves secret fields referencing the scheme, e.g. vault://secret/notifier#twilio_token,
+// with the provider. Providers for file:// and env:// references are registered by default.
+func WithSecretProvider(scheme string, provider SecretProvider) ConfigOption {
+	return func(o *configOptions) {
+		if o.secretProviders == nil {
+			o.secretProviders = make(map[string]SecretProvider)
+		}
+
+		o.secretProviders[scheme] = provider
+	}
+}
+
+func defaultSecretProviders() map[string]SecretProvider {
+	return map[string]SecretProvider{
+		_fileSecretScheme: FileSecretProvider{},
+		_envSecretScheme:  EnvSecretProvider{},
+	}
+}
+
+// resolveSecrets replaces references held by secret fields with the values of the secrets. Values of secret fields
+// which aren't references to a known scheme are kept as they are, e.g. the literal URL of the Slack webhook.
+func resolveSecrets(fields []configField, providers map[string]SecretProvider) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), _secretResolveTimeout)
+	defer cancel()
+
+	var errs []error
+
+	for _, f := range fields {
+		if !f.secret || f.value.Kind() != reflect.String {
+			continue
+		}
+
+		value, err := resolveSecret(ctx, f.value.String(), providers)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.key, err))
+
+			continue
+		}
+
+		f.value.SetString(value)
+	}
+
+	return errs
+}
+
+// resolveSecret returns the value of the referenced secret, values which aren't references are returned as they are.
+func resolveSecret(ctx context.Context, value string, providers map[string]SecretProvider) (string, error) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+
+	provider, ok := providers[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference: %v", err)
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %v", ref.Redacted(), err)
+	}
+
+	return secret, nil
+}
+
+// SecretValues returns the values of the secret fields, to be masked wherever they might show up.
+func (c *Config) SecretValues() []string {
+	var secrets []string
+
+	fields := configFields(reflect.ValueOf(c).Elem(), "", "")
+
+	for _, t := range c.Tenants {
+		fields = append(fields, t.fields()...)
+		secrets = append(secrets, t.APIKeys...)
+	}
+
+	for _, f := range fields {
+		if !f.secret || f.value.Kind() != reflect.String || f.value.String() == "" {
+			continue
+		}
+
+		secrets = append(secrets, f.value.String())
+
+		// Named chat webhooks are sent to one at a time, so that their errors quote a single URL.
+		if f.namespace == "Chat.Endpoints" {
+			endpoints, _ := parseChatEndpoints(f.value.String())
+			for _, u := range endpoints {
+				secrets = append(secrets, u)
+			}
+		}
+	}
+
+	return secrets
+}
+
+// Redactor masks secret values in text.
+//
+// Redactor is safe for concurrent use by multiple goroutines.
+type Redactor struct {
+	secrets atomic.Pointer[[]string]
+}
+
+// NewRedactor is a constructor function for Redactor.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.SetSecrets(secrets...)
+
+	return r
+}
+
+// SetSecrets replaces the masked values, e.g. after the secrets were rotated.
+func (r *Redactor) SetSecrets(secrets ...string) {
+	sorted := make([]string, 0, len(secrets))
+
+	for _, s := range secrets {
+		if s != "" {
+			sorted = append(sorted, s)
+		}
+	}
+
+	// Longer secrets first, so that a secret containing another one is masked as a whole.
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	r.secrets.Store(&sorted)
+}
+
+// Redact masks the secrets in the text.
+func (r *Redactor) Redact(s string) string {
+	return redact(s, *r.secrets.Load())
+}
+
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, _redacted)
+		}
+	}
+
+	return s
+}
+
+// redactedError masks secrets in the message of the error, keeping the error available to errors.Is and errors.As.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+func (e *redactedError) Error() string {
+	return redact(e.err.Error(), e.secrets)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError masks the secrets in the message of the error, errors of providers may quote them, e.g. the Slack
+// webhook URL is part of every error of the HTTP client.
+func redactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &redactedError{err: err, secrets: secrets}
+}
+
+// RedactingHandler is a slog.Handler masking secrets in messages and attributes before passing records on.
+type RedactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+var _ slog.Handler = (*RedactingHandler)(nil)
+
+// NewRedactingHandler is a constructor function for RedactingHandler.
+func NewRedactingHandler(next slog.Handler, redactor *Redactor) *RedactingHandler {
+	return &RedactingHandler{next: next, redactor: redactor}
+}
+
+// Enabled reports whether the next handler handles records at the level.
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle masks the secrets in the record and passes it on.
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(a))
+
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler masking secrets in the attributes as well.
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, h.redactAttr(a))
+	}
+
+	return &RedactingHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+// WithGroup returns a handler masking secrets of the group.
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *RedactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+
+	//nolint: exhaustive
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+
+		redacted := make([]any, 0, len(group))
+		for _, ga := range group {
+			redacted = append(redacted, h.redactAttr(ga))
+		}
+
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		switch value := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, h.redactor.Redact(value.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, h.redactor.Redact(value.String()))
+		}
+	}
+
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,132 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+func TestSecretReferences(t *testing.T) {
+	t.Setenv("SLACK_WEB_HOOK_URL", "")
+	t.Setenv("TWILIO_TOKEN", "")
+	t.Setenv("EMAIL_SMTP_PASSWORD", "")
+	t.Setenv("SMTP_PASSWORD_V2", "env-password")
+
+	tokenFile := writeConfigFile(t, "twilio_token", "file-token\n")
+
+	config := strings.NewReplacer(
+		"http://example.com/secret-path", "vault://notifier/slack",
+		"twilio-token", "file://"+tokenFile,
+		"smtp-password", "env://SMTP_PASSWORD_V2",
+	).Replace(_testConfigFile)
+
+	vault := internal.SecretProviderFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		return "https://hooks.slack.com/services/" + ref.Host + ref.Path, nil
+	})
+
+	c, err := internal.NewConfig(
+		internal.WithConfigFile(writeConfigFile(t, "config.yaml", config)),
+		internal.WithSecretProvider("vault", vault),
+	)
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if c.Twilio.Token != "file-token" {
+		t.Fatalf("Expected secret read from file, got: %q", c.Twilio.Token)
+	}
+
+	if c.Mail.SMTPPassword != "env-password" {
+		t.Fatalf("Expected secret read from environment variable, got: %q", c.Mail.SMTPPassword)
+	}
+
+	if c.SlackWebHookURL != "https://hooks.slack.com/services/notifier/slack" {
+		t.Fatalf("Expected secret resolved by provider, got: %q", c.SlackWebHookURL)
+	}
+
+	config = strings.Replace(_testConfigFile, "twilio-token", "file:///nonexistent/twilio_token", 1)
+
+	_, err = internal.NewConfig(internal.WithConfigFile(writeConfigFile(t, "config.yaml", config)))
+	if err == nil || !strings.Contains(err.Error(), "twilio.token: failed to resolve secret file:///nonexistent") {
+		t.Fatalf("Expected error resolving secret, got: %v", err)
+	}
+}
+
+func TestRedactingHandler(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	redactor := internal.NewRedactor("s3cret")
+	log := slog.New(internal.NewRedactingHandler(slog.NewTextHandler(&out, nil), redactor)).With("bound", "s3cret")
+
+	log.Info("token s3cret",
+		"url", "https://example.com/s3cret",
+		"error", errors.New("request to s3cret failed"),
+		slog.Group("provider", "token", "s3cret"),
+	)
+
+	redactor.SetSecrets("r0tated")
+	log.Info("token r0tated")
+
+	for _, secret := range []string{"s3cret", "r0tated"} {
+		if strings.Contains(out.String(), secret) {
+			t.Fatalf("Expected secret %q to be redacted, got:\n%s", secret, out.String())
+		}
+	}
+
+	if count := strings.Count(out.String(), "********"); count != 7 {
+		t.Fatalf("Expected 7 redacted values, got %d:\n%s", count, out.String())
+	}
+}
+
+func TestProviderErrorsRedacted(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on the port, so that the error of the HTTP client quotes the webhook URL.
+	config.SlackWebHookURL = "http://127.0.0.1:1/services/secret-token"
+	config.Throttle.SlackRate = 0
+
+	err = internal.NewService(config).NotifySlack(context.Background(), "Hello")
+	if err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("Expected webhook URL to be redacted, got: %s", err)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("Expected the original error to be kept, got: %#v", err)
+	}
+}
+
+func TestChatEndpointsRedacted(t *testing.T) {
+	t.Parallel()
+
+	config := &internal.Config{}
+	config.Chat.Endpoints = "ops=https://chat.example.com/hooks/ops-token,dev=https://chat.example.com/hooks/dev-token"
+
+	redactor := internal.NewRedactor(config.SecretValues()...)
+
+	out := redactor.Redact(`Post "https://chat.example.com/hooks/dev-token": connection refused`)
+	if strings.Contains(out, "dev-token") {
+		t.Fatalf("Expected the endpoint to be redacted, got: %s", out)
+	}
+}
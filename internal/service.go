@@ -5,59 +5,153 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
 
-	"github.com/twilio/twilio-go"
-	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"gopkg.in/gomail.v2"
 )
 
-// SlackMessage represents body for Slack message.
+// SlackMessage represents body for Slack message. Slack compatible webhooks, e.g. of Mattermost and Rocket.Chat,
+// accept the optional fields as well.
 type SlackMessage struct {
-	Text string `json:"text"`
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+	// Props are metadata of the post, only supported by Mattermost.
+	Props map[string]any `json:"props,omitempty"`
 }
 
+// SlackAttachment is a legacy message attachment, a block of rich content with a colour of its left border.
+type SlackAttachment struct {
+	Fallback  string                 `validate:"max=1024" json:"fallback,omitempty"`
+	Color     string                 `validate:"max=32" json:"color,omitempty"`
+	Pretext   string                 `validate:"max=1024" json:"pretext,omitempty"`
+	Title     string                 `validate:"max=256" json:"title,omitempty"`
+	TitleLink string                 `validate:"omitempty,http_url" json:"title_link,omitempty"`
+	Text      string                 `validate:"max=8000" json:"text,omitempty"`
+	Fields    []SlackAttachmentField `validate:"max=20,dive" json:"fields,omitempty"`
+	ImageURL  string                 `validate:"omitempty,http_url" json:"image_url,omitempty"`
+	Footer    string                 `validate:"max=256" json:"footer,omitempty"`
+}
+
+// SlackAttachmentField is a title and value pair of the attachment, short fields are shown side by side.
+type SlackAttachmentField struct {
+	Title string `validate:"required,max=256" json:"title"`
+	Value string `validate:"required,max=2048" json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+// ErrChannelDisabled is returned when sending through a channel which is not enabled.
+var ErrChannelDisabled = errors.New("channel is not enabled")
+
 // Slack holds Slack related configuration for sending notifications.
 type Slack struct {
 	webHookURL string
 	client     *http.Client
+	throttle   *Throttle
 }
 
-// Twilio holds related configuration for Twilio service, responsible for sending SMS notifications.
+// Twilio holds related configuration for Twilio service, responsible for sending SMS, MMS and WhatsApp
+// notifications.
 type Twilio struct {
-	client *twilio.RestClient
-	number string
+	sid            string
+	token          string
+	number         string
+	whatsappNumber string
+	baseURL        *url.URL
+	transport      http.RoundTripper
+	throttle       *Throttle
 }
 
 // Email holds email related configuration for sending mail notifications.
 type Email struct {
 	client        *gomail.Dialer
 	messageSender string
+	throttle      *Throttle
 }
 
 // Service handles business logic for the application.
 type Service struct {
-	slack  *Slack
-	twilio *Twilio
-	email  *Email
+	providers atomic.Pointer[providers]
+}
+
+// providers are the clients of the providers, built from a single configuration.
+type providers struct {
+	slack    *Slack
+	twilio   *Twilio
+	email    *Email
+	webhook  *Webhook
+	teams    *Teams
+	discord  *Discord
+	telegram *Telegram
+	chat     *Chat
+	matrix   *Matrix
+	fcm      *FCM
+	apns     *APNS
+	webPush  *WebPush
+	voice    *Voice
+	// throttle is the configuration the throttles were created with.
+	throttle ThrottleConfig
 }
 
 // NewService is a constructor function for Service.
 func NewService(config *Config) *Service {
-	s := &Service{
-		slack: &Slack{
-			client:     http.DefaultClient,
+	s := &Service{}
+	s.Reload(config)
+
+	return s
+}
+
+// Reload atomically swaps the providers for ones built from the configuration, notifications in progress complete
+// with the previous ones. Throttles are kept as long as their configuration is unchanged. Providers of disabled
+// channels are left out.
+func (s *Service) Reload(config *Config) {
+	p := &providers{throttle: config.Throttle}
+	prev := s.providers.Load()
+
+	keepThrottle := func(channel string) bool {
+		return prev != nil && prev.throttle == config.Throttle && prev.has(channel)
+	}
+
+	if config.SlackEnabled {
+		p.slack = &Slack{
+			client:     newProviderClient(),
 			webHookURL: config.SlackWebHookURL,
-		},
-		twilio: &Twilio{
-			client: twilio.NewRestClientWithParams(twilio.ClientParams{
-				Username: config.Twilio.SID,
-				Password: config.Twilio.Token,
-			}),
-			number: config.Twilio.Number,
-		},
-		email: &Email{
+			throttle:   NewThrottle(config.Throttle.SlackRate, config.Throttle.SlackConcurrency),
+		}
+
+		if keepThrottle(_slackChannel) {
+			p.slack.throttle = prev.slack.throttle
+		}
+	}
+
+	if config.Twilio.Enabled {
+		p.twilio = &Twilio{
+			sid:            config.Twilio.SID,
+			token:          config.Twilio.Token,
+			number:         config.Twilio.Number,
+			whatsappNumber: config.Twilio.whatsappNumber(),
+			baseURL:        config.Twilio.baseURL(),
+			throttle:       NewThrottle(config.Throttle.TwilioRate, config.Throttle.TwilioConcurrency),
+		}
+
+		if keepThrottle(_smsChannel) {
+			p.twilio.throttle = prev.twilio.throttle
+		}
+	}
+
+	if config.Mail.Enabled {
+		p.email = &Email{
 			client: gomail.NewDialer(
 				config.Mail.SMTPHost,
 				config.Mail.SMTPPort,
@@ -65,18 +159,168 @@ func NewService(config *Config) *Service {
 				config.Mail.SMTPPassword,
 			),
 			messageSender: config.Mail.EmailSender,
-		},
+			throttle:      NewThrottle(config.Throttle.MailRate, config.Throttle.MailConcurrency),
+		}
+
+		p.email.client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+		if keepThrottle(_mailChannel) {
+			p.email.throttle = prev.email.throttle
+		}
 	}
 
-	s.email.client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	if config.Webhook.Enabled {
+		p.webhook = newWebhook(config.Webhook)
+		p.webhook.throttle = NewThrottle(config.Throttle.WebhookRate, config.Throttle.WebhookConcurrency)
 
-	return s
+		if keepThrottle(_webhookChannel) {
+			p.webhook.throttle = prev.webhook.throttle
+		}
+	}
+
+	if config.Teams.Enabled {
+		p.teams = &Teams{
+			client:     newProviderClient(),
+			webHookURL: config.Teams.WebHookURL,
+			throttle:   NewThrottle(config.Throttle.TeamsRate, config.Throttle.TeamsConcurrency),
+		}
+
+		if keepThrottle(_teamsChannel) {
+			p.teams.throttle = prev.teams.throttle
+		}
+	}
+
+	if config.Discord.Enabled {
+		p.discord = newDiscord(config.Discord)
+		p.discord.throttle = NewThrottle(config.Throttle.DiscordRate, config.Throttle.DiscordConcurrency)
+
+		if keepThrottle(_discordChannel) {
+			p.discord.throttle = prev.discord.throttle
+			p.discord.limits = prev.discord.limits
+		}
+	}
+
+	if config.Telegram.Enabled {
+		p.telegram = &Telegram{
+			baseURL:  config.Telegram.BaseURL,
+			token:    config.Telegram.BotToken,
+			chatID:   config.Telegram.ChatID,
+			client:   newProviderClient(),
+			throttle: NewThrottle(config.Throttle.TelegramRate, config.Throttle.TelegramConcurrency),
+		}
+
+		if keepThrottle(_telegramChannel) {
+			p.telegram.throttle = prev.telegram.throttle
+		}
+	}
+
+	if config.Chat.Enabled {
+		p.chat = newChat(config.Chat)
+		p.chat.throttle = NewThrottle(config.Throttle.ChatRate, config.Throttle.ChatConcurrency)
+
+		if keepThrottle(_chatChannel) {
+			p.chat.throttle = prev.chat.throttle
+		}
+	}
+
+	if config.Matrix.Enabled {
+		p.matrix = &Matrix{
+			homeserverURL: config.Matrix.HomeserverURL,
+			accessToken:   config.Matrix.AccessToken,
+			room:          config.Matrix.Room,
+			client:        newProviderClient(),
+			throttle:      NewThrottle(config.Throttle.MatrixRate, config.Throttle.MatrixConcurrency),
+		}
+
+		if keepThrottle(_matrixChannel) {
+			p.matrix.throttle = prev.matrix.throttle
+		}
+	}
+
+	if config.FCM.Enabled {
+		p.fcm = newFCM(config.FCM)
+		p.fcm.throttle = NewThrottle(config.Throttle.FCMRate, config.Throttle.FCMConcurrency)
+
+		if keepThrottle(_fcmChannel) {
+			p.fcm.throttle = prev.fcm.throttle
+		}
+	}
+
+	if config.APNS.Enabled {
+		p.apns = newAPNS(config.APNS)
+		p.apns.throttle = NewThrottle(config.Throttle.APNSRate, config.Throttle.APNSConcurrency)
+
+		if keepThrottle(_apnsChannel) {
+			p.apns.throttle = prev.apns.throttle
+		}
+	}
+
+	if config.WebPush.Enabled {
+		p.webPush = newWebPush(config.WebPush)
+		p.webPush.throttle = NewThrottle(config.Throttle.WebPushRate, config.Throttle.WebPushConcurrency)
+
+		if keepThrottle(_webPushChannel) {
+			p.webPush.throttle = prev.webPush.throttle
+		}
+	}
+
+	if config.Twilio.Voice.Enabled {
+		p.voice = newVoice(config.Twilio)
+		p.voice.twilio.throttle = NewThrottle(config.Throttle.VoiceRate, config.Throttle.VoiceConcurrency)
+
+		if keepThrottle(_voiceChannel) {
+			p.voice.twilio.throttle = prev.voice.twilio.throttle
+		}
+	}
+
+	s.providers.Store(p)
+}
+
+// has reports whether the provider of the channel is enabled.
+func (p *providers) has(channel string) bool {
+	switch channel {
+	case _slackChannel:
+		return p.slack != nil
+	case _smsChannel:
+		return p.twilio != nil
+	case _mailChannel:
+		return p.email != nil
+	case _webhookChannel:
+		return p.webhook != nil
+	case _teamsChannel:
+		return p.teams != nil
+	case _discordChannel:
+		return p.discord != nil
+	case _telegramChannel:
+		return p.telegram != nil
+	case _chatChannel:
+		return p.chat != nil
+	case _matrixChannel:
+		return p.matrix != nil
+	case _fcmChannel:
+		return p.fcm != nil
+	case _apnsChannel:
+		return p.apns != nil
+	case _webPushChannel:
+		return p.webPush != nil
+	case _voiceChannel:
+		return p.voice != nil
+	default:
+		return false
+	}
 }
 
 var _ Notifier = (*Service)(nil)
 
 // NotifySlack sends Slack notification.
-func (s *Service) NotifySlack(ctx context.Context, msg any) error {
+func (s *Service) NotifySlack(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_slackChannel, _slackProvider, err) }()
+
+	slack := s.providers.Load().slack
+	if slack == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _slackChannel)
+	}
+
 	slackMsg := msg.(string)
 
 	slackMessage := SlackMessage{
@@ -88,17 +332,42 @@ func (s *Service) NotifySlack(ctx context.Context, msg any) error {
 		return fmt.Errorf("failed to marshal Slack message: %v", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, s.slack.webHookURL, bytes.NewBuffer(payload))
+	release, err := slack.throttle.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %v", err)
+		return fmt.Errorf("failed to schedule Slack notification: %w", err)
+	}
+	defer release()
+
+	// Notifications addressed to a destination are sent to its webhook instead.
+	webHookURL := slack.webHookURL
+	if u := destinationURLFromContext(ctx); u != "" {
+		webHookURL = u
 	}
 
+	req, err := http.NewRequest(http.MethodPost, webHookURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), webHookURL)
+	}
+
+	ctx, span := startProviderSpan(ctx, _slackChannel, _slackProvider)
+	defer func() { endSpan(span, err) }()
+
 	req.Header.Set("Content-Type", "application/json")
 	req = req.WithContext(ctx)
 
-	resp, err := s.slack.client.Do(req)
+	if correlationID := CorrelationIDFromContext(ctx); correlationID != "" {
+		req.Header.Set(CorrelationIDHeader, correlationID)
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := slack.client.Do(req)
+
+	observeProviderDuration(_slackChannel, _slackProvider, start)
+
 	if err != nil {
-		return fmt.Errorf("failed to send Slack notification: %v", err)
+		return redactError(fmt.Errorf("failed to send Slack notification: %w", err), webHookURL)
 	}
 
 	//nolint: errcheck
@@ -108,42 +377,291 @@ func (s *Service) NotifySlack(ctx context.Context, msg any) error {
 		return fmt.Errorf("unexpected response status: %s", resp.Status)
 	}
 
+	LoggerFromContext(ctx).Debug("Slack notification sent")
+
 	return nil
 }
 
 // NotifySMS sends SMS notification.
-func (s *Service) NotifySMS(ctx context.Context, msg any) error {
-	twilioMsg := msg.(*SMSRequestBody)
+func (s *Service) NotifySMS(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_smsChannel, _twilioProvider, err) }()
 
-	params := &twilioApi.CreateMessageParams{}
+	twilio := s.providers.Load().twilio
+	if twilio == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _smsChannel)
+	}
 
-	params.SetTo(twilioMsg.SendToNumber)
-	params.SetFrom(s.twilio.number)
-	params.SetBody(twilioMsg.Message)
+	twilioMsg := msg.(*SMSRequestBody)
+
+	// Destinations may resolve to either kind of address, features are only checked once it is known.
+	if err := checkSMSFeatures(twilioMsg); err != nil {
+		return Permanent(err)
+	}
 
-	_, err := s.twilio.client.Api.CreateMessage(params)
+	params, err := twilio.messageParams(twilioMsg)
 	if err != nil {
 		return err
 	}
 
+	release, err := twilio.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule SMS notification: %w", err)
+	}
+	defer release()
+
+	ctx, span := startProviderSpan(ctx, _smsChannel, _twilioProvider)
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	resp, err := twilio.api(ctx).CreateMessage(params)
+
+	observeProviderDuration(_smsChannel, _twilioProvider, start)
+
+	if err != nil {
+		return twilioError(err, twilio.token)
+	}
+
+	if resp.Sid != nil {
+		LoggerFromContext(ctx).Debug("SMS notification sent", "sid", *resp.Sid)
+	}
+
 	return nil
 }
 
 // NotifyMail send mail notification.
-func (s *Service) NotifyMail(_ context.Context, msg any) error {
+func (s *Service) NotifyMail(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_mailChannel, _smtpProvider, err) }()
+
+	email := s.providers.Load().email
+	if email == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _mailChannel)
+	}
+
 	m := gomail.NewMessage()
 
 	mailContent := msg.(*MailRequestBody)
 
-	m.SetHeader("From", s.email.messageSender)
+	m.SetHeader("From", email.messageSender)
 	m.SetHeader("To", mailContent.SendTo)
 	m.SetHeader("Subject", mailContent.Subject)
 	m.SetBody("text/plain", mailContent.Message)
 
-	err := s.email.client.DialAndSend(m)
+	if correlationID := CorrelationIDFromContext(ctx); correlationID != "" {
+		m.SetHeader(CorrelationIDHeader, correlationID)
+	}
+
+	release, err := email.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule email: %w", err)
+	}
+	defer release()
+
+	_, span := startProviderSpan(ctx, _mailChannel, _smtpProvider)
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	err = sendMail(ctx, email.client, email.messageSender, []string{mailContent.SendTo}, m)
+
+	observeProviderDuration(_mailChannel, _smtpProvider, start)
+
 	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+		return redactError(fmt.Errorf("failed to send email: %w", err), email.client.Password)
 	}
 
+	LoggerFromContext(ctx).Debug("mail notification sent")
+
 	return nil
 }
+
+// _maxResponseSize limits how much of the responses of the providers is read.
+const _maxResponseSize = 64 << 10
+
+// sendProviderRequest sends the request to the provider of the channel and checks the response with check, given
+// the body read up to _maxResponseSize. The call is traced and timed, the correlation ID and the trace context are
+// forwarded to the provider.
+func sendProviderRequest(
+	ctx context.Context, client *http.Client, req *http.Request, channel, provider string,
+	check func(resp *http.Response, body []byte) error,
+) (err error) {
+	ctx, span := startProviderSpan(ctx, channel, provider)
+	defer func() { endSpan(span, err) }()
+
+	req = req.WithContext(ctx)
+
+	if correlationID := CorrelationIDFromContext(ctx); correlationID != "" {
+		req.Header.Set(CorrelationIDHeader, correlationID)
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+
+	observeProviderDuration(channel, provider, start)
+
+	if err != nil {
+		return err
+	}
+
+	//nolint: errcheck
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, _maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return check(resp, body)
+}
+
+// responseStatusError checks the response of a provider, any 2xx status is a success. Redirects and client errors,
+// except for timeouts and rate limiting, don't change on retry and are permanent.
+func responseStatusError(resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	err := fmt.Errorf("unexpected response status: %s", resp.Status)
+
+	if resp.StatusCode < http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+
+	return err
+}
+
+// HealthChecks returns deep checks of the reachability of the providers of the enabled channels.
+func (s *Service) HealthChecks() []HealthCheck {
+	p := s.providers.Load()
+
+	var checks []HealthCheck
+
+	if p.has(_slackChannel) {
+		checks = append(checks, HealthCheck{Name: _slackProvider, Check: s.checkSlack})
+	}
+
+	if p.has(_smsChannel) {
+		checks = append(checks, HealthCheck{Name: _twilioProvider, Check: s.checkTwilio})
+	}
+
+	// Voice calls are placed through the same account, it is only checked on its own when SMS is disabled.
+	if p.has(_voiceChannel) && !p.has(_smsChannel) {
+		checks = append(checks, HealthCheck{Name: _twilioProvider, Check: s.checkVoice})
+	}
+
+	if p.has(_mailChannel) {
+		checks = append(checks, HealthCheck{Name: _smtpProvider, Check: s.checkMail})
+	}
+
+	// Notifications may name their own URL, only the configured one can be checked.
+	if p.has(_webhookChannel) && p.webhook.url != "" {
+		checks = append(checks, HealthCheck{Name: _webhookProvider, Check: s.checkWebhook})
+	}
+
+	if p.has(_teamsChannel) {
+		checks = append(checks, HealthCheck{Name: _teamsProvider, Check: s.checkTeams})
+	}
+
+	if p.has(_discordChannel) {
+		checks = append(checks, HealthCheck{Name: _discordProvider, Check: s.checkDiscord})
+	}
+
+	if p.has(_telegramChannel) {
+		checks = append(checks, HealthCheck{Name: _telegramProvider, Check: s.checkTelegram})
+	}
+
+	if p.has(_chatChannel) {
+		checks = append(checks, HealthCheck{Name: _chatProvider, Check: s.checkChat})
+	}
+
+	if p.has(_matrixChannel) {
+		checks = append(checks, HealthCheck{Name: _matrixProvider, Check: s.checkMatrix})
+	}
+
+	if p.has(_fcmChannel) {
+		checks = append(checks, HealthCheck{Name: _fcmProvider, Check: s.checkFCM})
+	}
+
+	if p.has(_apnsChannel) {
+		checks = append(checks, HealthCheck{Name: _apnsProvider, Check: s.checkAPNS})
+	}
+
+	// Web push has no provider of its own to check, every browser vendor runs its push service.
+
+	return checks
+}
+
+// checkSlack verifies the webhook is reachable.
+func (s *Service) checkSlack(ctx context.Context) error {
+	slack := s.providers.Load().slack
+
+	return checkURL(ctx, slack.client, slack.webHookURL)
+}
+
+// newProviderClient creates the HTTP client of a provider. Redirects are not followed, they could carry the request
+// along with its credentials to another host, the redirect response is reported as failure instead.
+func newProviderClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkURL verifies the URL is reachable by a GET request.
+func checkURL(ctx context.Context, client *http.Client, u string) error {
+	return probeURL(ctx, client, http.MethodGet, u)
+}
+
+// probeURL verifies the URL is reachable by a request with the method, any response which is not a server error
+// means it is. The URL is redacted from errors, it usually holds a secret, e.g. the token of a webhook.
+func probeURL(ctx context.Context, client *http.Client, method, u string) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), u)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactError(fmt.Errorf("endpoint unreachable: %w", err), u)
+	}
+
+	//nolint: errcheck
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// checkTwilio looks up the configured account.
+func (s *Service) checkTwilio(ctx context.Context) error {
+	twilio := s.providers.Load().twilio
+
+	if _, err := twilio.api(ctx).FetchAccount(twilio.sid); err != nil {
+		return redactError(fmt.Errorf("account lookup failed: %w", err), twilio.token)
+	}
+
+	return nil
+}
+
+// checkMail connects to the SMTP server and issues NOOP command.
+func (s *Service) checkMail(ctx context.Context) error {
+	email := s.providers.Load().email
+
+	c, err := dialSMTP(ctx, email.client)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Noop(); err != nil {
+		c.close()
+
+		return contextError(ctx, fmt.Errorf("SMTP NOOP failed: %v", err))
+	}
+
+	return c.quit()
+}
@@ -0,0 +1,305 @@
+package internal_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+func TestServiceCancellation(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	// Every provider stand-in blocks until the client gives up.
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer blocking.Close()
+	defer close(release)
+
+	silentSMTP, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentSMTP.Close()
+
+	go func() {
+		var conns []net.Conn
+
+		defer func() {
+			for _, conn := range conns {
+				//nolint: errcheck
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := silentSMTP.Accept()
+			if err != nil {
+				return
+			}
+
+			// Never greet the client, leaving it waiting for the response.
+			conns = append(conns, conn)
+		}
+	}()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.SlackWebHookURL = blocking.URL
+	config.Twilio.APIBaseURL = blocking.URL
+	config.Mail.SMTPHost, config.Mail.SMTPPort = splitHostPort(t, silentSMTP.Addr().String())
+
+	service := internal.NewService(config)
+
+	type test struct {
+		name   string
+		notify func(context.Context) error
+	}
+
+	tests := []test{
+		{
+			name: "Slack notification should stop when context is done",
+			notify: func(ctx context.Context) error {
+				return service.NotifySlack(ctx, "Hello")
+			},
+		},
+		{
+			name: "SMS notification should stop when context is done",
+			notify: func(ctx context.Context) error {
+				return service.NotifySMS(ctx, &internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"})
+			},
+		},
+		{
+			name: "mail notification should stop when context is done",
+			notify: func(ctx context.Context) error {
+				return service.NotifyMail(ctx, &internal.MailRequestBody{
+					Message: "Hello", SendTo: "example@gmail.com", Subject: "Test",
+				})
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+			defer cancel()
+
+			tNow := time.Now()
+
+			err := tc.notify(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("\nExpected: %s\nActual: %v", context.DeadlineExceeded, err)
+			}
+
+			if since := time.Since(tNow); since > time.Second {
+				t.Fatalf("Expected provider work to stop with the context, took %v", since)
+			}
+		})
+	}
+}
+
+func TestNotifyMail(t *testing.T) {
+	t.Parallel()
+
+	smtpServer, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtpServer.Close()
+
+	received := make(chan string, 1)
+
+	go serveSMTP(smtpServer, received)
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Mail.SMTPHost, config.Mail.SMTPPort = splitHostPort(t, smtpServer.Addr().String())
+	config.Mail.SMTPUsername = ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	err = internal.NewService(config).NotifyMail(ctx, &internal.MailRequestBody{
+		Message: "Hello", SendTo: "example@gmail.com", Subject: "Test",
+	})
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	data := <-received
+
+	for _, expected := range []string{"Subject: Test", "Hello"} {
+		if !strings.Contains(data, expected) {
+			t.Fatalf("Expected sent mail to contain %q, got:\n%s", expected, data)
+		}
+	}
+}
+
+// serveSMTP is a minimal SMTP server accepting a single message.
+func serveSMTP(l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		//nolint: errcheck
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+
+	var data strings.Builder
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 end with <CR><LF>.<CR><LF>")
+
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+
+				data.WriteString(line)
+			}
+
+			received <- data.String()
+
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func splitHostPort(t *testing.T, addr string) (string, int) {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return host, p
+}
+
+func TestServiceReload(t *testing.T) {
+	t.Parallel()
+
+	hits := make(chan string, 2)
+
+	newWebhook := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits <- name
+		}))
+	}
+
+	previous, next := newWebhook("previous"), newWebhook("next")
+	defer previous.Close()
+	defer next.Close()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.SlackWebHookURL = previous.URL
+	config.Throttle.SlackRate = 0
+
+	service := internal.NewService(config)
+
+	reloaded := *config
+	reloaded.SlackWebHookURL = next.URL
+
+	for _, expected := range []string{"previous", "next"} {
+		if err := service.NotifySlack(context.Background(), "Hello"); err != nil {
+			t.Fatalf("Expected error to be nil but got: %s", err)
+		}
+
+		if hit := <-hits; hit != expected {
+			t.Fatalf("Different webhooks, expected: %v, got: %v", expected, hit)
+		}
+
+		service.Reload(&reloaded)
+	}
+}
+
+func TestServiceDisabledChannel(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Twilio.Enabled = false
+
+	service := internal.NewService(config)
+
+	err = service.NotifySMS(context.Background(), &internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"})
+	if !errors.Is(err, internal.ErrChannelDisabled) {
+		t.Fatalf("\nExpected: %s\nActual: %v", internal.ErrChannelDisabled, err)
+	}
+
+	for _, check := range service.HealthChecks() {
+		if check.Name == "twilio" {
+			t.Fatal("Expected no health check for provider of disabled channel")
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+)
+
+// smtpConn is a connection to an SMTP server which is aborted as soon as its context is done.
+type smtpConn struct {
+	*smtp.Client
+	ctx  context.Context
+	stop func() bool
+}
+
+// dialSMTP connects to the SMTP server described by the dialer, upgrading the connection to TLS when possible.
+// Unlike gomail.Dialer, dialing, the handshake and every following command are canceled with the context.
+func dialSMTP(ctx context.Context, d *gomail.Dialer) (*smtpConn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(d.Host, strconv.Itoa(d.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("SMTP server unreachable: %w", err)
+	}
+
+	// Closing the connection unblocks any read or write in progress. No deadline is set on the connection, it could
+	// fire before the context is done and the error wouldn't be reported as the one of the context.
+	raw := conn
+	stop := context.AfterFunc(ctx, func() {
+		//nolint: errcheck
+		raw.Close()
+	})
+
+	if d.SSL {
+		conn = tls.Client(conn, tlsConfig(d))
+	}
+
+	c, err := smtp.NewClient(conn, d.Host)
+	if err != nil {
+		stop()
+
+		//nolint: errcheck
+		conn.Close()
+
+		return nil, contextError(ctx, fmt.Errorf("SMTP handshake failed: %w", err))
+	}
+
+	sc := &smtpConn{Client: c, ctx: ctx, stop: stop}
+
+	if d.LocalName != "" {
+		if err := c.Hello(d.LocalName); err != nil {
+			sc.close()
+
+			return nil, contextError(ctx, fmt.Errorf("SMTP HELO failed: %w", err))
+		}
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !d.SSL {
+		if err := c.StartTLS(tlsConfig(d)); err != nil {
+			sc.close()
+
+			return nil, contextError(ctx, fmt.Errorf("SMTP STARTTLS failed: %w", err))
+		}
+	}
+
+	return sc, nil
+}
+
+// authenticate picks the strongest mechanism supported by the server, like gomail.Dialer does.
+func (c *smtpConn) authenticate(d *gomail.Dialer) error {
+	auth := d.Auth
+
+	if auth == nil && d.Username != "" {
+		ok, mechanisms := c.Extension("AUTH")
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case strings.Contains(mechanisms, "CRAM-MD5"):
+			auth = smtp.CRAMMD5Auth(d.Username, d.Password)
+		case strings.Contains(mechanisms, "LOGIN") && !strings.Contains(mechanisms, "PLAIN"):
+			auth = &loginAuth{username: d.Username, password: d.Password, host: d.Host}
+		default:
+			auth = smtp.PlainAuth("", d.Username, d.Password, d.Host)
+		}
+	}
+
+	if auth == nil {
+		return nil
+	}
+
+	if err := c.Auth(auth); err != nil {
+		return contextError(c.ctx, fmt.Errorf("SMTP authentication failed: %w", err))
+	}
+
+	return nil
+}
+
+// send delivers the message to the given recipients.
+func (c *smtpConn) send(from string, to []string, m *gomail.Message) error {
+	if err := c.Mail(from); err != nil {
+		return contextError(c.ctx, fmt.Errorf("SMTP MAIL failed: %w", err))
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return contextError(c.ctx, fmt.Errorf("SMTP RCPT failed: %w", err))
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return contextError(c.ctx, fmt.Errorf("SMTP DATA failed: %w", err))
+	}
+
+	if _, err := m.WriteTo(w); err != nil {
+		//nolint: errcheck
+		w.Close()
+
+		return contextError(c.ctx, fmt.Errorf("failed to write message: %w", err))
+	}
+
+	if err := w.Close(); err != nil {
+		return contextError(c.ctx, fmt.Errorf("SMTP DATA failed: %w", err))
+	}
+
+	return nil
+}
+
+// quit ends the session politely and releases the connection.
+func (c *smtpConn) quit() error {
+	defer c.close()
+
+	if err := c.Quit(); err != nil {
+		return contextError(c.ctx, fmt.Errorf("SMTP QUIT failed: %w", err))
+	}
+
+	return nil
+}
+
+func (c *smtpConn) close() {
+	c.stop()
+
+	//nolint: errcheck
+	c.Close()
+}
+
+// contextError prefers the error of the context, since failures of a connection closed on cancellation
+// are only a symptom of it.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return errors.Join(ctxErr, err)
+	}
+
+	return err
+}
+
+// sendMail dials the SMTP server, authenticates and delivers the message, honouring the context throughout.
+func sendMail(ctx context.Context, d *gomail.Dialer, from string, to []string, m *gomail.Message) error {
+	c, err := dialSMTP(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	if err := c.authenticate(d); err != nil {
+		c.close()
+
+		return err
+	}
+
+	if err := c.send(from, to, m); err != nil {
+		c.close()
+
+		return err
+	}
+
+	return c.quit()
+}
+
+// loginAuth implements the LOGIN authentication mechanism, not provided by net/smtp.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start begins the authentication, credentials are only sent over TLS or to localhost.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// Next answers the challenges of the server.
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch {
+	case strings.EqualFold(string(fromServer), "Username:"):
+		return []byte(a.username), nil
+	case strings.EqualFold(string(fromServer), "Password:"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
+
+func tlsConfig(d *gomail.Dialer) *tls.Config {
+	if d.TLSConfig == nil {
+		return &tls.Config{ServerName: d.Host, MinVersion: tls.VersionTLS12}
+	}
+
+	return d.TLSConfig
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
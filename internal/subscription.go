@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// SubscriptionStore persists the web push subscriptions of users, kept apart by tenant. A user has a subscription for
+// every browser the app is installed in, identified by its endpoint.
+//
+// Implementations of SubscriptionStore must be safe for concurrent use by multiple goroutines.
+type SubscriptionStore interface {
+	// Save adds the subscription of the user, replacing the one with the same endpoint.
+	Save(ctx context.Context, tenant, user string, s PushSubscription) error
+	List(ctx context.Context, tenant, user string) ([]PushSubscription, error)
+	// Delete removes the subscription of the user with the endpoint, ErrNotFound is returned when there is none.
+	Delete(ctx context.Context, tenant, user, endpoint string) error
+}
+
+// MemorySubscriptionStore is a SubscriptionStore keeping the subscriptions in memory, they are lost on restart.
+type MemorySubscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions map[subscriptionOwner][]PushSubscription
+}
+
+type subscriptionOwner struct {
+	tenant, user string
+}
+
+// NewMemorySubscriptionStore is a constructor function for MemorySubscriptionStore.
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{subscriptions: make(map[subscriptionOwner][]PushSubscription)}
+}
+
+var _ SubscriptionStore = (*MemorySubscriptionStore)(nil)
+
+// Save adds the subscription of the user, replacing the one with the same endpoint.
+func (s *MemorySubscriptionStore) Save(_ context.Context, tenant, user string, sub PushSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := subscriptionOwner{tenant: tenant, user: user}
+	s.subscriptions[owner] = upsertSubscription(s.subscriptions[owner], sub)
+
+	return nil
+}
+
+// List returns the subscriptions of the user.
+func (s *MemorySubscriptionStore) List(_ context.Context, tenant, user string) ([]PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.subscriptions[subscriptionOwner{tenant: tenant, user: user}]), nil
+}
+
+// Delete removes the subscription of the user with the endpoint.
+func (s *MemorySubscriptionStore) Delete(_ context.Context, tenant, user, endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := subscriptionOwner{tenant: tenant, user: user}
+
+	subscriptions, err := deleteSubscription(s.subscriptions[owner], endpoint)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 0 {
+		delete(s.subscriptions, owner)
+	} else {
+		s.subscriptions[owner] = subscriptions
+	}
+
+	return nil
+}
+
+// FileSubscriptionStore is a SubscriptionStore keeping the subscriptions of every user in a separate file of a
+// directory.
+type FileSubscriptionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileSubscriptionStore is a constructor function for FileSubscriptionStore.
+func NewFileSubscriptionStore(dir string) (*FileSubscriptionStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create subscriptions directory: %v", err)
+	}
+
+	return &FileSubscriptionStore{dir: dir}, nil
+}
+
+var _ SubscriptionStore = (*FileSubscriptionStore)(nil)
+
+// Check reports whether subscriptions can be written to the directory.
+func (s *FileSubscriptionStore) Check(_ context.Context) error {
+	return checkWritableDir(s.dir)
+}
+
+// Save adds the subscription of the user, replacing the one with the same endpoint.
+func (s *FileSubscriptionStore) Save(_ context.Context, tenant, user string, sub PushSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions, err := s.read(tenant, user)
+	if err != nil {
+		return err
+	}
+
+	return s.write(tenant, user, upsertSubscription(subscriptions, sub))
+}
+
+// List reads the subscriptions of the user.
+func (s *FileSubscriptionStore) List(_ context.Context, tenant, user string) ([]PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(tenant, user)
+}
+
+// Delete removes the subscription of the user with the endpoint, the file is removed with the last subscription.
+func (s *FileSubscriptionStore) Delete(_ context.Context, tenant, user, endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions, err := s.read(tenant, user)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err = deleteSubscription(subscriptions, endpoint)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 0 {
+		if err := os.Remove(s.path(tenant, user)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete subscriptions: %v", err)
+		}
+
+		return nil
+	}
+
+	return s.write(tenant, user, subscriptions)
+}
+
+func (s *FileSubscriptionStore) read(tenant, user string) ([]PushSubscription, error) {
+	data, err := os.ReadFile(s.path(tenant, user))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions: %v", err)
+	}
+
+	var subscriptions []PushSubscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal subscriptions: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// write replaces the file of the user, the write is atomic so partially written files are never read.
+func (s *FileSubscriptionStore) write(tenant, user string, subscriptions []PushSubscription) error {
+	data, err := json.Marshal(subscriptions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscriptions: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "subscriptions.*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create subscriptions file: %v", err)
+	}
+
+	//nolint: errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint: errcheck
+		tmp.Close()
+
+		return fmt.Errorf("failed to write subscriptions: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write subscriptions: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path(tenant, user))
+}
+
+// path is the file of the user, named by a hash so that IDs of users and tenants can be anything.
+func (s *FileSubscriptionStore) path(tenant, user string) string {
+	sum := sha256.Sum256([]byte(tenant + "\x00" + user))
+
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func upsertSubscription(subscriptions []PushSubscription, sub PushSubscription) []PushSubscription {
+	subscriptions = slices.DeleteFunc(slices.Clone(subscriptions), func(s PushSubscription) bool {
+		return s.Endpoint == sub.Endpoint
+	})
+
+	return append(subscriptions, sub)
+}
+
+func deleteSubscription(subscriptions []PushSubscription, endpoint string) ([]PushSubscription, error) {
+	i := slices.IndexFunc(subscriptions, func(s PushSubscription) bool { return s.Endpoint == endpoint })
+	if i < 0 {
+		return nil, fmt.Errorf("subscription %w", ErrNotFound)
+	}
+
+	return slices.Delete(slices.Clone(subscriptions), i, i+1), nil
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	_adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	_adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	// _adaptiveCardVersion is the latest version supported by Teams on every client.
+	_adaptiveCardVersion = "1.4"
+)
+
+// Teams holds Microsoft Teams related configuration for sending notifications.
+type Teams struct {
+	webHookURL string
+	client     *http.Client
+	throttle   *Throttle
+}
+
+// teamsMessage is a message with an Adaptive Card attached, as accepted by incoming webhooks and Workflows.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string                `json:"$schema"`
+	Type    string                `json:"type"`
+	Version string                `json:"version"`
+	Body    []adaptiveCardElement `json:"body"`
+	Actions []adaptiveCardAction  `json:"actions,omitempty"`
+	// MSTeams makes the card use the full width of the conversation.
+	MSTeams map[string]string `json:"msteams"`
+}
+
+type adaptiveCardElement struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Size   string             `json:"size,omitempty"`
+	Weight string             `json:"weight,omitempty"`
+	Wrap   bool               `json:"wrap,omitempty"`
+	Facts  []adaptiveCardFact `json:"facts,omitempty"`
+}
+
+type adaptiveCardFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type adaptiveCardAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// newTeamsMessage maps the notification to a message with an Adaptive Card: the title, the text, the facts as a fact
+// set and the actions as buttons opening their URL.
+func newTeamsMessage(body *TeamsRequestBody) teamsMessage {
+	card := adaptiveCard{
+		Schema:  _adaptiveCardSchema,
+		Type:    "AdaptiveCard",
+		Version: _adaptiveCardVersion,
+		MSTeams: map[string]string{"width": "Full"},
+	}
+
+	if body.Title != "" {
+		card.Body = append(card.Body, adaptiveCardElement{
+			Type: "TextBlock", Text: body.Title, Size: "Medium", Weight: "Bolder", Wrap: true,
+		})
+	}
+
+	card.Body = append(card.Body, adaptiveCardElement{Type: "TextBlock", Text: body.Text, Wrap: true})
+
+	if len(body.Facts) > 0 {
+		facts := make([]adaptiveCardFact, 0, len(body.Facts))
+		for _, f := range body.Facts {
+			facts = append(facts, adaptiveCardFact{Title: f.Name, Value: f.Value})
+		}
+
+		card.Body = append(card.Body, adaptiveCardElement{Type: "FactSet", Facts: facts})
+	}
+
+	for _, a := range body.Actions {
+		card.Actions = append(card.Actions, adaptiveCardAction{Type: "Action.OpenUrl", Title: a.Title, URL: a.URL})
+	}
+
+	return teamsMessage{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: _adaptiveCardContentType, Content: card}},
+	}
+}
+
+// NotifyTeams sends Microsoft Teams notification as an Adaptive Card.
+func (s *Service) NotifyTeams(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_teamsChannel, _teamsProvider, err) }()
+
+	teams := s.providers.Load().teams
+	if teams == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _teamsChannel)
+	}
+
+	payload, err := json.Marshal(newTeamsMessage(msg.(*TeamsRequestBody)))
+	if err != nil {
+		return fmt.Errorf("failed to marshal Teams message: %v", err)
+	}
+
+	release, err := teams.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule Teams notification: %w", err)
+	}
+	defer release()
+
+	// Notifications addressed to a destination are sent to its webhook instead.
+	webHookURL := teams.webHookURL
+	if u := destinationURLFromContext(ctx); u != "" {
+		webHookURL = u
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webHookURL, bytes.NewReader(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), webHookURL)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	err = sendProviderRequest(ctx, teams.client, req, _teamsChannel, _teamsProvider, checkTeamsResponse)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send Teams notification: %w", err), webHookURL)
+	}
+
+	LoggerFromContext(ctx).Debug("Teams notification sent")
+
+	return nil
+}
+
+// checkTeamsResponse checks the response of the webhook. Workflows accept messages with 202 and incoming webhooks
+// with 200, the latter report some failures in the body of a 200 response instead of the status.
+func checkTeamsResponse(resp *http.Response, body []byte) error {
+	if err := responseStatusError(resp); err != nil {
+		return err
+	}
+
+	text := strings.TrimSpace(string(body))
+	if resp.StatusCode == http.StatusOK && text != "" && text != "1" && strings.Contains(strings.ToLower(text), "error") {
+		return fmt.Errorf("message rejected: %s", text)
+	}
+
+	return nil
+}
+
+// checkTeams verifies the webhook is reachable.
+func (s *Service) checkTeams(ctx context.Context) error {
+	teams := s.providers.Load().teams
+
+	return checkURL(ctx, teams.client, teams.webHookURL)
+}
@@ -0,0 +1,189 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// teamsCard is the part of the Adaptive Card message checked by the tests.
+type teamsCard struct {
+	Type        string `json:"type"`
+	Attachments []struct {
+		ContentType string `json:"contentType"`
+		Content     struct {
+			Type    string `json:"type"`
+			Version string `json:"version"`
+			Body    []struct {
+				Type  string `json:"type"`
+				Text  string `json:"text"`
+				Facts []struct {
+					Title string `json:"title"`
+					Value string `json:"value"`
+				} `json:"facts"`
+			} `json:"body"`
+			Actions []struct {
+				Type  string `json:"type"`
+				Title string `json:"title"`
+				URL   string `json:"url"`
+			} `json:"actions"`
+		} `json:"content"`
+	} `json:"attachments"`
+}
+
+func TestTeamsNotification(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		cards    []teamsCard
+		response = func(w http.ResponseWriter) { w.WriteHeader(http.StatusAccepted) }
+	)
+
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var card teamsCard
+		err := json.NewDecoder(r.Body).Decode(&card)
+		if err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		cards = append(cards, card)
+		response(w)
+	}))
+	defer teams.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Teams = internal.TeamsConfig{Enabled: true, WebHookURL: teams.URL}
+		config.Retry.MaxRetries = 2
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	card := &internal.TeamsRequestBody{
+		Title:   "Deployment finished",
+		Text:    "Version 1.2.3 is live.",
+		Facts:   []internal.TeamsFact{{Name: "Environment", Value: "production"}},
+		Actions: []internal.TeamsAction{{Title: "Open dashboard", URL: "https://example.com/dashboard"}},
+	}
+
+	type test struct {
+		name               string
+		requestBody        any
+		response           func(w http.ResponseWriter)
+		expectedStatusCode int
+		expectedCards      int
+	}
+
+	tests := []test{
+		{
+			name:               "card accepted by a Workflows webhook should be sent",
+			requestBody:        card,
+			expectedStatusCode: http.StatusOK,
+			expectedCards:      1,
+		},
+		{
+			name:        "card accepted by an incoming webhook should be sent",
+			requestBody: &internal.TeamsRequestBody{Text: "Hello"},
+			response: func(w http.ResponseWriter) {
+				w.Write([]byte("1")) //nolint: errcheck
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedCards:      2,
+		},
+		{
+			name:        "card rejected in the body of the response should fail",
+			requestBody: &internal.TeamsRequestBody{Text: "Hello"},
+			response: func(w http.ResponseWriter) {
+				w.Write([]byte("Webhook message delivery failed with error: Bad payload")) //nolint: errcheck
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedCards:      4,
+		},
+		{
+			name:               "card rejected by the webhook should not be retried",
+			requestBody:        &internal.TeamsRequestBody{Text: "Hello"},
+			response:           func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedCards:      5,
+		},
+		{
+			name:               "card without text should be rejected",
+			requestBody:        &internal.TeamsRequestBody{Title: "Hello"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCards:      5,
+		},
+		{
+			name: "fact without value should be rejected",
+			requestBody: &internal.TeamsRequestBody{
+				Text: "Hello", Facts: []internal.TeamsFact{{Name: "Environment"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCards:      5,
+		},
+		{
+			name: "action without HTTP URL should be rejected",
+			requestBody: &internal.TeamsRequestBody{
+				Text: "Hello", Actions: []internal.TeamsAction{{Title: "Open", URL: "javascript:alert(1)"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCards:      5,
+		},
+	}
+
+	for _, tc := range tests {
+		mu.Lock()
+		if tc.response != nil {
+			response = tc.response
+		}
+		mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/teams", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		mu.Lock()
+		sent := len(cards)
+		mu.Unlock()
+
+		if sent != tc.expectedCards {
+			t.Fatalf("%s: Expected %d cards sent, got: %d", tc.name, tc.expectedCards, sent)
+		}
+	}
+
+	sent := cards[0]
+	if sent.Type != "message" || len(sent.Attachments) != 1 ||
+		sent.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("Expected message with Adaptive Card attached, got: %+v", sent)
+	}
+
+	content := sent.Attachments[0].Content
+	if content.Type != "AdaptiveCard" || len(content.Body) != 3 || content.Body[0].Text != card.Title ||
+		content.Body[1].Text != card.Text || content.Body[2].Type != "FactSet" ||
+		content.Body[2].Facts[0].Title != "Environment" || content.Body[2].Facts[0].Value != "production" {
+		t.Fatalf("Expected title, text and facts in the body of the card, got: %+v", content.Body)
+	}
+
+	if len(content.Actions) != 1 || content.Actions[0].Type != "Action.OpenUrl" ||
+		content.Actions[0].URL != "https://example.com/dashboard" {
+		t.Fatalf("Expected action opening the URL, got: %+v", content.Actions)
+	}
+}
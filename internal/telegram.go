@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// TelegramMarkdownV2 formats the message with the MarkdownV2 style of the Bot API.
+	TelegramMarkdownV2 = "MarkdownV2"
+
+	// TelegramHTML formats the message with the HTML style of the Bot API.
+	TelegramHTML = "HTML"
+)
+
+// ErrMissingTelegramChat is returned when a notification names no chat and none is configured.
+var ErrMissingTelegramChat = errors.New("telegram chat is missing")
+
+// telegramChatIDPattern matches IDs of chats, negative for groups and channels, and usernames of public channels.
+var telegramChatIDPattern = regexp.MustCompile(`^(-?[0-9]{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+// telegramMarkdownV2Replacer escapes the characters reserved by MarkdownV2.
+var telegramMarkdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`", ">", `\>`,
+	"#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// Telegram holds Telegram Bot API related configuration for sending notifications.
+type Telegram struct {
+	baseURL string
+	token   string
+	chatID  string
+	client  *http.Client
+	// throttle is shared by all chats, the Bot API limits the bot as a whole.
+	throttle *Throttle
+}
+
+// telegramMessage is the request of the sendMessage method.
+type telegramMessage struct {
+	ChatID              string `json:"chat_id"`
+	Text                string `json:"text"`
+	ParseMode           string `json:"parse_mode"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+}
+
+// telegramResponse is the response of every method of the Bot API.
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		// RetryAfter is the number of seconds to wait before retrying rate limited requests.
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// EscapeTelegram escapes the text so that it is shown as is in messages of the parse mode.
+func EscapeTelegram(parseMode, text string) string {
+	if parseMode == TelegramHTML {
+		return html.EscapeString(text)
+	}
+
+	return telegramMarkdownV2Replacer.Replace(text)
+}
+
+// formatTelegramMessage formats the notification in the parse mode, the title in bold above the message. Both are
+// escaped, so that they are shown as sent.
+func formatTelegramMessage(parseMode string, body *TelegramRequestBody) string {
+	text := EscapeTelegram(parseMode, body.Message)
+	if body.Title == "" {
+		return text
+	}
+
+	title := EscapeTelegram(parseMode, body.Title)
+	if parseMode == TelegramHTML {
+		return "<b>" + title + "</b>\n" + text
+	}
+
+	return "*" + title + "*\n" + text
+}
+
+// NotifyTelegram sends Telegram notification to the chat of the notification or the configured one.
+func (s *Service) NotifyTelegram(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_telegramChannel, _telegramProvider, err) }()
+
+	telegram := s.providers.Load().telegram
+	if telegram == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _telegramChannel)
+	}
+
+	body := msg.(*TelegramRequestBody)
+
+	chatID := body.ChatID
+	if chatID == "" {
+		chatID = telegram.chatID
+	}
+
+	if chatID == "" {
+		return Permanent(ErrMissingTelegramChat)
+	}
+
+	parseMode := body.ParseMode
+	if parseMode == "" {
+		parseMode = TelegramMarkdownV2
+	}
+
+	payload, err := json.Marshal(telegramMessage{
+		ChatID:              chatID,
+		Text:                formatTelegramMessage(parseMode, body),
+		ParseMode:           parseMode,
+		DisableNotification: body.Silent,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Telegram message: %v", err)
+	}
+
+	release, err := telegram.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule Telegram notification: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodPost, telegram.methodURL("sendMessage"), bytes.NewReader(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), telegram.token)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	err = sendProviderRequest(ctx, telegram.client, req, _telegramChannel, _telegramProvider, checkTelegramResponse)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send Telegram notification: %w", err), telegram.token)
+	}
+
+	LoggerFromContext(ctx).Debug("Telegram notification sent")
+
+	return nil
+}
+
+// methodURL is the URL of the method of the Bot API, the token of the bot is part of it.
+func (t *Telegram) methodURL(method string) string {
+	return strings.TrimSuffix(t.baseURL, "/") + "/bot" + t.token + "/" + method
+}
+
+// checkTelegramResponse checks the response of the Bot API. Rate limited requests are retried after the delay the
+// API asks for, other client errors, e.g. a chat which blocked the bot, are permanent.
+func checkTelegramResponse(resp *http.Response, body []byte) error {
+	var res telegramResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		if err := responseStatusError(resp); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	if res.OK {
+		return nil
+	}
+
+	err := fmt.Errorf("request failed with error %d: %s", res.ErrorCode, res.Description)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return RetryAfter(err, time.Duration(res.Parameters.RetryAfter)*time.Second)
+	case resp.StatusCode < http.StatusInternalServerError:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+// checkTelegram verifies the token of the bot with the getMe method.
+func (s *Service) checkTelegram(ctx context.Context) error {
+	telegram := s.providers.Load().telegram
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, telegram.methodURL("getMe"), nil)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), telegram.token)
+	}
+
+	resp, err := telegram.client.Do(req)
+	if err != nil {
+		return redactError(fmt.Errorf("bot API unreachable: %w", err), telegram.token)
+	}
+
+	//nolint: errcheck
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
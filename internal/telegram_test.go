@@ -0,0 +1,203 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// telegramMessage is the sendMessage request checked by the tests.
+type telegramMessage struct {
+	ChatID              string `json:"chat_id"`
+	Text                string `json:"text"`
+	ParseMode           string `json:"parse_mode"`
+	DisableNotification bool   `json:"disable_notification"`
+}
+
+func TestEscapeTelegram(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name      string
+		parseMode string
+		text      string
+		expected  string
+	}
+
+	tests := []test{
+		{
+			name:      "reserved MarkdownV2 characters should be escaped",
+			parseMode: internal.TelegramMarkdownV2,
+			text:      `CPU > 90% on web-1.example.com (eu_west) [see *dashboard*]! \o/`,
+			expected:  `CPU \> 90% on web\-1\.example\.com \(eu\_west\) \[see \*dashboard\*\]\! \\o/`,
+		},
+		{
+			name:      "HTML tags should be escaped",
+			parseMode: internal.TelegramHTML,
+			text:      `<b>5 > 3 & "quoted"</b>`,
+			expected:  `&lt;b&gt;5 &gt; 3 &amp; &#34;quoted&#34;&lt;/b&gt;`,
+		},
+	}
+
+	for _, tc := range tests {
+		if got := internal.EscapeTelegram(tc.parseMode, tc.text); got != tc.expected {
+			t.Fatalf("%s: expected: %s, got: %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestTelegramNotification(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		messages []telegramMessage
+		response = func(w http.ResponseWriter) { w.Write([]byte(`{"ok": true, "result": {}}`)) } //nolint: errcheck
+	)
+
+	bot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var msg telegramMessage
+		if r.URL.Path != "/botbot-token/sendMessage" || json.NewDecoder(r.Body).Decode(&msg) != nil {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		messages = append(messages, msg)
+		response(w)
+	}))
+	defer bot.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Telegram = internal.TelegramConfig{
+			Enabled: true, BotToken: "bot-token", BaseURL: bot.URL, ChatID: "-1001234567890",
+		}
+		// The request deadline has to leave room for the delay asked for by rate limited responses.
+		config.Retry.Delay = 250 * time.Millisecond
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	rateLimited := true
+
+	type test struct {
+		name               string
+		requestBody        any
+		response           func(w http.ResponseWriter)
+		expectedStatusCode int
+		expectedMessages   int
+	}
+
+	tests := []test{
+		{
+			name:               "message should be sent to the configured chat formatted as MarkdownV2",
+			requestBody:        &internal.TelegramRequestBody{Title: "Deploy", Message: "Version 1.2.3 is live!"},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   1,
+		},
+		{
+			name: "silent HTML message should be sent to its own chat",
+			requestBody: &internal.TelegramRequestBody{
+				Title: "Deploy", Message: "<ok>", ChatID: "@ops_alerts", ParseMode: internal.TelegramHTML, Silent: true,
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   2,
+		},
+		{
+			name:        "rate limited message should be retried after the delay",
+			requestBody: &internal.TelegramRequestBody{Message: "Hello"},
+			response: func(w http.ResponseWriter) {
+				if rateLimited {
+					rateLimited = false
+
+					w.WriteHeader(http.StatusTooManyRequests)
+					//nolint: errcheck
+					w.Write([]byte(`{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 1",
+						"parameters": {"retry_after": 1}}`))
+
+					return
+				}
+
+				w.Write([]byte(`{"ok": true, "result": {}}`)) //nolint: errcheck
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   4,
+		},
+		{
+			name:        "message to a chat which blocked the bot should not be retried",
+			requestBody: &internal.TelegramRequestBody{Message: "Hello"},
+			response: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusForbidden)
+				//nolint: errcheck
+				w.Write([]byte(`{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessages:   5,
+		},
+		{
+			name:               "message with invalid chat should be rejected",
+			requestBody:        &internal.TelegramRequestBody{Message: "Hello", ChatID: "ops alerts"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   5,
+		},
+		{
+			name:               "message with unknown parse mode should be rejected",
+			requestBody:        &internal.TelegramRequestBody{Message: "Hello", ParseMode: "Markdown"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   5,
+		},
+	}
+
+	for _, tc := range tests {
+		mu.Lock()
+		if tc.response != nil {
+			response = tc.response
+		}
+		mu.Unlock()
+
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/telegram", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		mu.Lock()
+		sent := len(messages)
+		mu.Unlock()
+
+		if sent != tc.expectedMessages {
+			t.Fatalf("%s: Expected %d messages sent, got: %d", tc.name, tc.expectedMessages, sent)
+		}
+	}
+
+	expected := telegramMessage{
+		ChatID: "-1001234567890", Text: "*Deploy*\nVersion 1\\.2\\.3 is live\\!", ParseMode: internal.TelegramMarkdownV2,
+	}
+	if messages[0] != expected {
+		t.Fatalf("Expected %+v, got: %+v", expected, messages[0])
+	}
+
+	expected = telegramMessage{
+		ChatID: "@ops_alerts", Text: "<b>Deploy</b>\n&lt;ok&gt;", ParseMode: internal.TelegramHTML,
+		DisableNotification: true,
+	}
+	if messages[1] != expected {
+		t.Fatalf("Expected %+v, got: %+v", expected, messages[1])
+	}
+}
@@ -0,0 +1,674 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+const (
+	_tenantsKey = "tenants"
+	_apiKeysKey = "api_keys"
+
+	_tenantStoreSource  = "tenant store"
+	_tenantStoreTimeout = 10 * time.Second
+)
+
+// ErrUnknownTenant is returned when sending a notification of a tenant which is no longer configured, it is
+// permanent.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// TenantConfig holds the configuration of a tenant - a product sharing the deployment with its own provider
+// credentials, sender identities, rate limits and API keys.
+//
+// Tenants are read from the tenants table of the configuration file, keyed by their ID, or from a TenantStore.
+// Only fields tagged with tenant:"true" can be set per tenant, the rest is inherited from the deployment.
+type TenantConfig struct {
+	ID string
+	// APIKeys authenticate the clients of the tenant, sent in the X-API-Key header.
+	APIKeys []string
+	// Config is the configuration of the deployment with the settings of the tenant applied.
+	Config *Config
+}
+
+// NewTenantConfig builds the configuration of a tenant kept in a TenantStore, applying the values, keyed like in the
+// tenants table of the configuration file, e.g. twilio.number, on top of the configuration of the deployment.
+// Values of secret fields may be references to secrets.
+func NewTenantConfig(base *Config, id string, apiKeys []string, values map[string]string) (TenantConfig, error) {
+	tenantValues := make(map[string]any, len(values)+1)
+	for key, value := range values {
+		tenantValues[key] = value
+	}
+
+	tenantValues[_apiKeysKey] = apiKeys
+
+	tenant, errs := buildTenant(base, id, tenantValues, _tenantStoreSource, defaultSecretProviders())
+	if len(errs) > 0 {
+		return TenantConfig{}, errors.Join(errs...)
+	}
+
+	if err := tenant.validate(make(map[string]string)); err != nil {
+		return TenantConfig{}, err
+	}
+
+	return tenant, nil
+}
+
+// fields lists the fields which can be set per tenant, keyed under the tenants table. Unlike for the deployment,
+// changes of tenants never require restart as their providers are rebuilt on reload.
+func (t TenantConfig) fields() []configField {
+	var fields []configField
+
+	for _, f := range configFields(reflect.ValueOf(t.Config).Elem(), joinConfigKey(_tenantsKey, t.ID), "") {
+		if f.tenant {
+			f.restart = false
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// validate checks the configuration of the tenant, keys records the tenant owning every API key seen so far.
+func (t TenantConfig) validate(keys map[string]string) error {
+	key := joinConfigKey(_tenantsKey, t.ID)
+
+	var errs []error
+
+	if len(t.APIKeys) == 0 {
+		errs = append(errs, fmt.Errorf("%s.%s is required", key, _apiKeysKey))
+	}
+
+	for _, apiKey := range t.APIKeys {
+		if owner, ok := keys[apiKey]; ok {
+			errs = append(errs, fmt.Errorf("%s.%s: key is already used by tenant %s", key, _apiKeysKey, owner))
+
+			continue
+		}
+
+		keys[apiKey] = t.ID
+	}
+
+	errs = append(errs, validateConfig(t.Config, key))
+
+	return errors.Join(errs...)
+}
+
+// extractTenantValues moves the values of the tenants table out of the values of the configuration file,
+// grouping them by tenant.
+func extractTenantValues(values map[string]any, file string) (map[string]map[string]any, []error) {
+	tenants := make(map[string]map[string]any)
+
+	var errs []error
+
+	for _, key := range sortedKeys(values) {
+		rest, ok := strings.CutPrefix(key, _tenantsKey+".")
+		if !ok {
+			continue
+		}
+
+		value := values[key]
+		delete(values, key)
+
+		id, name, ok := strings.Cut(rest, ".")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s: expected a table", file, key))
+
+			continue
+		}
+
+		if tenants[id] == nil {
+			tenants[id] = make(map[string]any)
+		}
+
+		tenants[id][name] = value
+	}
+
+	return tenants, errs
+}
+
+// buildTenant applies the values of the tenant on top of a copy of the configuration of the deployment.
+func buildTenant(
+	base *Config, id string, values map[string]any, source string, providers map[string]SecretProvider,
+) (TenantConfig, []error) {
+	config := *base
+	config.Tenants = nil
+
+	tenant := TenantConfig{ID: id, Config: &config}
+	prefix := joinConfigKey(_tenantsKey, id)
+
+	fields := tenant.fields()
+
+	tenantFields := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		tenantFields[f.key] = true
+	}
+
+	var errs []error
+
+	fieldValues := make(map[string]any, len(values))
+
+	for _, name := range sortedKeys(values) {
+		key := joinConfigKey(prefix, name)
+
+		if name == _apiKeysKey {
+			apiKeys, err := tenantAPIKeys(values[name], providers)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %v", source, key, err))
+			}
+
+			tenant.APIKeys = apiKeys
+
+			continue
+		}
+
+		if !tenantFields[key] && isConfigKey(name) {
+			errs = append(errs, fmt.Errorf("%s: %s: can't be set per tenant", source, key))
+
+			continue
+		}
+
+		fieldValues[key] = values[name]
+	}
+
+	errs = append(errs, applyConfigValues(fields, fieldValues, source)...)
+	errs = append(errs, resolveSecrets(fields, providers)...)
+
+	return tenant, errs
+}
+
+// isConfigKey reports whether the key names a field of the configuration.
+func isConfigKey(key string) bool {
+	for _, f := range configFields(reflect.ValueOf(&Config{}).Elem(), "", "") {
+		if f.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tenantAPIKeys reads a single API key or a list of them, keys may be references to secrets.
+func tenantAPIKeys(value any, providers map[string]SecretProvider) ([]string, error) {
+	var raw []string
+
+	switch value := value.(type) {
+	case string:
+		raw = []string{value}
+	case []string:
+		raw = value
+	case []any:
+		for _, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %T", v)
+			}
+
+			raw = append(raw, s)
+		}
+	default:
+		return nil, fmt.Errorf("expected a list of strings, got %T", value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), _secretResolveTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(raw))
+
+	for _, r := range raw {
+		key, err := resolveSecret(ctx, r, providers)
+		if err != nil {
+			return nil, err
+		}
+
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// TenantStore provides tenants kept outside of the configuration file, e.g. in a database. Tenants are built with
+// NewTenantConfig from the configuration of the deployment.
+//
+// Implementations of TenantStore must be safe for concurrent use by multiple goroutines.
+type TenantStore interface {
+	ListTenants(ctx context.Context, base *Config) ([]TenantConfig, error)
+}
+
+// TenantStoreFunc is an adapter to allow the use of ordinary functions as TenantStore.
+type TenantStoreFunc func(ctx context.Context, base *Config) ([]TenantConfig, error)
+
+// ListTenants calls f(ctx, base).
+func (f TenantStoreFunc) ListTenants(ctx context.Context, base *Config) ([]TenantConfig, error) {
+	return f(ctx, base)
+}
+
+// Tenant is a tenant along with the providers built from its configuration.
+type Tenant struct {
+	ID     string
+	Config *Config
+	// base is the configuration of the tenant before the build functions were applied.
+	base    *Config
+	service *Service
+}
+
+// Tenants resolves the tenant of every request by its API key and sends its notifications through its own
+// providers. Without tenants the deployment serves a single tenant and API keys aren't required.
+//
+// Tenants is safe for concurrent use by multiple goroutines.
+type Tenants struct {
+	mu       sync.Mutex
+	stores   []TenantStore
+	build    []func(TenantConfig) (*Config, error)
+	config   *Config
+	registry atomic.Pointer[tenantRegistry]
+}
+
+type tenantRegistry struct {
+	byID  map[string]*Tenant
+	byKey map[string]*Tenant
+}
+
+// NewTenants is a constructor function for Tenants, tenants are loaded by Reload.
+func NewTenants(stores ...TenantStore) *Tenants {
+	t := &Tenants{stores: stores}
+	t.registry.Store(&tenantRegistry{})
+
+	return t
+}
+
+// OnBuild registers the function changing the configuration of every tenant on reload, e.g. applying the
+// credentials of the tenant managed through the admin API. Functions are called in registration order.
+func (t *Tenants) OnBuild(build func(TenantConfig) (*Config, error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.build = append(t.build, build)
+}
+
+// Reload replaces the tenants with the ones of the configuration and of the stores. Providers of tenants which are
+// kept are reloaded in place, so that their throttles survive. On error the tenants in effect are kept.
+func (t *Tenants) Reload(ctx context.Context, config *Config) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.reload(ctx, config)
+}
+
+// rebuild reloads the tenants with the configuration they were last reloaded with, if any.
+func (t *Tenants) rebuild(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.config == nil {
+		return nil
+	}
+
+	return t.reload(ctx, t.config)
+}
+
+// reload replaces the tenants, t.mu must be held.
+func (t *Tenants) reload(ctx context.Context, config *Config) error {
+	configs := append([]TenantConfig(nil), config.Tenants...)
+
+	if len(t.stores) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, _tenantStoreTimeout)
+		defer cancel()
+
+		for _, store := range t.stores {
+			stored, err := store.ListTenants(ctx, config)
+			if err != nil {
+				return fmt.Errorf("failed to list tenants: %v", err)
+			}
+
+			configs = append(configs, stored...)
+		}
+	}
+
+	next := &tenantRegistry{byID: make(map[string]*Tenant), byKey: make(map[string]*Tenant)}
+
+	for _, tc := range configs {
+		if _, ok := next.byID[tc.ID]; ok {
+			return fmt.Errorf("duplicate tenant %s", tc.ID)
+		}
+
+		tenant := &Tenant{ID: tc.ID, Config: tc.Config, base: tc.Config}
+
+		for _, build := range t.build {
+			built, err := build(TenantConfig{ID: tc.ID, APIKeys: tc.APIKeys, Config: tenant.Config})
+			if err != nil {
+				return fmt.Errorf("tenant %s: %v", tc.ID, err)
+			}
+
+			tenant.Config = built
+		}
+
+		for _, key := range tc.APIKeys {
+			if owner, ok := next.byKey[key]; ok {
+				return fmt.Errorf("API key of tenant %s is already used by tenant %s", tc.ID, owner.ID)
+			}
+
+			next.byKey[key] = tenant
+		}
+
+		next.byID[tc.ID] = tenant
+	}
+
+	prev := t.registry.Load()
+
+	for _, tenant := range next.byID {
+		if p, ok := prev.byID[tenant.ID]; ok {
+			p.service.Reload(tenant.Config)
+			tenant.service = p.service
+
+			continue
+		}
+
+		tenant.service = NewService(tenant.Config)
+	}
+
+	t.registry.Store(next)
+	t.config = config
+
+	return nil
+}
+
+// Enabled reports whether any tenant is configured, requiring every request to carry the API key of a tenant.
+func (t *Tenants) Enabled() bool {
+	return len(t.registry.Load().byID) > 0
+}
+
+// Tenant looks up the tenant by its ID.
+func (t *Tenants) Tenant(id string) (*Tenant, bool) {
+	tenant, ok := t.registry.Load().byID[id]
+
+	return tenant, ok
+}
+
+// Authenticate looks up the tenant owning the API key.
+func (t *Tenants) Authenticate(apiKey string) (*Tenant, bool) {
+	tenant, ok := t.registry.Load().byKey[apiKey]
+
+	return tenant, ok
+}
+
+// channelEnabled reports whether any tenant has the channel enabled.
+func (t *Tenants) channelEnabled(channel string) bool {
+	for _, tenant := range t.registry.Load().byID {
+		if tenant.Config.channelEnabled(channel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Notifier returns Notifier sending notifications of tenants, as stored in the context by TenantMiddleware, through
+// the providers of the tenant and other notifications through fallback.
+func (t *Tenants) Notifier(fallback Notifier) Notifier {
+	return &tenantNotifier{tenants: t, fallback: fallback}
+}
+
+type tenantNotifier struct {
+	tenants  *Tenants
+	fallback Notifier
+}
+
+var _ Notifier = (*tenantNotifier)(nil)
+
+func (n *tenantNotifier) notifier(ctx context.Context) (Notifier, error) {
+	id := TenantIDFromContext(ctx)
+	if id == "" {
+		return n.fallback, nil
+	}
+
+	tenant, ok := n.tenants.Tenant(id)
+	if !ok {
+		return nil, Permanent(fmt.Errorf("%w: %s", ErrUnknownTenant, id))
+	}
+
+	return tenant.service, nil
+}
+
+// NotifySlack sends Slack notification through the providers of the tenant.
+func (n *tenantNotifier) NotifySlack(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifySlack(ctx, msg)
+}
+
+// NotifySMS sends SMS notification through the providers of the tenant.
+func (n *tenantNotifier) NotifySMS(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifySMS(ctx, msg)
+}
+
+// NotifyMail sends mail notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyMail(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyMail(ctx, msg)
+}
+
+// NotifyWebhook sends webhook notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyWebhook(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyWebhook(ctx, msg)
+}
+
+// NotifyTeams sends Microsoft Teams notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyTeams(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyTeams(ctx, msg)
+}
+
+// NotifyDiscord sends Discord notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyDiscord(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyDiscord(ctx, msg)
+}
+
+// NotifyTelegram sends Telegram notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyTelegram(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyTelegram(ctx, msg)
+}
+
+// NotifyChat sends Mattermost or Rocket.Chat notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyChat(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyChat(ctx, msg)
+}
+
+// NotifyMatrix sends Matrix notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyMatrix(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyMatrix(ctx, msg)
+}
+
+// NotifyFCM sends FCM push notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyFCM(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyFCM(ctx, msg)
+}
+
+// NotifyAPNS sends APNs push notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyAPNS(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyAPNS(ctx, msg)
+}
+
+// NotifyWebPush sends web push notification through the providers of the tenant.
+func (n *tenantNotifier) NotifyWebPush(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyWebPush(ctx, msg)
+}
+
+// NotifyVoice places voice call through the providers of the tenant.
+func (n *tenantNotifier) NotifyVoice(ctx context.Context, msg any) error {
+	notifier, err := n.notifier(ctx)
+	if err != nil {
+		return err
+	}
+
+	return notifier.NotifyVoice(ctx, msg)
+}
+
+type (
+	tenantContextKey struct{}
+	apiKeyContextKey struct{}
+)
+
+// WithTenantID stores the ID of the tenant the request is made for in the context.
+func WithTenantID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, id)
+}
+
+// TenantIDFromContext retrieves the ID of the tenant stored in the context, empty if there is none.
+func TenantIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantContextKey{}).(string)
+
+	return id
+}
+
+// apiKeyFromContext retrieves the API key the request was authenticated with, empty if there is none.
+func apiKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyContextKey{}).(string)
+
+	return key
+}
+
+// TenantMiddleware resolves the tenant of the request from its API key and stores it in the request context.
+// Requests without a valid API key are rejected with 401 Unauthorized once any tenant is configured.
+func TenantMiddleware(tenants *Tenants) httptreemux.MiddlewareFunc {
+	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+			if !tenants.Enabled() {
+				next(w, r, m)
+
+				return
+			}
+
+			apiKey := r.Header.Get(_apiKeyHeader)
+
+			tenant, ok := tenants.Authenticate(apiKey)
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error": "Unauthorized."}`, http.StatusUnauthorized)
+
+				return
+			}
+
+			ctx := WithTenantID(r.Context(), tenant.ID)
+			ctx = context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+			ctx = WithLogger(ctx, LoggerFromContext(ctx).With("tenant", tenant.ID))
+
+			next(w, r.WithContext(ctx), m)
+		}
+	}
+}
+
+// tenantChannelMiddleware rejects requests of tenants which don't have the channel enabled, requests without tenant
+// are checked against the configuration of the deployment.
+func tenantChannelMiddleware(config *Config, tenants *Tenants, channel string) httptreemux.MiddlewareFunc {
+	disabled := MakeDisabledChannelEndpoint(channel)
+
+	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+			enabled := config.channelEnabled(channel)
+			if tenant, ok := tenants.Tenant(TenantIDFromContext(r.Context())); ok {
+				enabled = tenant.Config.channelEnabled(channel)
+			}
+
+			if !enabled {
+				disabled(w, r)
+
+				return
+			}
+
+			next(w, r, m)
+		}
+	}
+}
+
+// tenantRateLimitMiddleware limits requests by the rules built from the configuration of their tenant, falling back
+// to the configuration of the deployment. Limits of tenants are kept apart by prefixing the rules with their ID.
+func tenantRateLimitMiddleware(
+	limiter RateLimiter, config *Config, tenants *Tenants, rules func(*Config) []RateLimitRule,
+) httptreemux.MiddlewareFunc {
+	return func(next httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+		fallback := RateLimitMiddleware(limiter, rules(config)...)(next)
+
+		return func(w http.ResponseWriter, r *http.Request, m map[string]string) {
+			tenant, ok := tenants.Tenant(TenantIDFromContext(r.Context()))
+			if !ok {
+				fallback(w, r, m)
+
+				return
+			}
+
+			tenantRules := rules(tenant.Config)
+			for i := range tenantRules {
+				tenantRules[i].Name = tenant.ID + "/" + tenantRules[i].Name
+			}
+
+			RateLimitMiddleware(limiter, tenantRules...)(next)(w, r, m)
+		}
+	}
+}
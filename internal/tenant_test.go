@@ -0,0 +1,242 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+const _testTenantsConfig = `
+tenants:
+  acme:
+    api_keys: [acme-key, acme-key-2]
+    slack_web_hook_url: http://example.com/acme-hook
+    twilio:
+      number: "+15550001111"
+      token: acme-token
+    rate_limit:
+      client_rate: 2
+  globex:
+    api_keys: globex-key
+    slack_enabled: false
+`
+
+func TestTenantConfig(t *testing.T) {
+	t.Setenv("SLACK_ENABLED", "")
+
+	config, err := internal.NewConfig(
+		internal.WithConfigFile(writeConfigFile(t, "config.yaml", _testConfigFile+_testTenantsConfig)),
+	)
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	if len(config.Tenants) != 2 || config.Tenants[0].ID != "acme" || config.Tenants[1].ID != "globex" {
+		t.Fatalf("Expected tenants acme and globex, got: %+v", config.Tenants)
+	}
+
+	acme, globex := config.Tenants[0], config.Tenants[1]
+
+	if len(acme.APIKeys) != 2 || acme.APIKeys[1] != "acme-key-2" {
+		t.Fatalf("Expected API keys of the tenant, got: %v", acme.APIKeys)
+	}
+
+	if acme.Config.Twilio.Number != "+15550001111" || acme.Config.Twilio.Token != "acme-token" {
+		t.Fatalf("Expected credentials of the tenant, got: %+v", acme.Config.Twilio)
+	}
+
+	if acme.Config.Twilio.SID != config.Twilio.SID || acme.Config.Mail != config.Mail {
+		t.Fatalf("Expected settings not set by the tenant to be inherited, got: %+v", acme.Config)
+	}
+
+	if acme.Config.RateLimit.ClientRate != 2 || config.RateLimit.ClientRate == 2 {
+		t.Fatalf("Expected rate limit of the tenant only, got: %v", acme.Config.RateLimit.ClientRate)
+	}
+
+	if globex.Config.SlackEnabled || !config.SlackEnabled {
+		t.Fatal("Expected Slack to be disabled for the tenant only")
+	}
+
+	var out bytes.Buffer
+	if err := config.WriteRedacted(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "acme:") || !strings.Contains(out.String(), `number: "+15550001111"`) {
+		t.Fatalf("Expected tenants to be printed, got:\n%s", out.String())
+	}
+
+	for _, secret := range []string{"acme-key", "globex-key", "acme-token", "acme-hook"} {
+		if strings.Contains(out.String(), secret) {
+			t.Fatalf("Expected secret %q to be redacted, got:\n%s", secret, out.String())
+		}
+	}
+}
+
+func TestTenantConfigErrors(t *testing.T) {
+	type test struct {
+		name          string
+		tenants       string
+		expectedError string
+	}
+
+	tests := []test{
+		{
+			name:          "settings of the deployment should not be set per tenant",
+			tenants:       "tenants:\n  acme:\n    api_keys: [acme-key]\n    server:\n      port: 9001\n",
+			expectedError: "tenants.acme.server.port: can't be set per tenant",
+		},
+		{
+			name:          "tenant without API keys should be rejected",
+			tenants:       "tenants:\n  acme:\n    twilio:\n      number: \"+15550001111\"\n",
+			expectedError: "tenants.acme.api_keys is required",
+		},
+		{
+			name:          "API key of another tenant should be rejected",
+			tenants:       "tenants:\n  acme:\n    api_keys: [shared]\n  globex:\n    api_keys: [shared]\n",
+			expectedError: "tenants.globex.api_keys: key is already used by tenant acme",
+		},
+		{
+			name:          "invalid settings of the tenant should be rejected",
+			tenants:       "tenants:\n  acme:\n    api_keys: [acme-key]\n    rate_limit:\n      client_rate: -1\n",
+			expectedError: "tenants.acme.rate_limit.client_rate must be greater than or equal to 0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := internal.NewConfig(
+				internal.WithConfigFile(writeConfigFile(t, "config.yaml", _testConfigFile+tc.tenants)),
+			)
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("Expected error containing %q, got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestTenantRequests(t *testing.T) {
+	t.Parallel()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var baseCalls, acmeCalls atomic.Int32
+
+	base := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseCalls.Add(1)
+	}))
+	defer base.Close()
+
+	acme := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acmeCalls.Add(1)
+	}))
+	defer acme.Close()
+
+	config.SlackWebHookURL = base.URL
+	config.Throttle.SlackRate = 0
+
+	store := internal.TenantStoreFunc(func(ctx context.Context, base *internal.Config) ([]internal.TenantConfig, error) {
+		values := map[string]map[string]string{
+			"acme":    {"slack_web_hook_url": acme.URL},
+			"globex":  {"slack_enabled": "false"},
+			"initech": {"slack_web_hook_url": acme.URL, "rate_limit.channel_burst": "1"},
+		}
+
+		tenants := make([]internal.TenantConfig, 0, len(values))
+
+		for id, v := range values {
+			tenant, err := internal.NewTenantConfig(base, id, []string{id + "-key"}, v)
+			if err != nil {
+				return nil, err
+			}
+
+			tenants = append(tenants, tenant)
+		}
+
+		return tenants, nil
+	})
+
+	tenants := internal.NewTenants(store)
+	if err := tenants.Reload(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := internal.NewMux(config, logger, internal.NewService(config), internal.WithTenants(tenants))
+
+	type test struct {
+		name               string
+		apiKey             string
+		expectedStatusCode int
+	}
+
+	tests := []test{
+		{
+			name:               "request without API key should be unauthorized",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "request with unknown API key should be unauthorized",
+			apiKey:             "unknown-key",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "request of a tenant should be sent through its providers",
+			apiKey:             "acme-key",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "request of a tenant without the channel should not be implemented",
+			apiKey:             "globex-key",
+			expectedStatusCode: http.StatusNotImplemented,
+		},
+		{
+			name:               "first request of a tenant within its rate limit should be sent",
+			apiKey:             "initech-key",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "request of a tenant over its rate limit should be rejected",
+			apiKey:             "initech-key",
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tc := range tests {
+		payload, err := json.Marshal(&internal.SlackRequestBody{Message: "Hello"})
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload))
+		if tc.apiKey != "" {
+			req.Header.Set("X-API-Key", tc.apiKey)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v",
+				tc.name, tc.expectedStatusCode, res.Result().StatusCode)
+		}
+	}
+
+	if acmeCalls.Load() != 2 || baseCalls.Load() != 0 {
+		t.Fatalf("Expected notifications sent through the providers of the tenants only, got: %d, deployment: %d",
+			acmeCalls.Load(), baseCalls.Load())
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrProviderThrottled is returned when a send can't be scheduled within the deadline of its context.
+var ErrProviderThrottled = errors.New("provider throughput limit exceeded")
+
+// Throttle shapes outbound throughput to a provider, limiting both the send rate and the number of concurrent sends.
+//
+// Throttle is safe for concurrent use by multiple goroutines.
+type Throttle struct {
+	mu     sync.Mutex
+	limit  RateLimit
+	bucket *tokenBucket
+	slots  chan struct{}
+}
+
+// NewThrottle is a constructor function for Throttle. Rate is in messages per second, zero rate or
+// concurrency means no limit.
+func NewThrottle(rate float64, concurrency int) *Throttle {
+	t := &Throttle{}
+
+	if rate > 0 {
+		t.limit = RateLimit{Rate: rate, Burst: int(math.Max(1, math.Ceil(rate)))}
+		t.bucket = newTokenBucket(time.Now(), t.limit)
+	}
+
+	if concurrency > 0 {
+		t.slots = make(chan struct{}, concurrency)
+	}
+
+	return t
+}
+
+// Acquire waits until a send is allowed and returns the function releasing it once the send completes.
+//
+// Sends are queued as long as the context allows, if the context has a deadline which would pass
+// before the send is scheduled, ErrProviderThrottled is returned right away.
+func (t *Throttle) Acquire(ctx context.Context) (func(), error) {
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release := func() {
+		if t.slots != nil {
+			<-t.slots
+		}
+	}
+
+	if err := t.wait(ctx); err != nil {
+		release()
+
+		return nil, err
+	}
+
+	return release, nil
+}
+
+func (t *Throttle) wait(ctx context.Context) error {
+	if t.bucket == nil {
+		return nil
+	}
+
+	delay, err := t.reserve(ctx)
+	if err != nil || delay == 0 {
+		return err
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.bucket.cancel()
+
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait before sending.
+func (t *Throttle) reserve(ctx context.Context) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	delay := t.bucket.reserve(now, t.limit)
+
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		t.bucket.cancel()
+
+		return 0, ErrProviderThrottled
+	}
+
+	return delay, nil
+}
@@ -0,0 +1,91 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+func TestThrottleRate(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name       string
+		rate       float64
+		sends      int
+		ctxTimeout time.Duration
+		err        error
+	}
+
+	tests := []test{
+		{
+			name:       "send should be queued when context deadline allows it",
+			rate:       4,
+			sends:      5,
+			ctxTimeout: time.Second * 2,
+		},
+		{
+			name:       "send should fail right away when it can't be scheduled within context deadline",
+			rate:       0.1,
+			sends:      2,
+			ctxTimeout: time.Second,
+			err:        internal.ErrProviderThrottled,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			throttle := internal.NewThrottle(tc.rate, 0)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.ctxTimeout)
+			defer cancel()
+
+			for i := 0; i < tc.sends; i++ {
+				release, err := throttle.Acquire(ctx)
+				if err != nil {
+					if !errors.Is(err, tc.err) {
+						t.Fatalf("\nExpected: %v\nActual: %v", tc.err, err)
+					}
+
+					return
+				}
+
+				release()
+			}
+
+			if tc.err != nil {
+				t.Fatalf("Expected error %v, got nil", tc.err)
+			}
+		})
+	}
+}
+
+func TestThrottleConcurrency(t *testing.T) {
+	t.Parallel()
+
+	throttle := internal.NewThrottle(0, 1)
+
+	release, err := throttle.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected error to be nil but got: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	if _, err := throttle.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected second send to wait for the first one, got: %v", err)
+	}
+
+	release()
+
+	release, err = throttle.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected send to be allowed after release but got: %s", err)
+	}
+
+	release()
+}
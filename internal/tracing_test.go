@@ -0,0 +1,138 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+	"github.com/kkereziev/notifier/internal/mocks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingPropagation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceParent = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+
+	recorder := tracetest.NewSpanRecorder()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notificationTraceID trace.TraceID
+
+	notifierMock := &mocks.NotifierMock{
+		NotifySlackFunc: func(ctx context.Context, ifaceVal any) error {
+			notificationTraceID = trace.SpanContextFromContext(ctx).TraceID()
+
+			return nil
+		},
+	}
+
+	mux := internal.NewMux(config, logger, notifierMock)
+
+	payload, err := json.Marshal(&internal.SlackRequestBody{Message: "Hello"})
+	if err != nil {
+		t.Fatalf("failed to marshal Slack message: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/slack", bytes.NewBuffer(payload))
+	req.Header.Set("traceparent", traceParent)
+
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if notificationTraceID.String() != traceID {
+		t.Fatalf("Different trace ids in notifier, expected: %s, got: %s", traceID, notificationTraceID)
+	}
+
+	spans := map[string]bool{}
+
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == traceID {
+			spans[span.Name()] = true
+		}
+	}
+
+	for _, name := range []string{"POST /api/v1/slack", "retry.attempt"} {
+		if !spans[name] {
+			t.Fatalf("Expected span %s to be recorded in trace %s, got: %v", name, traceID, spans)
+		}
+	}
+}
+
+func TestTracingPropagationToTwilio(t *testing.T) {
+	t.Parallel()
+
+	const (
+		traceID     = "5bf92f3577b34da6a3ce929d0e0e4736"
+		traceParent = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tracetest.NewSpanRecorder())))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	twilio := &fakeTwilio{}
+
+	server := httptest.NewServer(twilio)
+	defer server.Close()
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := internal.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Twilio.Enabled = true
+	config.Twilio.SID = "AC00000000000000000000000000000000"
+	config.Twilio.Token = "token"
+	config.Twilio.Number = "+15005550006"
+	config.Twilio.APIBaseURL = server.URL
+	config.Throttle.TwilioRate = 0
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	payload, err := json.Marshal(&internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"})
+	if err != nil {
+		t.Fatalf("failed to marshal SMS message: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sms", bytes.NewBuffer(payload))
+	req.Header.Set("traceparent", traceParent)
+	req.Header.Set(internal.CorrelationIDHeader, "correlation")
+
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	headers := twilio.lastHeaders()
+
+	if !strings.Contains(headers.Get("traceparent"), traceID) {
+		t.Fatalf("Expected trace %s to be propagated to Twilio, got: %q", traceID, headers.Get("traceparent"))
+	}
+
+	if headers.Get(internal.CorrelationIDHeader) != "correlation" {
+		t.Fatalf("Expected correlation ID to be forwarded to Twilio, got: %q", headers.Get(internal.CorrelationIDHeader))
+	}
+}
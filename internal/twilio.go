@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twilio/twilio-go/client"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	_twilioTimeout = 10 * time.Second
+	// _whatsappPrefix marks WhatsApp addresses, Twilio sends messages to them through WhatsApp instead of SMS.
+	_whatsappPrefix = "whatsapp:"
+)
+
+// ErrUnsupportedSMSFeature is returned when a message uses a feature its address doesn't support, e.g. content
+// template sent to a phone number.
+var ErrUnsupportedSMSFeature = errors.New("feature is not supported for the address")
+
+// twilioError keeps the token of Twilio out of the error of its API. Messages and calls Twilio rejects with a client
+// error, e.g. to an invalid number, don't change on retry and are permanent.
+func twilioError(err error, token string) error {
+	err = redactError(err, token)
+	if isTwilioRejection(err) {
+		return Permanent(err)
+	}
+
+	return err
+}
+
+// isTwilioRejection tells whether Twilio rejected the request with a client error, except for rate limiting.
+func isTwilioRejection(err error) bool {
+	var restErr *client.TwilioRestError
+
+	return errors.As(err, &restErr) && restErr.Status >= http.StatusBadRequest &&
+		restErr.Status < http.StatusInternalServerError && restErr.Status != http.StatusRequestTimeout &&
+		restErr.Status != http.StatusTooManyRequests
+}
+
+func isWhatsappAddress(address string) bool {
+	return strings.HasPrefix(address, _whatsappPrefix)
+}
+
+// checkSMSFeatures verifies the message only uses features supported by its address. Content templates are approved
+// for WhatsApp only and WhatsApp messages carry a single media, phone numbers receive up to ten as MMS.
+func checkSMSFeatures(msg *SMSRequestBody) error {
+	if isWhatsappAddress(msg.SendToNumber) {
+		if len(msg.MediaURLs) > 1 {
+			return fmt.Errorf("%w: WhatsApp messages carry a single media", ErrUnsupportedSMSFeature)
+		}
+
+		return nil
+	}
+
+	if msg.ContentSID != "" {
+		return fmt.Errorf("%w: content templates are only sent to WhatsApp addresses", ErrUnsupportedSMSFeature)
+	}
+
+	return nil
+}
+
+// messageParams builds the parameters of the message, WhatsApp messages are sent from the WhatsApp sender.
+func (t *Twilio) messageParams(msg *SMSRequestBody) (*twilioApi.CreateMessageParams, error) {
+	params := &twilioApi.CreateMessageParams{}
+
+	params.SetTo(msg.SendToNumber)
+	params.SetFrom(t.number)
+
+	if isWhatsappAddress(msg.SendToNumber) {
+		params.SetFrom(_whatsappPrefix + t.whatsappNumber)
+	}
+
+	if msg.Message != "" {
+		params.SetBody(msg.Message)
+	}
+
+	if len(msg.MediaURLs) > 0 {
+		params.SetMediaUrl(msg.MediaURLs)
+	}
+
+	if msg.ContentSID != "" {
+		params.SetContentSid(msg.ContentSID)
+	}
+
+	if len(msg.ContentVariables) > 0 {
+		variables, err := json.Marshal(msg.ContentVariables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal content variables: %v", err)
+		}
+
+		params.SetContentVariables(string(variables))
+	}
+
+	return params, nil
+}
+
+// api returns Twilio API client whose requests are bound to the context, twilio-go itself has no notion of it.
+func (t *Twilio) api(ctx context.Context) *twilioApi.ApiService {
+	c := &client.Client{
+		Credentials: client.NewCredentials(t.sid, t.token),
+		HTTPClient: &http.Client{
+			Transport: &contextTransport{ctx: ctx, baseURL: t.baseURL, next: t.transport},
+			Timeout:   _twilioTimeout,
+			// Like the default client of twilio-go, the most recent response is returned instead of following redirects.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	c.SetAccountSid(t.sid)
+
+	return twilioApi.NewApiService(client.NewRequestHandler(c))
+}
+
+// contextTransport binds requests to the context, optionally redirecting them to another base URL.
+type contextTransport struct {
+	ctx     context.Context
+	baseURL *url.URL
+	next    http.RoundTripper
+}
+
+// RoundTrip sends the request with the context of the transport, the correlation ID and the trace context are
+// forwarded to Twilio.
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(t.ctx)
+
+	if correlationID := CorrelationIDFromContext(t.ctx); correlationID != "" {
+		req.Header.Set(CorrelationIDHeader, correlationID)
+	}
+
+	otel.GetTextMapPropagator().Inject(t.ctx, propagation.HeaderCarrier(req.Header))
+
+	if t.baseURL != nil {
+		req.URL.Scheme = t.baseURL.Scheme
+		req.URL.Host = t.baseURL.Host
+		req.Host = t.baseURL.Host
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,230 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// fakeTwilio is Twilio API sending messages and placing calls, it keeps the parameters of every request.
+type fakeTwilio struct {
+	mu       sync.Mutex
+	calls    []url.Values
+	messages []url.Values
+	headers  http.Header
+}
+
+func (f *fakeTwilio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.PostForm.Get("To") == "+15005550001" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number", "status": 400}`)) //nolint: errcheck
+
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.headers = r.Header.Clone()
+
+	var sid string
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/Calls.json"):
+		f.calls = append(f.calls, r.PostForm)
+		sid = fmt.Sprintf("CA%032d", len(f.calls))
+	case strings.HasSuffix(r.URL.Path, "/Messages.json"):
+		f.messages = append(f.messages, r.PostForm)
+		sid = fmt.Sprintf("SM%032d", len(f.messages))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"sid": %q, "status": "queued"}`, sid) //nolint: errcheck
+}
+
+func (f *fakeTwilio) lastCall() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[len(f.calls)-1]
+}
+
+func (f *fakeTwilio) lastHeaders() http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.headers
+}
+
+func (f *fakeTwilio) lastMessage() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.messages) == 0 {
+		return url.Values{}
+	}
+
+	return f.messages[len(f.messages)-1]
+}
+
+func TestSMSNotificationThroughTwilio(t *testing.T) {
+	t.Parallel()
+
+	twilio := &fakeTwilio{}
+
+	server := httptest.NewServer(twilio)
+	defer server.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Twilio.Enabled = true
+		config.Twilio.SID = "AC00000000000000000000000000000000"
+		config.Twilio.Token = "token"
+		config.Twilio.Number = "+15005550006"
+		config.Twilio.WhatsappNumber = "+14155238886"
+		config.Twilio.APIBaseURL = server.URL
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	template := "HX" + strings.Repeat("a", 32)
+
+	type test struct {
+		name               string
+		requestBody        internal.SMSRequestBody
+		expectedStatusCode int
+		expectedMessage    url.Values
+	}
+
+	tests := []test{
+		{
+			name:               "SMS should be sent from the number",
+			requestBody:        internal.SMSRequestBody{Message: "Hello", SendToNumber: "+35988357997"},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    url.Values{"From": {"+15005550006"}, "To": {"+35988357997"}, "Body": {"Hello"}},
+		},
+		{
+			name: "MMS should carry every media",
+			requestBody: internal.SMSRequestBody{
+				SendToNumber: "+35988357997",
+				MediaURLs:    []string{"https://example.com/a.png", "https://example.com/b.png"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage: url.Values{
+				"From": {"+15005550006"}, "To": {"+35988357997"},
+				"MediaUrl": {"https://example.com/a.png", "https://example.com/b.png"},
+			},
+		},
+		{
+			name: "WhatsApp message should be sent from the WhatsApp sender",
+			requestBody: internal.SMSRequestBody{
+				Message: "Hello", SendToNumber: "whatsapp:+35988357997", MediaURLs: []string{"https://example.com/a.png"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage: url.Values{
+				"From": {"whatsapp:+14155238886"}, "To": {"whatsapp:+35988357997"}, "Body": {"Hello"},
+				"MediaUrl": {"https://example.com/a.png"},
+			},
+		},
+		{
+			name: "WhatsApp content template should be filled in by the variables",
+			requestBody: internal.SMSRequestBody{
+				SendToNumber:     "whatsapp:+35988357997",
+				ContentSID:       template,
+				ContentVariables: map[string]string{"1": "12/1", "2": "3pm"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage: url.Values{
+				"From": {"whatsapp:+14155238886"}, "To": {"whatsapp:+35988357997"},
+				"ContentSid": {template}, "ContentVariables": {`{"1":"12/1","2":"3pm"}`},
+			},
+		},
+		{
+			name:               "SMS rejected by Twilio should fail",
+			requestBody:        internal.SMSRequestBody{Message: "Hello", SendToNumber: "+15005550001"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "content template should not be sent to phone number",
+			requestBody: internal.SMSRequestBody{
+				SendToNumber: "+35988357997", ContentSID: template,
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "WhatsApp message should not carry multiple media",
+			requestBody: internal.SMSRequestBody{
+				SendToNumber: "whatsapp:+35988357997",
+				MediaURLs:    []string{"https://example.com/a.png", "https://example.com/b.png"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "content variables should not be sent without template",
+			requestBody: internal.SMSRequestBody{
+				Message: "Hello", SendToNumber: "whatsapp:+35988357997", ContentVariables: map[string]string{"1": "x"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sent := len(twilio.messages)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/sms", bytes.NewReader(payload)))
+
+		if res.Code != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode, res.Code)
+		}
+
+		if tc.expectedMessage == nil {
+			if len(twilio.messages) != sent {
+				t.Fatalf("%s: Expected message not to be sent, got: %v", tc.name, twilio.lastMessage())
+			}
+
+			continue
+		}
+
+		message := twilio.lastMessage()
+		for key, expected := range tc.expectedMessage {
+			if strings.Join(message[key], " ") != strings.Join(expected, " ") {
+				t.Fatalf("%s: Different %s, expected: %v, got: %v", tc.name, key, expected, message[key])
+			}
+		}
+
+		if message.Has("Body") != tc.expectedMessage.Has("Body") {
+			t.Fatalf("%s: Expected body only when the message has one, got: %v", tc.name, message)
+		}
+	}
+}
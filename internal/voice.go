@@ -0,0 +1,350 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+const (
+	// _acknowledgeDigit is the key callees press to acknowledge the alert.
+	_acknowledgeDigit = "1"
+	// _gatherTimeout is how many seconds Twilio waits for the key after the message is read.
+	_gatherTimeout = 5
+	// _callRetention is how long the state of calls is kept for lookups.
+	_callRetention = 24 * time.Hour
+)
+
+// Statuses of calls, as reported by Twilio. Calls end in one of completed, busy, no-answer, failed or canceled.
+const (
+	CallStatusQueued     = "queued"
+	CallStatusInProgress = "in-progress"
+	CallStatusCompleted  = "completed"
+	CallStatusBusy       = "busy"
+	CallStatusNoAnswer   = "no-answer"
+	CallStatusFailed     = "failed"
+	CallStatusCanceled   = "canceled"
+)
+
+// _callStatusEvents are the progress events of calls Twilio reports to the status callback.
+var _callStatusEvents = []string{"initiated", "ringing", "answered", "completed"}
+
+// Voice holds configuration for placing voice calls through Twilio, the message is read to the callee with
+// text-to-speech.
+type Voice struct {
+	twilio      *Twilio
+	callbackURL string
+	speechVoice string
+	language    string
+	ringTimeout time.Duration
+}
+
+func newVoice(config TwilioConfig) *Voice {
+	return &Voice{
+		twilio: &Twilio{
+			sid:     config.SID,
+			token:   config.Token,
+			number:  config.Number,
+			baseURL: config.baseURL(),
+		},
+		callbackURL: strings.TrimSuffix(config.Voice.CallbackURL, "/"),
+		speechVoice: config.Voice.SpeechVoice,
+		language:    config.Voice.Language,
+		ringTimeout: config.Voice.RingTimeout,
+	}
+}
+
+// NotifyVoice places voice call reading the message, optionally asking the callee to acknowledge it. Progress of
+// the call is reported by Twilio to the callbacks of the call.
+func (s *Service) NotifyVoice(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_voiceChannel, _twilioProvider, err) }()
+
+	voice := s.providers.Load().voice
+	if voice == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _voiceChannel)
+	}
+
+	voiceMsg := msg.(*VoiceRequestBody)
+
+	twiml, err := voice.twiml(voiceMsg)
+	if err != nil {
+		return err
+	}
+
+	params := &twilioApi.CreateCallParams{}
+
+	params.SetTo(voiceMsg.SendToNumber)
+	params.SetFrom(voice.twilio.number)
+	params.SetTwiml(twiml)
+	params.SetTimeout(int(voice.ringTimeout.Seconds()))
+
+	if voiceMsg.CallID != "" {
+		params.SetStatusCallback(voice.callback(_voiceStatusURL, voiceMsg))
+		params.SetStatusCallbackEvent(_callStatusEvents)
+		params.SetStatusCallbackMethod(http.MethodPost)
+	}
+
+	release, err := voice.twilio.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule voice call: %w", err)
+	}
+	defer release()
+
+	ctx, span := startProviderSpan(ctx, _voiceChannel, _twilioProvider)
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	resp, err := voice.twilio.api(ctx).CreateCall(params)
+
+	observeProviderDuration(_voiceChannel, _twilioProvider, start)
+
+	if err != nil {
+		return twilioError(err, voice.twilio.token)
+	}
+
+	if resp.Sid != nil {
+		LoggerFromContext(ctx).Debug("Voice call placed", "sid", *resp.Sid, "call_id", voiceMsg.CallID)
+	}
+
+	return nil
+}
+
+// checkVoice looks up the account calls are placed with.
+func (s *Service) checkVoice(ctx context.Context) error {
+	twilio := s.providers.Load().voice.twilio
+
+	if _, err := twilio.api(ctx).FetchAccount(twilio.sid); err != nil {
+		return redactError(fmt.Errorf("account lookup failed: %w", err), twilio.token)
+	}
+
+	return nil
+}
+
+// twiml builds the instructions of the call. The message is read Repeat times, when it is to be acknowledged the
+// callee is asked to press the key after every reading and the key is reported to the gather callback.
+func (v *Voice) twiml(msg *VoiceRequestBody) (string, error) {
+	say := twimlSay{Voice: v.speechVoice, Language: v.language, Text: msg.Message}
+
+	var response twimlResponse
+
+	if msg.Acknowledge && msg.CallID != "" {
+		prompt := twimlSay{Voice: v.speechVoice, Language: v.language, Text: "Press 1 to acknowledge."}
+
+		gather := twimlGather{
+			Action:    v.callback(_voiceGatherURL, msg),
+			Method:    http.MethodPost,
+			NumDigits: 1,
+			Timeout:   _gatherTimeout,
+		}
+
+		for i := 0; i < max(msg.Repeat, 1); i++ {
+			gather.Say = append(gather.Say, say, prompt)
+		}
+
+		response.Verbs = append(response.Verbs, gather)
+	} else {
+		say.Loop = max(msg.Repeat, 1)
+		response.Verbs = append(response.Verbs, say)
+	}
+
+	data, err := xml.Marshal(response)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal TwiML: %v", err)
+	}
+
+	return string(data), nil
+}
+
+// callback is the URL of the callback of the call, carrying its ID and key.
+func (v *Voice) callback(path string, msg *VoiceRequestBody) string {
+	query := url.Values{"id": {msg.CallID}, "key": {msg.CallbackKey}}
+
+	return v.callbackURL + _voiceCallbackURLPattern + path + "?" + query.Encode()
+}
+
+// twimlResponse is the TwiML document of a call, see https://www.twilio.com/docs/voice/twiml.
+type twimlResponse struct {
+	XMLName xml.Name `xml:"Response"`
+	Verbs   []any
+}
+
+type twimlSay struct {
+	XMLName  xml.Name `xml:"Say"`
+	Voice    string   `xml:"voice,attr,omitempty"`
+	Language string   `xml:"language,attr,omitempty"`
+	// Loop is how many times the text is read, Twilio reads it forever for zero so it must be omitted instead.
+	Loop int    `xml:"loop,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type twimlGather struct {
+	XMLName xml.Name `xml:"Gather"`
+	// Action is where the pressed keys are reported, without it they are reported to the URL of the document.
+	Action    string     `xml:"action,attr,omitempty"`
+	Method    string     `xml:"method,attr"`
+	NumDigits int        `xml:"numDigits,attr"`
+	Timeout   int        `xml:"timeout,attr"`
+	Say       []twimlSay `xml:"Say"`
+}
+
+type twimlHangup struct {
+	XMLName xml.Name `xml:"Hangup"`
+}
+
+// gatherResponse builds the reply to the key pressed by the callee, the call is ended on acknowledgement and the
+// callee is asked again otherwise.
+func gatherResponse(config *Config, acknowledged bool) ([]byte, error) {
+	voice := config.Twilio.Voice
+
+	response := twimlResponse{}
+
+	if acknowledged {
+		response.Verbs = append(response.Verbs,
+			twimlSay{Voice: voice.SpeechVoice, Language: voice.Language, Text: "Acknowledged. Goodbye."},
+			twimlHangup{},
+		)
+	} else {
+		response.Verbs = append(response.Verbs, twimlGather{
+			Method:    http.MethodPost,
+			NumDigits: 1,
+			Timeout:   _gatherTimeout,
+			Say:       []twimlSay{{Voice: voice.SpeechVoice, Language: voice.Language, Text: "Press 1 to acknowledge."}},
+		})
+	}
+
+	data, err := xml.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal TwiML: %v", err)
+	}
+
+	return data, nil
+}
+
+// newCallbackKey generates the key authenticating the callbacks of a call.
+func newCallbackKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate callback key: %v", err)
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+// VoiceCall is the state of a call placed through the voice channel, updated by the callbacks of Twilio.
+type VoiceCall struct {
+	ID          string `json:"id"`
+	Tenant      string `json:"-"`
+	To          string `json:"to,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// SID is the ID of the call in Twilio, it is known once Twilio reports the first status.
+	SID    string `json:"sid,omitempty"`
+	Status string `json:"status"`
+	// Answered reports whether the call was picked up, Acknowledged whether the callee pressed the key.
+	Answered     bool      `json:"answered"`
+	Acknowledged bool      `json:"acknowledged"`
+	CallbackKey  string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// setStatus records the status reported by Twilio. Statuses may be reported out of order, the call keeps the one
+// it ended with.
+func (c *VoiceCall) setStatus(status string) {
+	if c.finished() {
+		return
+	}
+
+	c.Status = status
+
+	if status == CallStatusInProgress || status == CallStatusCompleted {
+		c.Answered = true
+	}
+}
+
+func (c *VoiceCall) finished() bool {
+	switch c.Status {
+	case CallStatusCompleted, CallStatusBusy, CallStatusNoAnswer, CallStatusFailed, CallStatusCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// CallStore keeps the state of voice calls.
+//
+// Implementations of CallStore must be safe for concurrent use by multiple goroutines.
+type CallStore interface {
+	Save(ctx context.Context, call VoiceCall) error
+	// Call returns the call with the ID, ErrNotFound is returned when there is none.
+	Call(ctx context.Context, id string) (VoiceCall, error)
+	// Update changes the call with the ID by the function, ErrNotFound is returned when there is none.
+	Update(ctx context.Context, id string, update func(*VoiceCall)) error
+}
+
+// MemoryCallStore is a CallStore keeping calls in memory for a day, they are lost on restart.
+type MemoryCallStore struct {
+	mu    sync.Mutex
+	calls map[string]VoiceCall
+}
+
+// NewMemoryCallStore is a constructor function for MemoryCallStore.
+func NewMemoryCallStore() *MemoryCallStore {
+	return &MemoryCallStore{calls: make(map[string]VoiceCall)}
+}
+
+var _ CallStore = (*MemoryCallStore)(nil)
+
+// Save adds the call, calls older than a day are dropped.
+func (s *MemoryCallStore) Save(_ context.Context, call VoiceCall) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.calls {
+		if time.Since(c.CreatedAt) > _callRetention {
+			delete(s.calls, id)
+		}
+	}
+
+	s.calls[call.ID] = call
+
+	return nil
+}
+
+// Call returns the call with the ID.
+func (s *MemoryCallStore) Call(_ context.Context, id string) (VoiceCall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call, ok := s.calls[id]
+	if !ok {
+		return VoiceCall{}, fmt.Errorf("call %w", ErrNotFound)
+	}
+
+	return call, nil
+}
+
+// Update changes the call with the ID by the function.
+func (s *MemoryCallStore) Update(_ context.Context, id string, update func(*VoiceCall)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call, ok := s.calls[id]
+	if !ok {
+		return fmt.Errorf("call %w", ErrNotFound)
+	}
+
+	update(&call)
+	call.UpdatedAt = time.Now().UTC()
+	s.calls[call.ID] = call
+
+	return nil
+}
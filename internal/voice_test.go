@@ -0,0 +1,221 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+type twiml struct {
+	Say    []twimlSay `xml:"Say"`
+	Gather *struct {
+		Action string     `xml:"action,attr"`
+		Say    []twimlSay `xml:"Say"`
+	} `xml:"Gather"`
+	Hangup *struct{} `xml:"Hangup"`
+}
+
+type twimlSay struct {
+	Loop string `xml:"loop,attr"`
+	Text string `xml:",chardata"`
+}
+
+func TestVoiceNotification(t *testing.T) {
+	t.Parallel()
+
+	twilio := &fakeTwilio{}
+
+	server := httptest.NewServer(twilio)
+	defer server.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.Twilio.SID = "AC00000000000000000000000000000000"
+		config.Twilio.Token = "token"
+		config.Twilio.Number = "+15005550006"
+		config.Twilio.APIBaseURL = server.URL
+		config.Twilio.Voice = internal.TwilioVoiceConfig{
+			Enabled:     true,
+			CallbackURL: "https://notifier.example.com/",
+			SpeechVoice: "alice",
+			Language:    "en-US",
+			RingTimeout: 20 * time.Second,
+		}
+		config.Retry.MaxRetries = 1
+	})
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	serve := func(method, target string, body []byte, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+
+		return res
+	}
+
+	call := func(body internal.VoiceRequestBody) (int, string) {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := serve(http.MethodPost, "/api/v1/voice", data, "application/json")
+
+		var response struct {
+			ID string `json:"id"`
+		}
+
+		//nolint: errcheck
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		return res.Code, response.ID
+	}
+
+	// callback posts the form to the callback URL Twilio was given.
+	callback := func(callbackURL string, form url.Values) *httptest.ResponseRecorder {
+		u, err := url.Parse(callbackURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Host != "notifier.example.com" {
+			t.Fatalf("Expected callback to notifier.example.com, got: %s", callbackURL)
+		}
+
+		return serve(http.MethodPost, u.RequestURI(), []byte(form.Encode()), "application/x-www-form-urlencoded")
+	}
+
+	lookup := func(id string) internal.VoiceCall {
+		res := serve(http.MethodGet, "/api/v1/voice/calls/"+id, nil, "")
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", "call lookup", http.StatusOK, res.Code)
+		}
+
+		var c internal.VoiceCall
+		if err := json.Unmarshal(res.Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	status, id := call(internal.VoiceRequestBody{
+		Message: "Database is down", SendToNumber: "+15005550009", Repeat: 2, Acknowledge: true,
+	})
+	if status != http.StatusOK || id == "" {
+		t.Fatalf("%s: Different status codes, expected: %v, got: %v", "call", http.StatusOK, status)
+	}
+
+	params := twilio.lastCall()
+	if params.Get("To") != "+15005550009" || params.Get("From") != config.Twilio.Number || params.Get("Timeout") != "20" {
+		t.Fatalf("Expected call to +15005550009 from %s ringing for 20s, got: %v", config.Twilio.Number, params)
+	}
+
+	if len(params["StatusCallbackEvent"]) != 4 {
+		t.Fatalf("Expected every progress event to be reported, got: %v", params["StatusCallbackEvent"])
+	}
+
+	var doc twiml
+	if err := xml.Unmarshal([]byte(params.Get("Twiml")), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Gather == nil || len(doc.Gather.Say) != 4 || doc.Gather.Say[0].Text != "Database is down" {
+		t.Fatalf("Expected message to be read twice while gathering the key, got: %s", params.Get("Twiml"))
+	}
+
+	statusCallback, gatherCallback := params.Get("StatusCallback"), doc.Gather.Action
+
+	forged := strings.Replace(statusCallback, "key=", "key=0", 1)
+	if res := callback(forged, url.Values{"CallStatus": {"completed"}}); res.Code != http.StatusNotFound {
+		t.Fatalf("%s: Different status codes, expected: %v, got: %v", "forged callback", http.StatusNotFound, res.Code)
+	}
+
+	res := callback(statusCallback, url.Values{"CallSid": {"CA1"}, "CallStatus": {"in-progress"}})
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("%s: Different status codes, expected: %v, got: %v", "status callback", http.StatusNoContent, res.Code)
+	}
+
+	if c := lookup(id); c.Status != "in-progress" || !c.Answered || c.Acknowledged || c.SID != "CA1" {
+		t.Fatalf("Expected call to be answered, got: %+v", c)
+	}
+
+	res = callback(gatherCallback, url.Values{"CallSid": {"CA1"}, "Digits": {"9"}})
+	if !strings.Contains(res.Body.String(), "<Gather") || lookup(id).Acknowledged {
+		t.Fatalf("Expected callee to be asked again on wrong key, got: %s", res.Body.String())
+	}
+
+	res = callback(gatherCallback, url.Values{"CallSid": {"CA1"}, "Digits": {"1"}})
+	if !strings.Contains(res.Body.String(), "<Hangup>") || !lookup(id).Acknowledged {
+		t.Fatalf("Expected call to be acknowledged, got: %s", res.Body.String())
+	}
+
+	callback(statusCallback, url.Values{"CallSid": {"CA1"}, "CallStatus": {"completed"}})
+	callback(statusCallback, url.Values{"CallSid": {"CA1"}, "CallStatus": {"ringing"}})
+
+	if c := lookup(id); c.Status != "completed" {
+		t.Fatalf("Expected call to keep the status it ended with, got: %+v", c)
+	}
+
+	status, id = call(internal.VoiceRequestBody{Message: "Disk is full", SendToNumber: "+15005550009", Repeat: 3})
+	if status != http.StatusOK {
+		t.Fatalf("%s: Different status codes, expected: %v, got: %v", "call", http.StatusOK, status)
+	}
+
+	doc = twiml{}
+	if err := xml.Unmarshal([]byte(twilio.lastCall().Get("Twiml")), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Gather != nil || len(doc.Say) != 1 || doc.Say[0].Loop != "3" {
+		t.Fatalf("Expected message to be read three times, got: %s", twilio.lastCall().Get("Twiml"))
+	}
+
+	callback(twilio.lastCall().Get("StatusCallback"), url.Values{"CallSid": {"CA2"}, "CallStatus": {"no-answer"}})
+
+	if c := lookup(id); c.Status != "no-answer" || c.Answered {
+		t.Fatalf("Expected call not to be answered, got: %+v", c)
+	}
+
+	tests := []struct {
+		name               string
+		body               internal.VoiceRequestBody
+		expectedStatusCode int
+	}{
+		{
+			name:               "call without message should fail",
+			body:               internal.VoiceRequestBody{SendToNumber: "+15005550009"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "call repeating message too often should fail",
+			body:               internal.VoiceRequestBody{Message: "Alert", SendToNumber: "+15005550009", Repeat: 11},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "call rejected by Twilio should fail",
+			body:               internal.VoiceRequestBody{Message: "Alert", SendToNumber: "+15005550001"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		if status, _ := call(tc.body); status != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode, status)
+		}
+	}
+
+	if res := serve(http.MethodGet, "/api/v1/voice/calls/unknown", nil, ""); res.Code != http.StatusNotFound {
+		t.Fatalf("%s: Different status codes, expected: %v, got: %v", "unknown call", http.StatusNotFound, res.Code)
+	}
+}
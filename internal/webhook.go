@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// WebhookIDHeader is the header carrying the ID of the notification, the same for every attempt to send it.
+	WebhookIDHeader = "X-Webhook-ID"
+
+	// WebhookTimestampHeader is the header carrying the Unix time the request was signed at.
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+
+	// WebhookSignatureHeader is the header carrying the signature of the request, see SignWebhook.
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var (
+	// ErrWebhookURLNotAllowed is returned when a notification names a URL on a host which is not allowed.
+	ErrWebhookURLNotAllowed = errors.New("webhook URL is not allowed")
+
+	// ErrMissingWebhookURL is returned when a notification names no URL and none is configured.
+	ErrMissingWebhookURL = errors.New("webhook URL is missing")
+)
+
+// webhookHeaderNamePattern matches valid header names.
+var webhookHeaderNamePattern = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+\-.^_|~]+$`)
+
+// _reservedWebhookHeaders are set by the service and can't be overridden by custom headers.
+var _reservedWebhookHeaders = []string{
+	WebhookIDHeader, WebhookTimestampHeader, WebhookSignatureHeader, CorrelationIDHeader,
+	"Content-Type", "Content-Length", "Host", "Traceparent", "Tracestate",
+}
+
+// WebhookEnvelope is the payload sent to webhooks, encoded as JSON or rendered by the body template.
+type WebhookEnvelope struct {
+	ID        string         `json:"id"`
+	Event     string         `json:"event,omitempty"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+	Tenant    string         `json:"tenant,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// Webhook holds configuration for sending notifications to arbitrary HTTP services.
+type Webhook struct {
+	url          string
+	secret       string
+	headers      http.Header
+	template     *template.Template
+	contentType  string
+	allowedHosts []string
+	client       *http.Client
+	throttle     *Throttle
+}
+
+func newWebhook(config WebhookConfig) *Webhook {
+	// The configuration is validated, headers and template are known to parse.
+	headers, _ := parseHeaders(config.Headers)
+	tmpl, _ := parseBodyTemplate(config.BodyTemplate)
+
+	return &Webhook{
+		url:          config.URL,
+		secret:       config.SigningSecret,
+		headers:      headers,
+		template:     tmpl,
+		contentType:  config.ContentType,
+		allowedHosts: parseHosts(config.AllowedHosts),
+		// Redirects could lead to hosts which are not allowed, they are reported as failures instead.
+		client: newProviderClient(),
+	}
+}
+
+// parseHosts parses comma separated hosts, lower-cased so that they compare with hosts of URLs ignoring case.
+func parseHosts(s string) []string {
+	var hosts []string
+
+	for _, host := range strings.Split(s, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, strings.ToLower(host))
+		}
+	}
+
+	return hosts
+}
+
+// SignWebhook computes the signature of the request body sent at the timestamp, as sent in WebhookSignatureHeader:
+// sha256= followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body. Receivers should recompute it
+// and reject requests with a different signature or an old timestamp.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	//nolint: errcheck
+	mac.Write([]byte(timestamp + "."))
+
+	//nolint: errcheck
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NotifyWebhook sends the notification to the URL of the notification, its destination or the configured one.
+// Any 2xx response means it was delivered. Redirects and client errors, except for timeouts and rate limiting, are
+// not retried.
+func (s *Service) NotifyWebhook(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_webhookChannel, _webhookProvider, err) }()
+
+	webhook := s.providers.Load().webhook
+	if webhook == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _webhookChannel)
+	}
+
+	body := msg.(*WebhookRequestBody)
+
+	target, err := webhook.target(ctx, body.URL)
+	if err != nil {
+		return err
+	}
+
+	envelope := WebhookEnvelope{
+		ID:        body.ID,
+		Event:     body.Event,
+		Message:   body.Message,
+		Data:      body.Data,
+		Tenant:    TenantIDFromContext(ctx),
+		Timestamp: time.Now().UTC(),
+	}
+
+	payload, err := webhook.render(envelope)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	release, err := webhook.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule webhook notification: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return redactError(fmt.Errorf("failed to create HTTP request: %w", err), target)
+	}
+
+	for name, values := range webhook.headers {
+		req.Header[name] = values
+	}
+
+	for name, value := range body.Headers {
+		req.Header.Set(name, value)
+	}
+
+	timestamp := strconv.FormatInt(envelope.Timestamp.Unix(), 10)
+
+	req.Header.Set("Content-Type", webhook.contentType)
+	req.Header.Set(WebhookIDHeader, envelope.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.secret, timestamp, payload))
+
+	err = sendProviderRequest(ctx, webhook.client, req, _webhookChannel, _webhookProvider,
+		func(resp *http.Response, _ []byte) error {
+			return responseStatusError(resp)
+		},
+	)
+	// Only failures to reach the receiver are wrapped, errors of the response are reported as they are.
+	var transportErr *url.Error
+	if errors.As(err, &transportErr) {
+		return redactError(fmt.Errorf("failed to send webhook notification: %w", err), target)
+	}
+
+	if err != nil {
+		return redactError(err, target)
+	}
+
+	LoggerFromContext(ctx).Debug("webhook notification sent", "id", envelope.ID)
+
+	return nil
+}
+
+// target resolves the URL the notification is sent to: the URL of its destination, its own URL when the host is
+// allowed or the configured one.
+func (w *Webhook) target(ctx context.Context, requested string) (string, error) {
+	if u := destinationURLFromContext(ctx); u != "" {
+		return u, nil
+	}
+
+	if requested == "" {
+		if w.url == "" {
+			return "", Permanent(ErrMissingWebhookURL)
+		}
+
+		return w.url, nil
+	}
+
+	u, err := url.Parse(requested)
+	if err != nil || !slices.Contains(w.allowedHosts, strings.ToLower(u.Hostname())) {
+		return "", Permanent(ErrWebhookURLNotAllowed)
+	}
+
+	return requested, nil
+}
+
+// render encodes the envelope as JSON or renders it with the body template.
+func (w *Webhook) render(envelope WebhookEnvelope) ([]byte, error) {
+	if w.template == nil {
+		payload, err := json.Marshal(envelope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal webhook envelope: %v", err)
+		}
+
+		return payload, nil
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, envelope); err != nil {
+		return nil, fmt.Errorf("failed to render webhook body: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseHeaders parses comma separated Name=value pairs.
+func parseHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("header %q is not a Name=value pair", pair)
+		}
+
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if err := checkWebhookHeader(name, value); err != nil {
+			return nil, err
+		}
+
+		headers.Add(name, value)
+	}
+
+	return headers, nil
+}
+
+// checkWebhookHeaders verifies the custom headers of a notification.
+func checkWebhookHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if err := checkWebhookHeader(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkWebhookHeader(name, value string) error {
+	if !webhookHeaderNamePattern.MatchString(name) || strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("invalid header %q", name)
+	}
+
+	for _, reserved := range _reservedWebhookHeaders {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("header %q can't be overridden", name)
+		}
+	}
+
+	return nil
+}
+
+// parseBodyTemplate parses the body template, the json function encodes values as JSON, e.g. {{ json .Message }}.
+func parseBodyTemplate(s string) (*template.Template, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+
+			return string(data), err
+		},
+	}).Option("missingkey=error").Parse(s)
+}
+
+// checkWebhook verifies the configured URL is reachable.
+func (s *Service) checkWebhook(ctx context.Context) error {
+	webhook := s.providers.Load().webhook
+
+	return probeURL(ctx, webhook.client, http.MethodHead, webhook.url)
+}
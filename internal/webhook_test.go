@@ -0,0 +1,267 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// webhookReceiver records the requests of webhook notifications, answering with the statuses in order.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+
+	if len(rec.statuses) > 0 {
+		w.WriteHeader(rec.statuses[0])
+		rec.statuses = rec.statuses[1:]
+	}
+}
+
+func newWebhookConfig(t *testing.T, webhookURL string) *internal.Config {
+	t.Helper()
+
+	return newTestConfig(t, func(config *internal.Config) {
+		config.Webhook = internal.WebhookConfig{
+			Enabled:       true,
+			URL:           webhookURL,
+			SigningSecret: "signing-secret",
+			Headers:       "X-Environment=test",
+			ContentType:   "application/json",
+		}
+	})
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		name             string
+		statuses         []int
+		bodyTemplate     string
+		allowedHosts     string
+		requestURL       func(base string) string
+		expectedError    error
+		expectedRequests int
+		expectedBody     string
+	}
+
+	tests := []test{
+		{
+			name:             "envelope should be sent signed and treated as sent on 2xx",
+			statuses:         []int{http.StatusAccepted},
+			expectedRequests: 1,
+		},
+		{
+			name:             "body should be rendered by the template",
+			bodyTemplate:     `{"text": {{ json .Message }}, "kind": "{{ .Event }}"}`,
+			expectedRequests: 1,
+			expectedBody:     `{"text": "Hello \"webhook\"", "kind": "deploy"}`,
+		},
+		{
+			name:             "server errors should be retried",
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			expectedRequests: 2,
+		},
+		{
+			name:             "client errors should not be retried",
+			statuses:         []int{http.StatusNotFound},
+			expectedError:    errors.New("unexpected response status: 404 Not Found"),
+			expectedRequests: 1,
+		},
+		{
+			name:             "URL of the notification on an allowed host should be sent to",
+			allowedHosts:     "127.0.0.1",
+			requestURL:       func(base string) string { return base + "/own" },
+			expectedRequests: 1,
+		},
+		{
+			name:          "URL of the notification on another host should be rejected",
+			requestURL:    func(base string) string { return base + "/own" },
+			expectedError: internal.ErrWebhookURLNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := &webhookReceiver{statuses: tc.statuses}
+
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			config := newWebhookConfig(t, server.URL)
+			config.Retry.Delay = 0
+			config.Webhook.BodyTemplate = tc.bodyTemplate
+			config.Webhook.AllowedHosts = tc.allowedHosts
+
+			body := &internal.WebhookRequestBody{
+				ID:      "notification-id",
+				Message: `Hello "webhook"`,
+				Event:   "deploy",
+				Data:    map[string]any{"version": "1.2.3"},
+				Headers: map[string]string{"X-Team": "ops"},
+			}
+
+			if tc.requestURL != nil {
+				body.URL = tc.requestURL(server.URL)
+			}
+
+			s := internal.NewService(config)
+
+			err := internal.Retry(s.NotifyWebhook, config.Retry.MaxRetries, config.Retry.Delay)(context.Background(), body)
+			if tc.expectedError != nil {
+				if err == nil || (!errors.Is(err, tc.expectedError) && err.Error() != tc.expectedError.Error()) {
+					t.Fatalf("Expected error %v, got: %v", tc.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected error to be nil but got: %v", err)
+			}
+
+			if len(receiver.requests) != tc.expectedRequests {
+				t.Fatalf("Expected %d requests, got: %d", tc.expectedRequests, len(receiver.requests))
+			}
+
+			for i, r := range receiver.requests {
+				timestamp := r.Header.Get(internal.WebhookTimestampHeader)
+
+				signature := internal.SignWebhook("signing-secret", timestamp, receiver.bodies[i])
+				if r.Header.Get(internal.WebhookSignatureHeader) != signature {
+					t.Fatalf("Expected signature %s, got: %s", signature, r.Header.Get(internal.WebhookSignatureHeader))
+				}
+
+				if r.Header.Get(internal.WebhookIDHeader) != "notification-id" ||
+					r.Header.Get("X-Environment") != "test" || r.Header.Get("X-Team") != "ops" {
+					t.Fatalf("Expected ID and custom headers to be sent, got: %v", r.Header)
+				}
+			}
+
+			if tc.expectedBody != "" && string(receiver.bodies[0]) != tc.expectedBody {
+				t.Fatalf("Expected body %s, got: %s", tc.expectedBody, receiver.bodies[0])
+			}
+
+			if tc.expectedBody == "" && tc.expectedRequests > 0 {
+				var envelope internal.WebhookEnvelope
+				if err := json.Unmarshal(receiver.bodies[0], &envelope); err != nil {
+					t.Fatal(err)
+				}
+
+				if envelope.ID != "notification-id" || envelope.Message != body.Message ||
+					envelope.Data["version"] != "1.2.3" || envelope.Timestamp.IsZero() {
+					t.Fatalf("Expected envelope of the notification, got: %+v", envelope)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookEndpoint(t *testing.T) {
+	t.Parallel()
+
+	receiver := &webhookReceiver{}
+
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	config := newWebhookConfig(t, server.URL)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Webhook.AllowedHosts = "hooks.example.com, " + u.Hostname()
+
+	mux := internal.NewMux(config, logger, internal.NewService(config))
+
+	type test struct {
+		name               string
+		requestBody        any
+		expectedStatusCode int
+		expectedResponse   string
+	}
+
+	tests := []test{
+		{
+			name:               "notification should be sent to the configured URL",
+			requestBody:        &internal.WebhookRequestBody{ID: "abc", Message: "Hello"},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `"id": "abc"`,
+		},
+		{
+			name:               "notification should be sent to its own URL on an allowed host",
+			requestBody:        &internal.WebhookRequestBody{Message: "Hello", URL: server.URL + "/own"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "notification without message should be rejected",
+			requestBody:        &internal.WebhookRequestBody{Event: "deploy"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "notification with invalid URL should be rejected",
+			requestBody:        &internal.WebhookRequestBody{Message: "Hello", URL: "not a URL"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "notification to a host which is not allowed should be rejected",
+			requestBody:        &internal.WebhookRequestBody{Message: "Hello", URL: "http://internal.example.com/hook"},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   internal.ErrWebhookURLNotAllowed.Error(),
+		},
+		{
+			name: "notification overriding the signature should be rejected",
+			requestBody: &internal.WebhookRequestBody{
+				Message: "Hello", Headers: map[string]string{internal.WebhookSignatureHeader: "sha256=forged"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/v1/webhook", bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		if !strings.Contains(res.Body.String(), tc.expectedResponse) {
+			t.Fatalf("%s: Expected response containing %s, got: %s", tc.name, tc.expectedResponse, res.Body.String())
+		}
+	}
+
+	if len(receiver.requests) != 2 || receiver.requests[1].URL.Path != "/own" {
+		t.Fatalf("Expected notifications to be sent to the configured and own URL, got: %d", len(receiver.requests))
+	}
+}
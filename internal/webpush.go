@@ -0,0 +1,379 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// _webPushRecordSize is the record size of encrypted payloads, push services accept payloads of up to 4096 bytes
+	// so that a single record holds the whole payload.
+	_webPushRecordSize = 4096
+	// _webPushHeaderSize is the size of the header of encrypted payloads: salt, record size, key ID length and key ID.
+	_webPushHeaderSize = 16 + 4 + 1 + 65
+	// _webPushDefaultTTL is how long push services keep notifications sent without TTL, the longest they support.
+	_webPushDefaultTTL = 2419200
+	// _vapidTokenLifetime is how long VAPID tokens are valid, push services reject tokens valid for over 24 hours.
+	_vapidTokenLifetime = 12 * time.Hour
+)
+
+var (
+	// ErrNoSubscriptions is returned when sending web push notification to a user without subscriptions.
+	ErrNoSubscriptions = errors.New("user has no web push subscriptions")
+	// ErrPushServiceNotAllowed is returned when the endpoint of a subscription is not an HTTPS URL on an allowed
+	// push service.
+	ErrPushServiceNotAllowed = errors.New("push service is not allowed")
+)
+
+// decodeBase64URL decodes URL safe base64 with or without padding, browsers and libraries use both.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// GenerateVAPIDKeys generates a P-256 key pair for VAPID, encoded as URL safe base64 like the keys of the web push
+// libraries. The public key is the application server key passed to PushManager.subscribe in the browser.
+func GenerateVAPIDKeys() (privateKey, publicKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate VAPID key: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// parseVAPIDKey parses the VAPID private key, the 32 bytes of a P-256 private key encoded as URL safe base64. The
+// public key is returned encoded the same way.
+func parseVAPIDKey(s string) (*ecdsa.PrivateKey, string, error) {
+	d, err := decodeBase64URL(s)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid VAPID key encoding: %v", err)
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid VAPID key: %v", err)
+	}
+
+	// The uncompressed point is 0x04 followed by the 32 bytes of x and y.
+	public := key.PublicKey().Bytes()
+
+	signer := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+
+	return signer, base64.RawURLEncoding.EncodeToString(public), nil
+}
+
+// parseP256DH decodes the public key of the browser, an uncompressed P-256 point encoded as URL safe base64.
+func parseP256DH(s string) (*ecdh.PublicKey, error) {
+	p256dh, err := decodeBase64URL(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key encoding: %v", err)
+	}
+
+	public, err := ecdh.P256().NewPublicKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+
+	return public, nil
+}
+
+// parseAuthSecret decodes the authentication secret of the subscription, 16 bytes encoded as URL safe base64.
+func parseAuthSecret(s string) ([]byte, error) {
+	auth, err := decodeBase64URL(s)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("auth secret must be 16 bytes encoded as URL safe base64")
+	}
+
+	return auth, nil
+}
+
+// WebPush holds configuration for sending notifications to browsers through their push services, authenticated with
+// VAPID.
+type WebPush struct {
+	subject   string
+	key       *ecdsa.PrivateKey
+	publicKey string
+	// allowedHosts are the hosts of push services, endpoints may be on them or on their subdomains.
+	allowedHosts []string
+	client       *http.Client
+	throttle     *Throttle
+	// tokens caches the VAPID tokens of every push service by its origin.
+	tokens sync.Map
+}
+
+func newWebPush(config WebPushConfig) *WebPush {
+	// The configuration is validated, the key is known to parse.
+	key, publicKey, _ := parseVAPIDKey(config.VAPIDPrivateKey)
+
+	return &WebPush{
+		subject:      config.Subject,
+		key:          key,
+		publicKey:    publicKey,
+		allowedHosts: parseHosts(config.AllowedHosts),
+		// Endpoints are chosen by clients, redirects could lead anywhere, they are reported as failures instead.
+		client: newProviderClient(),
+	}
+}
+
+// allowed tells whether notifications may be pushed to the endpoint: over HTTPS to an allowed push service, so that
+// subscriptions can't reach internal services.
+func (p *WebPush) allowed(endpoint *url.URL) bool {
+	return pushServiceAllowed(p.allowedHosts, endpoint)
+}
+
+// pushServiceAllowed tells whether the endpoint is an HTTPS URL on one of the hosts or on their subdomains.
+func pushServiceAllowed(hosts []string, endpoint *url.URL) bool {
+	if endpoint.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(endpoint.Hostname())
+
+	for _, allowed := range hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// webPushMessage is the payload delivered to the service worker of the app, which shows the notification.
+type webPushMessage struct {
+	Title string            `json:"title,omitempty"`
+	Body  string            `json:"body,omitempty"`
+	URL   string            `json:"url,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// NotifyWebPush sends web push notification to the subscription of the notification.
+func (s *Service) NotifyWebPush(ctx context.Context, msg any) (err error) {
+	defer func() { observeNotification(_webPushChannel, _webPushProvider, err) }()
+
+	webPush := s.providers.Load().webPush
+	if webPush == nil {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, _webPushChannel)
+	}
+
+	body := msg.(*WebPushRequestBody)
+	if body.Subscription == nil {
+		return Permanent(ErrNoSubscriptions)
+	}
+
+	sub := body.Subscription
+
+	plaintext, err := json.Marshal(webPushMessage{Title: body.Title, Body: body.Body, URL: body.URL, Data: body.Data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal web push message: %v", err)
+	}
+
+	payload, err := encryptWebPush(sub.Keys, plaintext)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encrypt web push message: %w", err))
+	}
+
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid subscription endpoint: %v", err))
+	}
+
+	if !webPush.allowed(endpoint) {
+		return Permanent(fmt.Errorf("%w: %s", ErrPushServiceNotAllowed, endpoint.Host))
+	}
+
+	release, err := webPush.throttle.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to schedule web push notification: %w", err)
+	}
+	defer release()
+
+	// The audience of the token is the origin of the push service.
+	audience := endpoint.Scheme + "://" + endpoint.Host
+
+	token, err := webPush.token(audience).get(ctx, func(context.Context) (string, time.Time, error) {
+		return webPush.issueToken(audience)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to authorize web push notification: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	ttl := _webPushDefaultTTL
+	if body.TTL != nil {
+		ttl = *body.TTL
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, webPush.publicKey))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(ttl))
+
+	if body.Urgency != "" {
+		req.Header.Set("Urgency", body.Urgency)
+	}
+
+	if body.Topic != "" {
+		req.Header.Set("Topic", body.Topic)
+	}
+
+	// Endpoints of subscriptions are capability URLs, they are kept out of errors.
+	err = sendProviderRequest(ctx, webPush.client, req, _webPushChannel, _webPushProvider, checkWebPushResponse)
+	if err != nil {
+		return redactError(fmt.Errorf("failed to send web push notification: %w", err), sub.Endpoint)
+	}
+
+	LoggerFromContext(ctx).Debug("web push notification sent", "push_service", endpoint.Host)
+
+	return nil
+}
+
+// checkWebPushResponse checks the response of the push service. Expired and unsubscribed subscriptions are reported
+// as ErrUnregisteredToken, throttled notifications are retried after the Retry-After header.
+func checkWebPushResponse(resp *http.Response, data []byte) error {
+	err := responseStatusError(resp)
+	if err == nil {
+		return nil
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return Permanent(fmt.Errorf("%w: subscription expired or unsubscribed", ErrUnregisteredToken))
+	}
+
+	if msg := strings.TrimSpace(string(data)); msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+
+	if after, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && after > 0 {
+		return RetryAfter(err, time.Duration(after)*time.Second)
+	}
+
+	return err
+}
+
+// token returns the cached VAPID token of the push service.
+func (w *WebPush) token(audience string) *cachedToken {
+	token, _ := w.tokens.LoadOrStore(audience, &cachedToken{})
+
+	return token.(*cachedToken)
+}
+
+// issueToken signs a VAPID token for the push service, it is reused until an hour before it expires.
+func (w *WebPush) issueToken(audience string) (string, time.Time, error) {
+	expires := time.Now().Add(_vapidTokenLifetime)
+
+	token, err := signJWT(
+		map[string]string{"typ": "JWT", "alg": "ES256"},
+		map[string]any{"aud": audience, "exp": expires.Unix(), "sub": w.subject},
+		w.key,
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expires.Add(-time.Hour), nil
+}
+
+// encryptWebPush encrypts the plaintext for the subscription as a single aes128gcm record, as specified by RFC 8291.
+func encryptWebPush(keys PushSubscriptionKeys, plaintext []byte) ([]byte, error) {
+	if len(plaintext) > _webPushRecordSize-_webPushHeaderSize-17 {
+		return nil, fmt.Errorf("message of %d bytes exceeds the size of web push payloads", len(plaintext))
+	}
+
+	uaPublic, err := parseP256DH(keys.P256DH)
+	if err != nil {
+		return nil, err
+	}
+
+	authSecret, err := parseAuthSecret(keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every message is encrypted with a new key pair of the application server and salt.
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %v", err)
+	}
+
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic.Bytes()...), asPublic...)
+	ikm := hkdf(authSecret, ecdhSecret, keyInfo, 32)
+
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	header := make([]byte, 0, _webPushHeaderSize)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, _webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// The padding delimiter 0x02 marks the last and only record.
+	return aead.Seal(header, nonce, append(plaintext, 0x02), nil), nil
+}
+
+// hkdf derives a key of up to 32 bytes from the input keying material with HKDF-SHA-256, as specified by RFC 5869.
+func hkdf(salt, ikm, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+
+	return expand.Sum(nil)[:length]
+}
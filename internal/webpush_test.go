@@ -0,0 +1,470 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kkereziev/notifier/internal"
+)
+
+// browser is the user agent side of a web push subscription, able to decrypt the payloads sent to it.
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	t.Helper()
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, auth); err != nil {
+		t.Fatal(err)
+	}
+
+	return browser{key: key, auth: auth}
+}
+
+func (b browser) subscription(endpoint string) internal.PushSubscription {
+	return internal.PushSubscription{
+		Endpoint: endpoint,
+		Keys: internal.PushSubscriptionKeys{
+			P256DH: base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(b.auth),
+		},
+	}
+}
+
+func hmacSHA256(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// decrypt decrypts the aes128gcm payload as specified by RFC 8291.
+func (b browser) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 21 || len(payload) < 21+int(payload[20]) {
+		return nil, errors.New("payload too short")
+	}
+
+	salt, idLen := payload[:16], int(payload[20])
+	asPublicBytes, ciphertext := payload[21:21+idLen], payload[21+idLen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := b.key.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...), asPublicBytes...)
+	ikm := hmacSHA256(hmacSHA256(b.auth, secret), keyInfo, []byte{1})[:32]
+	prk := hmacSHA256(salt, ikm)
+	cek := hmacSHA256(prk, []byte("Content-Encoding: aes128gcm\x00"), []byte{1})[:16]
+	nonce := hmacSHA256(prk, []byte("Content-Encoding: nonce\x00"), []byte{1})[:12]
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing padding delimiter of the last record")
+	}
+
+	return plaintext[:len(plaintext)-1], nil
+}
+
+// verifyVAPID verifies the VAPID token of the request was signed with the key in the header for the audience.
+func verifyVAPID(r *http.Request, publicKey, audience string) error {
+	var token, key string
+
+	for _, param := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "vapid "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+
+	if key != publicKey {
+		return fmt.Errorf("unexpected key %q", key)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	point, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(point) != 65 {
+		return errors.New("malformed key")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return errors.New("malformed signature")
+	}
+
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:]),
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return errors.New("invalid signature")
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+
+	var c struct {
+		Aud string `json:"aud"`
+		Sub string `json:"sub"`
+		Exp int64  `json:"exp"`
+	}
+
+	if err := json.Unmarshal(claims, &c); err != nil {
+		return err
+	}
+
+	if c.Aud != audience || c.Sub != "mailto:ops@example.com" || c.Exp > time.Now().Add(24*time.Hour).Unix() {
+		return fmt.Errorf("unexpected claims %+v", c)
+	}
+
+	return nil
+}
+
+func TestWebPushNotification(t *testing.T) {
+	t.Parallel()
+
+	privateKey, publicKey, err := internal.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := newBrowser(t)
+
+	var (
+		mu       sync.Mutex
+		messages []map[string]any
+	)
+
+	pushService := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path == "/push/gone" {
+			w.WriteHeader(http.StatusGone)
+
+			return
+		}
+
+		if err := verifyVAPID(r, publicKey, "https://"+r.Host); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error())) //nolint: errcheck
+
+			return
+		}
+
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		plaintext, err := alice.decrypt(payload)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error())) //nolint: errcheck
+
+			return
+		}
+
+		var msg map[string]any
+		if err := json.Unmarshal(plaintext, &msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		messages = append(messages, msg)
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+
+	config := newTestConfig(t, func(config *internal.Config) {
+		config.WebPush = internal.WebPushConfig{
+			Enabled: true, VAPIDPrivateKey: privateKey, Subject: "mailto:ops@example.com", AllowedHosts: "127.0.0.1",
+		}
+	})
+
+	subscriptions := internal.NewMemorySubscriptionStore()
+	s := internal.NewService(config)
+	s.SetWebPushClient(pushService.Client())
+
+	mux := internal.NewMux(config, logger, s, internal.WithSubscriptionStore(subscriptions))
+
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/webpush/vapid-key", nil))
+
+	if !strings.Contains(res.Body.String(), publicKey) {
+		t.Fatalf("Expected VAPID public key %s, got: %s", publicKey, res.Body.String())
+	}
+
+	type test struct {
+		name               string
+		method             string
+		url                string
+		requestBody        any
+		expectedStatusCode int
+		expectedMessages   int
+		expectedSubs       int
+	}
+
+	invalid := alice.subscription(pushService.URL + "/push/invalid")
+	invalid.Keys.Auth = "c2hvcnQ"
+
+	subscribe := func(endpoint string) internal.SubscriptionRequestBody {
+		return internal.SubscriptionRequestBody{User: "alice", Subscription: alice.subscription(endpoint)}
+	}
+
+	notAllowed := alice.subscription("https://metadata.internal/push/alice")
+
+	unsubscribe := internal.SubscriptionRequestBody{
+		User: "alice", Subscription: internal.PushSubscription{Endpoint: pushService.URL + "/push/alice"},
+	}
+
+	tests := []test{
+		{
+			name:               "subscription should be saved",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        subscribe(pushService.URL + "/push/alice"),
+			expectedStatusCode: http.StatusCreated,
+			expectedSubs:       1,
+		},
+		{
+			name:               "expired subscription should be saved",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        subscribe(pushService.URL + "/push/gone"),
+			expectedStatusCode: http.StatusCreated,
+			expectedSubs:       2,
+		},
+		{
+			name:               "subscription with invalid keys should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        internal.SubscriptionRequestBody{User: "alice", Subscription: invalid},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedSubs:       2,
+		},
+		{
+			name:               "subscription over HTTP should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        subscribe(strings.Replace(pushService.URL, "https", "http", 1) + "/push/alice"),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedSubs:       2,
+		},
+		{
+			name:               "subscription on push service which is not allowed should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        subscribe("https://metadata.internal/push/alice"),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedSubs:       2,
+		},
+		{
+			name:   "notification should be sent and expired subscription removed",
+			method: http.MethodPost,
+			url:    "/api/v1/webpush",
+			requestBody: internal.WebPushRequestBody{
+				User: "alice", Title: "Hello", Body: "World", Urgency: "high", Topic: "greeting",
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   1,
+			expectedSubs:       1,
+		},
+		{
+			name:               "notification should be sent to the remaining subscription",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush",
+			requestBody:        internal.WebPushRequestBody{User: "alice", Data: map[string]string{"id": "1"}},
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   2,
+			expectedSubs:       1,
+		},
+		{
+			name:               "notification to push service which is not allowed should be rejected",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush",
+			requestBody:        internal.WebPushRequestBody{Title: "Hello", Subscription: &notAllowed},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessages:   2,
+			expectedSubs:       1,
+		},
+		{
+			name:               "notification to user without subscriptions should not be found",
+			method:             http.MethodPost,
+			url:                "/api/v1/webpush",
+			requestBody:        internal.WebPushRequestBody{User: "bob", Title: "Hello"},
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessages:   2,
+			expectedSubs:       1,
+		},
+		{
+			name:               "subscription should be deleted",
+			method:             http.MethodDelete,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        unsubscribe,
+			expectedStatusCode: http.StatusOK,
+			expectedMessages:   2,
+		},
+		{
+			name:               "unknown subscription should not be found",
+			method:             http.MethodDelete,
+			url:                "/api/v1/webpush/subscriptions",
+			requestBody:        unsubscribe,
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessages:   2,
+		},
+	}
+
+	for _, tc := range tests {
+		payload, err := json.Marshal(tc.requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request: %v", err)
+		}
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(tc.method, tc.url, bytes.NewBuffer(payload)))
+
+		if res.Result().StatusCode != tc.expectedStatusCode {
+			t.Fatalf("%s: Different status codes, expected: %v, got: %v", tc.name, tc.expectedStatusCode,
+				res.Result().StatusCode)
+		}
+
+		mu.Lock()
+		sent := len(messages)
+		mu.Unlock()
+
+		subs, err := subscriptions.List(context.Background(), "", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sent != tc.expectedMessages || len(subs) != tc.expectedSubs {
+			t.Fatalf("%s: Expected %d messages and %d subscriptions, got: %d messages and %d subscriptions", tc.name,
+				tc.expectedMessages, tc.expectedSubs, sent, len(subs))
+		}
+	}
+
+	if messages[0]["title"] != "Hello" || messages[0]["body"] != "World" {
+		t.Fatalf("Expected decrypted message, got: %v", messages[0])
+	}
+}
+
+func TestFileSubscriptionStore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store, err := internal.NewFileSubscriptionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := newBrowser(t).subscription("https://push.example.com/1")
+
+	if err := store.Save(ctx, "acme", "alice", sub); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving a subscription again replaces it.
+	if err := store.Save(ctx, "acme", "alice", sub); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := internal.NewFileSubscriptionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := reopened.List(ctx, "acme", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(subs) != 1 || subs[0].Endpoint != sub.Endpoint || subs[0].Keys != sub.Keys {
+		t.Fatalf("Expected the saved subscription, got: %+v", subs)
+	}
+
+	if subs, _ := reopened.List(ctx, "", "alice"); len(subs) != 0 {
+		t.Fatalf("Expected subscriptions of other tenants to be kept apart, got: %+v", subs)
+	}
+
+	if err := reopened.Delete(ctx, "acme", "alice", sub.Endpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reopened.Delete(ctx, "acme", "alice", sub.Endpoint); !errors.Is(err, internal.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got: %v", err)
+	}
+}
@@ -2,70 +2,320 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/kkereziev/notifier/internal"
 )
 
-const _serviceName = "notifier "
+const _serviceName = "notifier"
 
 func main() {
-	log := log.New(os.Stdout, _serviceName, log.LstdFlags)
+	args := os.Args[1:]
 
-	if err := run(log); err != nil {
-		log.Fatalf("failed startup: %v", err)
+	if len(args) >= 2 && args[0] == "config" && args[1] == "check" {
+		if err := checkConfig(args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if len(args) >= 2 && args[0] == "webpush" && args[1] == "keys" {
+		if err := generateVAPIDKeys(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate VAPID keys: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if err := run(args); err != nil {
+		slog.Error("failed startup", "error", err)
+		os.Exit(1)
+	}
+}
+
+// configLoader parses the command line and returns the loader of the configuration, reading the file given by
+// -config or CONFIG_FILE, the environment and the command line flags. The path of the file is returned as well.
+func configLoader(name string, args []string) (internal.ConfigLoader, string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or TOML configuration file")
+	overrides := internal.ConfigFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	if fs.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	load := func() (*internal.Config, error) {
+		return internal.NewConfig(internal.WithConfigFile(*file), internal.WithConfigOverrides(overrides))
 	}
+
+	return load, *file, nil
 }
 
-func run(log *log.Logger) error {
-	cfg, err := internal.NewConfig()
+// checkConfig validates the configuration and prints the effective one, with secrets redacted.
+func checkConfig(args []string) error {
+	load, _, err := configLoader(_serviceName+" config check", args)
 	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
+		return err
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+
+	return cfg.WriteRedacted(os.Stdout)
+}
+
+// generateVAPIDKeys prints a new VAPID key pair for the Web Push channel.
+func generateVAPIDKeys() error {
+	privateKey, publicKey, err := internal.GenerateVAPIDKeys()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("WEB_PUSH_VAPID_PRIVATE_KEY=%s\n# Public key browsers subscribe with: %s\n", privateKey, publicKey)
+
+	return nil
+}
+
+func run(args []string) error {
+	load, configFile, err := configLoader(_serviceName, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
 		return fmt.Errorf("config initialization: %v", err)
 	}
 
+	cfg, err := load()
+	if err != nil {
+		return fmt.Errorf("config initialization: %v", err)
+	}
+
+	log, err := internal.NewLogger(cfg.Log, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("logger initialization: %v", err)
+	}
+
+	// Secrets are masked in every log record, whatever their origin, e.g. errors quoting the Slack webhook URL.
+	redactor := internal.NewRedactor(cfg.SecretValues()...)
+
+	log = slog.New(internal.NewRedactingHandler(log.Handler(), redactor)).With("service", _serviceName)
+	slog.SetDefault(log)
+
+	shutdownTracing, err := internal.NewTracerProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("tracing initialization: %v", err)
+	}
+
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error("tracing shutdown", "error", err)
+		}
+	}()
+
+	// Lifecycle context is the base of every request context, canceling it interrupts in-flight notifications.
+	lifecycle, stop := context.WithCancelCause(internal.WithLogger(context.Background(), log))
+	defer stop(internal.ErrShuttingDown)
+
 	s := internal.NewService(cfg)
 
+	// Applies reloaded configuration to the service, its secrets are masked from then on.
+	applyConfig := func(cfg *internal.Config) {
+		redactor.SetSecrets(cfg.SecretValues()...)
+		s.Reload(cfg)
+	}
+
+	tenants := internal.NewTenants()
+
+	// Stores the service can't serve requests without are checked by the readiness probe.
+	var readiness []internal.HealthCheck
+
+	// Credentials managed through the admin API override the configured ones of the deployment and of tenants, they
+	// are applied whenever they change.
+	var admin *internal.Admin
+
+	if cfg.Admin.DBPath != "" {
+		store, err := internal.NewAdminStore(cfg.Admin)
+		if err != nil {
+			return fmt.Errorf("admin store initialization: %v", err)
+		}
+
+		//nolint: errcheck
+		defer store.Close()
+
+		readiness = append(readiness, internal.HealthCheck{Name: "admin_store", Check: store.Check})
+
+		admin = internal.NewAdmin(store)
+		admin.WithTenants(tenants)
+		admin.OnSecrets(func(secrets []string) { redactor.SetSecrets(secrets...) })
+		admin.OnApply(s.Reload)
+
+		if err := admin.Reload(lifecycle, cfg); err != nil {
+			return fmt.Errorf("admin initialization: %v", err)
+		}
+
+		applyConfig = func(cfg *internal.Config) {
+			if err := admin.Reload(lifecycle, cfg); err != nil {
+				log.Error("applying stored credentials failed, keeping current configuration", "error", err)
+			}
+		}
+	}
+
+	if err := tenants.Reload(lifecycle, cfg); err != nil {
+		return fmt.Errorf("tenants initialization: %v", err)
+	}
+
+	health := internal.NewHealth(cfg.Health)
+	health.AddProviderCheck(s.HealthChecks()...)
+	health.AddReadinessCheck(readiness...)
+
+	// Rate limits are shared by the multiplexers built on reload, so that reloading doesn't reset them.
+	muxOptions := []internal.MuxOption{
+		internal.WithHealth(health),
+		internal.WithRateLimiter(internal.NewMemoryRateLimiter()),
+		internal.WithTenants(tenants),
+		internal.WithAdmin(admin),
+	}
+
+	// Subscriptions are shared by the multiplexers built on reload, like the rate limits.
+	var subscriptions internal.SubscriptionStore = internal.NewMemorySubscriptionStore()
+
+	if cfg.WebPush.SubscriptionsDir != "" {
+		store, err := internal.NewFileSubscriptionStore(cfg.WebPush.SubscriptionsDir)
+		if err != nil {
+			return fmt.Errorf("subscription store initialization: %v", err)
+		}
+
+		subscriptions = store
+
+		health.AddReadinessCheck(internal.HealthCheck{Name: "subscription_store", Check: store.Check})
+	}
+
+	// Calls are shared as well, so that callbacks of calls placed before reload find them.
+	muxOptions = append(muxOptions,
+		internal.WithSubscriptionStore(subscriptions), internal.WithCallStore(internal.NewMemoryCallStore()))
+
+	if cfg.Server.PendingDir != "" {
+		store, err := internal.NewFilePendingStore(cfg.Server.PendingDir)
+		if err != nil {
+			return fmt.Errorf("pending store initialization: %v", err)
+		}
+
+		muxOptions = append(muxOptions, internal.WithPendingStore(store))
+
+		health.AddReadinessCheck(internal.HealthCheck{Name: "pending_store", Check: store.Check})
+
+		go func() {
+			if err := internal.ResumePending(lifecycle, cfg, store, admin.Notifier(tenants.Notifier(s))); err != nil {
+				log.Error("resuming pending notifications", "error", err)
+			}
+		}()
+	}
+
+	handler := internal.NewReloadableHandler(internal.NewMux(cfg, log, s, muxOptions...))
+
+	reloader := internal.NewReloader(cfg, load, log)
+	reloader.OnReload(applyConfig)
+	reloader.OnReload(func(cfg *internal.Config) {
+		if err := tenants.Reload(lifecycle, cfg); err != nil {
+			log.Error("tenants reload failed, keeping current tenants", "error", err)
+		}
+	})
+	reloader.OnReload(func(cfg *internal.Config) {
+		handler.Swap(internal.NewMux(cfg, log, s, muxOptions...))
+	})
+
+	if configFile != "" && cfg.Reload.WatchInterval > 0 {
+		go reloader.WatchFile(lifecycle, configFile, cfg.Reload.WatchInterval)
+	}
+
+	if cfg.Secrets.RefreshInterval > 0 {
+		go reloader.Refresh(lifecycle, cfg.Secrets.RefreshInterval)
+	}
+
 	server := &http.Server{
 		Addr:         cfg.Server.Addr(),
-		Handler:      internal.NewMux(cfg, log, s),
+		Handler:      handler,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return lifecycle
+		},
 	}
 
 	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Printf("[Server] listen on %s", server.Addr)
+		log.Info("server listening", "addr", server.Addr)
 
 		serverErrors <- server.ListenAndServe()
 	}()
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
-	select {
-	case err := <-serverErrors:
-		return fmt.Errorf("server error: %w", err)
-	case sig := <-shutdown:
-		log.Println("shutdown started", sig)
-		defer log.Println("shutdown completed", sig)
-
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
-		defer cancel()
+	for {
+		select {
+		case err := <-serverErrors:
+			return fmt.Errorf("server error: %w", err)
+		case sig := <-reload:
+			log.Info("configuration reload requested", "signal", sig)
 
-		if err := server.Shutdown(ctx); err != nil {
 			//nolint: errcheck
-			server.Close()
+			reloader.Reload()
+		case sig := <-shutdown:
+			log.Info("shutdown started", "signal", sig)
+			health.SetShuttingDown()
+			defer log.Info("shutdown completed", "signal", sig)
+
+			// Requests keep being served until load balancers notice the failing readiness probe.
+			time.Sleep(cfg.Server.PreShutdownDelay)
 
-			return fmt.Errorf("could not stop server gracefully: %v", err)
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+
+			// Notifications still in progress after the drain timeout are interrupted, so that they can be
+			// persisted for resumption before the shutdown timeout kills them mid-send.
+			drain := time.AfterFunc(cfg.Server.DrainTimeout, func() {
+				log.Info("drain timeout reached, interrupting in-flight notifications")
+				stop(internal.ErrShuttingDown)
+			})
+			defer drain.Stop()
+
+			if err := server.Shutdown(ctx); err != nil {
+				//nolint: errcheck
+				server.Close()
+
+				return fmt.Errorf("could not stop server gracefully: %v", err)
+			}
+
+			return nil
 		}
 	}
-
-	return nil
 }